@@ -4,24 +4,39 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
+	"github.com/nestjam/yap-shortener/internal/auth"
+	"github.com/nestjam/yap-shortener/internal/canonical"
 	"github.com/nestjam/yap-shortener/internal/cert"
 	conf "github.com/nestjam/yap-shortener/internal/config"
 	env "github.com/nestjam/yap-shortener/internal/config/environment"
+	"github.com/nestjam/yap-shortener/internal/domain"
 	factory "github.com/nestjam/yap-shortener/internal/factory"
+	"github.com/nestjam/yap-shortener/internal/grpcserver"
+	"github.com/nestjam/yap-shortener/internal/middleware"
+	"github.com/nestjam/yap-shortener/internal/policy"
+	pb "github.com/nestjam/yap-shortener/internal/proto"
 	"github.com/nestjam/yap-shortener/internal/server"
+	"github.com/nestjam/yap-shortener/internal/service"
 	"github.com/pkg/errors"
 )
 
 const (
 	eventKey            = "event"
 	shortenURLsMaxCount = 1000
+	urlsSweepInterval   = time.Minute
+	policyWatchInterval = 10 * time.Second
+	clicksFlushInterval = time.Second
 )
 
 var (
@@ -47,13 +62,55 @@ func main() {
 	doneCh := make(chan struct{})
 	defer close(doneCh)
 	urlRemoved := server.NewURLRemover(ctx, doneCh, store, logger)
+	_ = server.NewURLSweeper(ctx, doneCh, store, urlsSweepInterval, logger)
+	clickRecorder := server.NewClickRecorder(ctx, doneCh, store, clicksFlushInterval, logger)
+
+	authorizer := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
+	trustedSubnet := getTrustedSubnet(config, logger)
+	trustedProxy := getTrustedProxy(config, logger)
+	canonicalizer := newCanonicalizer(config)
+	domainPolicy := getDomainPolicy(config, logger)
+	rateLimits := getRateLimits(config, logger)
+	quotas := getQuotas(config, logger)
+	comingSoonPage := getComingSoonPage(config, logger)
+	redirectStatus := getRedirectStatus(config, logger)
+
+	if domainPolicy != nil {
+		domainPolicy.Watch(doneCh, policyWatchInterval, logger)
+	}
+
+	urlService := newService(store, urlRemoved, canonicalizer, domainPolicy, quotas)
 
 	handler := server.New(store, config.BaseURL,
 		server.WithLogger(logger),
-		server.WithShortenURLsMaxCount(shortenURLsMaxCount),
-		server.WithURLsRemover(urlRemoved))
-
+		server.WithService(urlService),
+		server.WithClickRecorder(clickRecorder),
+		server.WithUserAuth(authorizer),
+		server.WithTrustedSubnet(trustedSubnet),
+		server.WithTrustedProxy(trustedProxy),
+		server.WithRateLimits(rateLimits),
+		server.WithComingSoonPage(comingSoonPage),
+		server.WithRedirectStatus(redirectStatus))
+
+	grpcHandler := grpcserver.New(store, config.BaseURL,
+		grpcserver.WithLogger(logger),
+		grpcserver.WithService(urlService),
+		grpcserver.WithUserAuth(authorizer))
+
+	grpcDoneCh := runGRPCServer(ctx, config, grpcHandler, logger)
 	runServer(ctx, config, handler, logger)
+	<-grpcDoneCh
+}
+
+// newService создает сервис сокращения ссылок, общий для HTTP и gRPC серверов.
+func newService(store domain.URLStore, remover service.URLRemover, canonicalizer *canonical.Canonicalizer,
+	domainPolicy *policy.DomainPolicy, quotas service.Quotas) *service.URLService {
+	return service.New(store,
+		service.WithShortenURLsMaxCount(shortenURLsMaxCount),
+		service.WithURLRemover(remover),
+		service.WithCanonicalizer(canonicalizer),
+		service.WithDomainPolicy(domainPolicy),
+		service.WithQuotas(quotas))
 }
 
 func getConfig() conf.Config {
@@ -77,6 +134,113 @@ func getConfig() conf.Config {
 	return config
 }
 
+func getTrustedSubnet(config conf.Config, log *zap.Logger) *net.IPNet {
+	if config.TrustedSubnet == "" {
+		return nil
+	}
+
+	_, subnet, err := net.ParseCIDR(config.TrustedSubnet)
+
+	if err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "parse trusted subnet"))
+	}
+
+	return subnet
+}
+
+func getTrustedProxy(config conf.Config, log *zap.Logger) *net.IPNet {
+	if config.TrustedProxy == "" {
+		return nil
+	}
+
+	_, subnet, err := net.ParseCIDR(config.TrustedProxy)
+
+	if err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "parse trusted proxy"))
+	}
+
+	return subnet
+}
+
+func newCanonicalizer(config conf.Config) *canonical.Canonicalizer {
+	options := []canonical.Option{canonical.WithSortedQuery(config.SortQueryParams)}
+
+	if config.URLSchemes != "" {
+		options = append(options, canonical.WithSchemes(strings.Split(config.URLSchemes, ",")...))
+	}
+
+	return canonical.New(options...)
+}
+
+func getDomainPolicy(config conf.Config, log *zap.Logger) *policy.DomainPolicy {
+	if config.BlocklistPath == "" && config.AllowlistPath == "" {
+		return nil
+	}
+
+	domainPolicy, err := policy.Load(config.BlocklistPath, config.AllowlistPath)
+
+	if err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "load domain policy"))
+	}
+
+	return domainPolicy
+}
+
+func getRateLimits(config conf.Config, log *zap.Logger) server.RateLimits {
+	parse := func(rate, name string) middleware.Rate {
+		r, err := middleware.ParseRate(rate)
+
+		if err != nil {
+			log.Fatal(err.Error(), zap.String(eventKey, "parse "+name+" rate limit"))
+		}
+
+		return r
+	}
+
+	return server.RateLimits{
+		Shorten:  parse(config.ShortenRateLimit, "shorten"),
+		Batch:    parse(config.BatchRateLimit, "batch"),
+		Redirect: parse(config.RedirectRateLimit, "redirect"),
+		Delete:   parse(config.DeleteRateLimit, "delete"),
+	}
+}
+
+func getQuotas(config conf.Config, log *zap.Logger) service.Quotas {
+	users, err := service.ParseUserQuotas(config.UserURLQuotas)
+
+	if err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "parse user quotas"))
+	}
+
+	return service.Quotas{Default: config.URLQuota, Users: users}
+}
+
+func getComingSoonPage(config conf.Config, log *zap.Logger) []byte {
+	if config.ComingSoonPath == "" {
+		return nil
+	}
+
+	page, err := os.ReadFile(config.ComingSoonPath)
+
+	if err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "read coming soon page"))
+	}
+
+	return page
+}
+
+func getRedirectStatus(config conf.Config, log *zap.Logger) int {
+	if config.RedirectStatus == 0 {
+		return 0
+	}
+
+	if err := service.CheckRedirectStatus(config.RedirectStatus); err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "check redirect status"))
+	}
+
+	return config.RedirectStatus
+}
+
 func getConfigFilePath() string {
 	path := conf.GetConfigFileFromArgs(os.Args)
 
@@ -135,6 +299,38 @@ func runServer(ctx context.Context, config conf.Config, handler *server.Server,
 	log.Info("server shutdown gracefully")
 }
 
+func runGRPCServer(ctx context.Context, config conf.Config, handler *grpcserver.Server, log *zap.Logger) <-chan struct{} {
+	doneCh := make(chan struct{})
+
+	listener, err := net.Listen("tcp", config.GRPCServerAddress)
+
+	if err != nil {
+		log.Fatal(err.Error(), zap.String(eventKey, "listen grpc"))
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(handler.AuthInterceptor))
+	pb.RegisterShortenerServer(server, handler)
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	go func() {
+		defer close(doneCh)
+		log.Info("running grpc server", zap.String("address", config.GRPCServerAddress))
+
+		if err := server.Serve(listener); err != nil {
+			log.Error(err.Error(), zap.String(eventKey, "grpc serve"))
+			return
+		}
+
+		log.Info("grpc server shutdown gracefully")
+	}()
+
+	return doneCh
+}
+
 func generateAndSave(certFile, keyfile string) error {
 	const op = "generate and save"
 	cert, key, err := cert.Generate()
@@ -14,6 +14,12 @@ import (
 
 const userAuthCookieName = "userauth"
 
+// Параметры аутентификации, которые используются серверами, если аутентификация не задана опцией.
+const (
+	DefaultSecret   = "supersecretkey" // секрет подписи токена
+	DefaultTokenExp = time.Hour * 3    // время жизни токена
+)
+
 // Claims определяет зарегистрированные утверждения и данные пользователя.
 type Claims struct {
 	jwt.RegisteredClaims
@@ -93,6 +99,18 @@ func (a *UserAuth) CreateCookie(userID domain.UserID) (*http.Cookie, error) {
 	return &cookie, nil
 }
 
+// CreateToken возвращает JWT с идентификатором пользователя.
+func (a *UserAuth) CreateToken(userID domain.UserID) (string, error) {
+	const op = "create token"
+	token, err := a.buildJWT(userID)
+
+	if err != nil {
+		return "", errors.Wrap(err, op)
+	}
+
+	return token, nil
+}
+
 func (a *UserAuth) buildJWT(userID domain.UserID) (string, error) {
 	const op = "build jwt"
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
@@ -0,0 +1,135 @@
+package canonical
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultSchemes перечисляет схемы исходных URL, которые разрешены по умолчанию.
+var DefaultSchemes = []string{"http", "https"}
+
+// Порты, используемые схемами по умолчанию. Такие порты удаляются из канонического вида URL.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// Ошибки проверки исходного URL.
+var (
+	ErrURLIsInvalid       = errors.New("url is invalid")            // URL не разобран или не является абсолютным
+	ErrSchemeIsNotAllowed = errors.New("url scheme is not allowed") // схема URL не входит в число разрешенных
+	ErrHostIsEmpty        = errors.New("url host is empty")         // в URL не указан хост
+	ErrHostIsInvalid      = errors.New("url host is invalid")       // хост не может быть преобразован в punycode
+)
+
+// Canonicalizer проверяет исходные URL и приводит их к каноническому виду, по которому выявляются повторы.
+type Canonicalizer struct {
+	schemes   map[string]struct{}
+	sortQuery bool
+}
+
+// Option определяет опцию настройки Canonicalizer.
+type Option func(*Canonicalizer)
+
+// New создает Canonicalizer. По умолчанию разрешены схемы DefaultSchemes, а порядок параметров запроса
+// сохраняется.
+func New(options ...Option) *Canonicalizer {
+	c := &Canonicalizer{}
+	WithSchemes(DefaultSchemes...)(c)
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithSchemes задает схемы, разрешенные в исходных URL. Схемы сравниваются без учета регистра.
+func WithSchemes(schemes ...string) Option {
+	return func(c *Canonicalizer) {
+		c.schemes = make(map[string]struct{}, len(schemes))
+
+		for _, scheme := range schemes {
+			scheme = strings.ToLower(strings.TrimSpace(scheme))
+
+			if scheme != "" {
+				c.schemes[scheme] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithSortedQuery включает сортировку параметров запроса по имени в каноническом виде URL.
+func WithSortedQuery(sortQuery bool) Option {
+	return func(c *Canonicalizer) {
+		c.sortQuery = sortQuery
+	}
+}
+
+// Canonicalize проверяет исходный URL и возвращает его канонический вид: схема и хост в нижнем регистре,
+// интернационализированное доменное имя в punycode и без порта по умолчанию для схемы. Если включена
+// сортировка, параметры запроса упорядочиваются по имени.
+func (c *Canonicalizer) Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		return "", ErrURLIsInvalid
+	}
+
+	if _, ok := c.schemes[u.Scheme]; !ok {
+		return "", ErrSchemeIsNotAllowed
+	}
+
+	if u.Hostname() == "" {
+		return "", ErrHostIsEmpty
+	}
+
+	host, err := canonicalHost(u.Hostname())
+
+	if err != nil {
+		return "", ErrHostIsInvalid
+	}
+
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	u.Host = host
+
+	if c.sortQuery && u.RawQuery != "" {
+		if query, err := url.ParseQuery(u.RawQuery); err == nil {
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	return u.String(), nil
+}
+
+// canonicalHost возвращает хост в нижнем регистре. Доменное имя преобразуется в punycode,
+// IP адрес возвращается без изменений.
+func canonicalHost(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return strings.ToLower(host), nil
+	}
+
+	host, err := idna.Lookup.ToASCII(host)
+
+	if err != nil {
+		return "", fmt.Errorf("canonical host: %w", err)
+	}
+
+	return strings.ToLower(host), nil
+}
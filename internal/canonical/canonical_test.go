@@ -0,0 +1,145 @@
+package canonical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		want    string
+		options []Option
+	}{
+		{
+			name:   "url is canonical",
+			rawURL: "https://example.com/path?b=2&a=1#top",
+			want:   "https://example.com/path?b=2&a=1#top",
+		},
+		{
+			name:   "scheme and host in upper case",
+			rawURL: "HTTP://Example.COM/Path",
+			want:   "http://example.com/Path",
+		},
+		{
+			name:   "surrounding spaces",
+			rawURL: "  http://example.com/  ",
+			want:   "http://example.com/",
+		},
+		{
+			name:   "default http port",
+			rawURL: "http://example.com:80/a",
+			want:   "http://example.com/a",
+		},
+		{
+			name:   "default https port",
+			rawURL: "https://example.com:443/a",
+			want:   "https://example.com/a",
+		},
+		{
+			name:   "non-default port",
+			rawURL: "http://example.com:443/a",
+			want:   "http://example.com:443/a",
+		},
+		{
+			name:   "internationalized domain name",
+			rawURL: "http://Пример.рф/путь",
+			want:   "http://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C",
+		},
+		{
+			name:   "ipv6 host with default port",
+			rawURL: "http://[::1]:80/",
+			want:   "http://[::1]/",
+		},
+		{
+			name:    "sorted query",
+			rawURL:  "http://example.com/?b=2&a=3&a=1",
+			want:    "http://example.com/?a=3&a=1&b=2",
+			options: []Option{WithSortedQuery(true)},
+		},
+		{
+			name:    "allowed scheme",
+			rawURL:  "FTP://example.com:21/file",
+			want:    "ftp://example.com/file",
+			options: []Option{WithSchemes("http", " FTP ")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(tt.options...)
+
+			got, err := sut.Canonicalize(tt.rawURL)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCanonicalizeInvalidURL(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		rawURL  string
+		options []Option
+	}{
+		{
+			name:    "not a url",
+			rawURL:  "not a url",
+			wantErr: ErrURLIsInvalid,
+		},
+		{
+			name:    "relative url",
+			rawURL:  "/path",
+			wantErr: ErrURLIsInvalid,
+		},
+		{
+			name:    "opaque url",
+			rawURL:  "http:example.com",
+			wantErr: ErrURLIsInvalid,
+		},
+		{
+			name:    "javascript url",
+			rawURL:  "javascript:alert(1)",
+			wantErr: ErrURLIsInvalid,
+		},
+		{
+			name:    "scheme is not allowed",
+			rawURL:  "ftp://example.com/file",
+			wantErr: ErrSchemeIsNotAllowed,
+		},
+		{
+			name:    "scheme is not allowed by options",
+			rawURL:  "http://example.com/",
+			wantErr: ErrSchemeIsNotAllowed,
+			options: []Option{WithSchemes("https")},
+		},
+		{
+			name:    "host is empty",
+			rawURL:  "http:///path",
+			wantErr: ErrHostIsEmpty,
+		},
+		{
+			name:    "host is invalid",
+			rawURL:  "http://exa mple.com/",
+			wantErr: ErrURLIsInvalid,
+		},
+		{
+			name:    "host is not a valid domain name",
+			rawURL:  "http://-example.com/",
+			wantErr: ErrHostIsInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(tt.options...)
+
+			_, err := sut.Canonicalize(tt.rawURL)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -8,16 +8,32 @@ import (
 
 // Config описывает конфигурацию сервера сокращения ссылок.
 type Config struct {
-	ServerAddress   string `json:"server_address"`    // адрес сервера
-	BaseURL         string `json:"base_url"`          // базовый адрес сокращенной ссылки
-	FileStoragePath string `json:"file_storage_path"` // путь к файловому хранилищу сокращенных ссылок
-	DataSourceName  string `json:"database_dsn"`      // строка подключения к БД хранилища сокращенных ссылок
-	EnableHTTPS     bool   `json:"enable_https"`      // включение HTTPS в веб-сервере
+	ServerAddress     string `json:"server_address"`      // адрес сервера
+	BaseURL           string `json:"base_url"`            // базовый адрес сокращенной ссылки
+	FileStoragePath   string `json:"file_storage_path"`   // путь к файловому хранилищу сокращенных ссылок
+	DataSourceName    string `json:"database_dsn"`        // строка подключения к БД хранилища сокращенных ссылок
+	GRPCServerAddress string `json:"grpc_server_address"` // адрес gRPC сервера
+	TrustedSubnet     string `json:"trusted_subnet"`      // доверенная подсеть в формате CIDR
+	TrustedProxy      string `json:"trusted_proxy"`       // подсеть прокси-серверов в формате CIDR, которым доверен X-Real-IP
+	URLSchemes        string `json:"url_schemes"`         // схемы исходных URL через запятую; пустое значение - http и https
+	BlocklistPath     string `json:"blocklist_path"`      // путь к файлу черного списка доменов
+	AllowlistPath     string `json:"allowlist_path"`      // путь к файлу белого списка доменов
+	ShortenRateLimit  string `json:"shorten_rate_limit"`  // ограничение частоты сокращения URL, например 10/1m
+	BatchRateLimit    string `json:"batch_rate_limit"`    // ограничение частоты сокращения коллекций и импорта URL
+	RedirectRateLimit string `json:"redirect_rate_limit"` // ограничение частоты переходов по сокращенным URL
+	DeleteRateLimit   string `json:"delete_rate_limit"`   // ограничение частоты удаления URL пользователя
+	UserURLQuotas     string `json:"user_url_quotas"`     // квоты отдельных пользователей: <user id>=<limit> через запятую
+	URLQuota          int    `json:"url_quota"`           // квота пользователя на количество URL; 0 - без ограничения
+	RedirectStatus    int    `json:"redirect_status"`     // код перенаправления по умолчанию: 301, 302, 307 или 308; 0 - 307
+	ComingSoonPath    string `json:"coming_soon_path"`    // путь к HTML странице для URL, которые еще не начали действовать
+	EnableHTTPS       bool   `json:"enable_https"`        // включение HTTPS в веб-сервере
+	SortQueryParams   bool   `json:"sort_query_params"`   // сортировка параметров запроса в каноническом виде URL
 }
 
 const (
 	defaultServerAddr = ":8080"
 	defaultBaseURL    = "http://localhost:8080"
+	defaultGRPCAddr   = ":3200"
 )
 
 // Environment определяет доступ к переменным среды.
@@ -28,8 +44,9 @@ type Environment interface {
 // New создает экземпляр конфигурации с настройками по умолчанию.
 func New() Config {
 	return Config{
-		ServerAddress: defaultServerAddr,
-		BaseURL:       defaultBaseURL,
+		ServerAddress:     defaultServerAddr,
+		BaseURL:           defaultBaseURL,
+		GRPCServerAddress: defaultGRPCAddr,
 	}
 }
 
@@ -47,7 +64,22 @@ func parseArgs(conf *Config, confFilePath *string, args []string) {
 	flagSet.StringVar(&conf.BaseURL, "b", conf.BaseURL, "base URL")
 	flagSet.StringVar(&conf.FileStoragePath, "f", conf.FileStoragePath, "file storage path")
 	flagSet.StringVar(&conf.DataSourceName, "d", conf.DataSourceName, "data source name")
+	flagSet.StringVar(&conf.GRPCServerAddress, "g", conf.GRPCServerAddress, "gRPC server address")
+	flagSet.StringVar(&conf.TrustedSubnet, "t", conf.TrustedSubnet, "trusted subnet")
+	flagSet.StringVar(&conf.TrustedProxy, "trusted-proxy", conf.TrustedProxy, "trusted proxy subnet for X-Real-IP")
 	flagSet.BoolVar(&conf.EnableHTTPS, "s", conf.EnableHTTPS, "enable HTTPS")
+	flagSet.StringVar(&conf.URLSchemes, "url-schemes", conf.URLSchemes, "comma-separated allowed URL schemes")
+	flagSet.BoolVar(&conf.SortQueryParams, "sort-query", conf.SortQueryParams, "sort query params of canonical URL")
+	flagSet.StringVar(&conf.BlocklistPath, "blocklist", conf.BlocklistPath, "domain blocklist file path")
+	flagSet.StringVar(&conf.AllowlistPath, "allowlist", conf.AllowlistPath, "domain allowlist file path")
+	flagSet.StringVar(&conf.ShortenRateLimit, "shorten-rate", conf.ShortenRateLimit, "shorten rate limit, e.g. 10/1m")
+	flagSet.StringVar(&conf.BatchRateLimit, "batch-rate", conf.BatchRateLimit, "batch shorten and import rate limit")
+	flagSet.StringVar(&conf.RedirectRateLimit, "redirect-rate", conf.RedirectRateLimit, "redirect rate limit")
+	flagSet.StringVar(&conf.DeleteRateLimit, "delete-rate", conf.DeleteRateLimit, "delete rate limit")
+	flagSet.IntVar(&conf.URLQuota, "url-quota", conf.URLQuota, "max URLs per user, 0 - unlimited")
+	flagSet.StringVar(&conf.UserURLQuotas, "user-quotas", conf.UserURLQuotas, "per-user URL quotas: <user id>=<limit>,...")
+	flagSet.StringVar(&conf.ComingSoonPath, "coming-soon", conf.ComingSoonPath, "coming soon page file path")
+	flagSet.IntVar(&conf.RedirectStatus, "redirect-status", conf.RedirectStatus, "default redirect status: 301, 302, 307 or 308")
 	flagSet.StringVar(confFilePath, "c", "", "config file path")
 
 	_ = flagSet.Parse(args[1:]) // exclude command name
@@ -71,6 +103,18 @@ func (conf Config) FromEnv(env Environment) Config {
 		conf.DataSourceName = dsn
 	}
 
+	if grpcServAddr, ok := env.LookupEnv("GRPC_SERVER_ADDRESS"); ok {
+		conf.GRPCServerAddress = grpcServAddr
+	}
+
+	if subnet, ok := env.LookupEnv("TRUSTED_SUBNET"); ok {
+		conf.TrustedSubnet = subnet
+	}
+
+	if subnet, ok := env.LookupEnv("TRUSTED_PROXY"); ok {
+		conf.TrustedProxy = subnet
+	}
+
 	if enableHTTPS, ok := env.LookupEnv("ENABLE_HTTPS"); ok {
 		enable, err := strconv.ParseBool(enableHTTPS)
 
@@ -81,6 +125,72 @@ func (conf Config) FromEnv(env Environment) Config {
 		conf.EnableHTTPS = enable
 	}
 
+	if schemes, ok := env.LookupEnv("URL_SCHEMES"); ok {
+		conf.URLSchemes = schemes
+	}
+
+	if sortQuery, ok := env.LookupEnv("SORT_QUERY_PARAMS"); ok {
+		sort, err := strconv.ParseBool(sortQuery)
+
+		if err != nil {
+			panic(err)
+		}
+
+		conf.SortQueryParams = sort
+	}
+
+	if path, ok := env.LookupEnv("BLOCKLIST_PATH"); ok {
+		conf.BlocklistPath = path
+	}
+
+	if path, ok := env.LookupEnv("ALLOWLIST_PATH"); ok {
+		conf.AllowlistPath = path
+	}
+
+	if rate, ok := env.LookupEnv("SHORTEN_RATE_LIMIT"); ok {
+		conf.ShortenRateLimit = rate
+	}
+
+	if rate, ok := env.LookupEnv("BATCH_RATE_LIMIT"); ok {
+		conf.BatchRateLimit = rate
+	}
+
+	if rate, ok := env.LookupEnv("REDIRECT_RATE_LIMIT"); ok {
+		conf.RedirectRateLimit = rate
+	}
+
+	if rate, ok := env.LookupEnv("DELETE_RATE_LIMIT"); ok {
+		conf.DeleteRateLimit = rate
+	}
+
+	if quota, ok := env.LookupEnv("URL_QUOTA"); ok {
+		limit, err := strconv.Atoi(quota)
+
+		if err != nil {
+			panic(err)
+		}
+
+		conf.URLQuota = limit
+	}
+
+	if quotas, ok := env.LookupEnv("USER_URL_QUOTAS"); ok {
+		conf.UserURLQuotas = quotas
+	}
+
+	if path, ok := env.LookupEnv("COMING_SOON_PATH"); ok {
+		conf.ComingSoonPath = path
+	}
+
+	if status, ok := env.LookupEnv("REDIRECT_STATUS"); ok {
+		code, err := strconv.Atoi(status)
+
+		if err != nil {
+			panic(err)
+		}
+
+		conf.RedirectStatus = code
+	}
+
 	return conf
 }
 
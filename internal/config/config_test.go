@@ -84,6 +84,39 @@ func TestConfigFromArgs(t *testing.T) {
 				DataSourceName: "database_name",
 			},
 		},
+		{
+			name: "args contain grpc server address",
+			args: []string{
+				"app.exe",
+				"-g",
+				":3300",
+			},
+			want: Config{
+				GRPCServerAddress: ":3300",
+			},
+		},
+		{
+			name: "args contain trusted subnet",
+			args: []string{
+				"app.exe",
+				"-t",
+				"192.168.1.0/24",
+			},
+			want: Config{
+				TrustedSubnet: "192.168.1.0/24",
+			},
+		},
+		{
+			name: "args contain trusted proxy",
+			args: []string{
+				"app.exe",
+				"-trusted-proxy",
+				"10.0.0.0/8",
+			},
+			want: Config{
+				TrustedProxy: "10.0.0.0/8",
+			},
+		},
 		{
 			name: "args contain enable https flag",
 			args: []string{
@@ -94,6 +127,81 @@ func TestConfigFromArgs(t *testing.T) {
 				EnableHTTPS: true,
 			},
 		},
+		{
+			name: "args contain url schemes and sort query flag",
+			args: []string{
+				"app.exe",
+				"-url-schemes=http,https,ftp",
+				"-sort-query",
+			},
+			want: Config{
+				URLSchemes:      "http,https,ftp",
+				SortQueryParams: true,
+			},
+		},
+		{
+			name: "args contain domain list paths",
+			args: []string{
+				"app.exe",
+				"-blocklist",
+				"/etc/shortener/blocklist.txt",
+				"-allowlist=/etc/shortener/allowlist.txt",
+			},
+			want: Config{
+				BlocklistPath: "/etc/shortener/blocklist.txt",
+				AllowlistPath: "/etc/shortener/allowlist.txt",
+			},
+		},
+		{
+			name: "args contain rate limits",
+			args: []string{
+				"app.exe",
+				"-shorten-rate=10/1m",
+				"-batch-rate=2/1m",
+				"-redirect-rate",
+				"100/1s",
+				"-delete-rate=5/1h",
+			},
+			want: Config{
+				ShortenRateLimit:  "10/1m",
+				BatchRateLimit:    "2/1m",
+				RedirectRateLimit: "100/1s",
+				DeleteRateLimit:   "5/1h",
+			},
+		},
+		{
+			name: "args contain url quotas",
+			args: []string{
+				"app.exe",
+				"-url-quota=100",
+				"-user-quotas",
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c8=1000",
+			},
+			want: Config{
+				URLQuota:      100,
+				UserURLQuotas: "6ba7b810-9dad-11d1-80b4-00c04fd430c8=1000",
+			},
+		},
+		{
+			name: "args contain coming soon page path",
+			args: []string{
+				"app.exe",
+				"-coming-soon=/etc/shortener/coming_soon.html",
+			},
+			want: Config{
+				ComingSoonPath: "/etc/shortener/coming_soon.html",
+			},
+		},
+		{
+			name: "args contain redirect status",
+			args: []string{
+				"app.exe",
+				"-redirect-status=301",
+			},
+			want: Config{
+				RedirectStatus: 301,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -178,6 +286,39 @@ func TestConfigFromEnv(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "env contains grpc server address",
+			want: Config{
+				GRPCServerAddress: ":3300",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"GRPC_SERVER_ADDRESS": ":3300",
+				},
+			},
+		},
+		{
+			name: "env contains trusted subnet",
+			want: Config{
+				TrustedSubnet: "192.168.1.0/24",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"TRUSTED_SUBNET": "192.168.1.0/24",
+				},
+			},
+		},
+		{
+			name: "env contains trusted proxy",
+			want: Config{
+				TrustedProxy: "10.0.0.0/8",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"TRUSTED_PROXY": "10.0.0.0/8",
+				},
+			},
+		},
 		{
 			name: "env contains enable HTTPS flag",
 			want: Config{
@@ -189,6 +330,84 @@ func TestConfigFromEnv(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "env contains url schemes and sort query flag",
+			want: Config{
+				URLSchemes:      "https",
+				SortQueryParams: true,
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"URL_SCHEMES":       "https",
+					"SORT_QUERY_PARAMS": "1",
+				},
+			},
+		},
+		{
+			name: "env contains domain list paths",
+			want: Config{
+				BlocklistPath: "blocklist.txt",
+				AllowlistPath: "allowlist.txt",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"BLOCKLIST_PATH": "blocklist.txt",
+					"ALLOWLIST_PATH": "allowlist.txt",
+				},
+			},
+		},
+		{
+			name: "env contains rate limits",
+			want: Config{
+				ShortenRateLimit:  "10/1m",
+				BatchRateLimit:    "2/1m",
+				RedirectRateLimit: "100/1s",
+				DeleteRateLimit:   "5/1h",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"SHORTEN_RATE_LIMIT":  "10/1m",
+					"BATCH_RATE_LIMIT":    "2/1m",
+					"REDIRECT_RATE_LIMIT": "100/1s",
+					"DELETE_RATE_LIMIT":   "5/1h",
+				},
+			},
+		},
+		{
+			name: "env contains url quotas",
+			want: Config{
+				URLQuota:      100,
+				UserURLQuotas: "6ba7b810-9dad-11d1-80b4-00c04fd430c8=1000",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"URL_QUOTA":       "100",
+					"USER_URL_QUOTAS": "6ba7b810-9dad-11d1-80b4-00c04fd430c8=1000",
+				},
+			},
+		},
+		{
+			name: "env contains coming soon page path",
+			want: Config{
+				ComingSoonPath: "/etc/shortener/coming_soon.html",
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"COMING_SOON_PATH": "/etc/shortener/coming_soon.html",
+				},
+			},
+		},
+		{
+			name: "env contains redirect status",
+			want: Config{
+				RedirectStatus: 302,
+			},
+			env: &testEnvironment{
+				m: map[string]string{
+					"REDIRECT_STATUS": "302",
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -207,13 +426,25 @@ func TestConfigFromEnv(t *testing.T) {
 		conf := New()
 		assert.Panics(t, func() { _ = conf.FromEnv(env) })
 	})
+
+	t.Run("failed to parse int", func(t *testing.T) {
+		env := &testEnvironment{
+			m: map[string]string{
+				"URL_QUOTA": "many",
+			},
+		}
+
+		conf := New()
+		assert.Panics(t, func() { _ = conf.FromEnv(env) })
+	})
 }
 
 func TestNew(t *testing.T) {
 	t.Run("new", func(t *testing.T) {
 		want := Config{
-			ServerAddress: defaultServerAddr,
-			BaseURL:       defaultBaseURL,
+			ServerAddress:     defaultServerAddr,
+			BaseURL:           defaultBaseURL,
+			GRPCServerAddress: defaultGRPCAddr,
 		}
 
 		got := New()
@@ -225,17 +456,27 @@ func TestNew(t *testing.T) {
 func TestFromJSON(t *testing.T) {
 	t.Run("from json", func(t *testing.T) {
 		want := Config{
-			ServerAddress:   "localhost:8080",
-			BaseURL:         "http://localhost",
-			FileStoragePath: "/path/to/file.db",
-			EnableHTTPS:     true,
+			ServerAddress:     "localhost:8080",
+			BaseURL:           "http://localhost",
+			FileStoragePath:   "/path/to/file.db",
+			GRPCServerAddress: "localhost:3200",
+			TrustedSubnet:     "192.168.1.0/24",
+			URLSchemes:        "http,https",
+			BlocklistPath:     "/path/to/blocklist.txt",
+			EnableHTTPS:       true,
+			SortQueryParams:   true,
 		}
 		const json = `{
 	"server_address": "localhost:8080",
 	"base_url": "http://localhost",
 	"file_storage_path": "/path/to/file.db",
 	"database_dsn": "",
-	"enable_https": true
+	"grpc_server_address": "localhost:3200",
+	"trusted_subnet": "192.168.1.0/24",
+	"url_schemes": "http,https",
+	"blocklist_path": "/path/to/blocklist.txt",
+	"enable_https": true,
+	"sort_query_params": true
 } `
 
 		got := Config{}.FromJSON([]byte(json))
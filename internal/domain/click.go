@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// Click описывает переход по сокращенному URL.
+type Click struct {
+	Timestamp time.Time // время перехода
+	ShortURL  string    // сокращенный URL
+	Referrer  string    // адрес страницы, с которой выполнен переход
+	UserAgent string    // клиент пользователя
+	IP        string    // анонимизированный IP-адрес пользователя
+	Variant   string    // имя варианта исходного URL; пустое значение - варианты не заданы
+}
+
+// DailyClicks содержит количество переходов за день.
+type DailyClicks struct {
+	Date  time.Time // начало дня в UTC
+	Count int       // количество переходов
+}
+
+// VariantClicks содержит количество переходов на вариант исходного URL.
+type VariantClicks struct {
+	Variant string // имя варианта
+	Count   int    // количество переходов
+}
+
+// ClickStats содержит статистику переходов по сокращенному URL.
+type ClickStats struct {
+	Daily    []DailyClicks   // количество переходов по дням в порядке возрастания даты
+	Variants []VariantClicks // количество переходов по вариантам в порядке имен; пусто, если варианты не заданы
+	Total    int             // общее количество переходов
+}
+
+// NewClickStats вычисляет статистику по коллекции переходов.
+func NewClickStats(clicks []Click) ClickStats {
+	counts := make(map[time.Time]int)
+	variants := make(map[string]int)
+
+	for _, click := range clicks {
+		counts[startOfDay(click.Timestamp)]++
+
+		if click.Variant != "" {
+			variants[click.Variant]++
+		}
+	}
+
+	stats := ClickStats{
+		Total: len(clicks),
+		Daily: make([]DailyClicks, 0, len(counts)),
+	}
+
+	for date, count := range counts {
+		stats.Daily = append(stats.Daily, DailyClicks{Date: date, Count: count})
+	}
+
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date.Before(stats.Daily[j].Date)
+	})
+
+	for variant, count := range variants {
+		stats.Variants = append(stats.Variants, VariantClicks{Variant: variant, Count: count})
+	}
+
+	sort.Slice(stats.Variants, func(i, j int) bool {
+		return stats.Variants[i].Variant < stats.Variants[j].Variant
+	})
+
+	return stats
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -7,8 +7,12 @@ import (
 
 // Ошибки, связанные с исходным URL.
 var (
-	ErrOriginalURLNotFound  = errors.New("not found")      // исходный URL не найден
-	ErrOriginalURLIsDeleted = errors.New("url is deleted") // исходный URL удален
+	ErrOriginalURLNotFound    = errors.New("not found")                // исходный URL не найден
+	ErrOriginalURLIsDeleted   = errors.New("url is deleted")           // исходный URL удален
+	ErrOriginalURLIsExpired   = errors.New("url is expired")           // срок действия сокращенного URL истек
+	ErrOriginalURLNotOwned    = errors.New("url is not owned by user") // сокращенный URL добавлен другим пользователем
+	ErrOriginalURLIsExhausted = errors.New("url clicks are exhausted") // переходы по сокращенному URL исчерпаны
+	ErrOriginalURLIsNotActive = errors.New("url is not active yet")    // сокращенный URL еще не начал действовать
 )
 
 // OriginalURLExistsError определяет ошибку, когда исходный URL уже был сокращен.
@@ -27,6 +31,10 @@ func NewOriginalURLExistsError(shortURL string, err error) *OriginalURLExistsErr
 
 // Error возвращает текст ошибки.
 func (u *OriginalURLExistsError) Error() string {
+	if u.err == nil {
+		return fmt.Sprintf("original URL already exists: %q", u.shortURL)
+	}
+
 	return fmt.Sprintf("original URL already exists: %v", u.err.Error())
 }
 
@@ -34,3 +42,31 @@ func (u *OriginalURLExistsError) Error() string {
 func (u *OriginalURLExistsError) GetShortURL() string {
 	return u.shortURL
 }
+
+// ShortURLExistsError определяет ошибку, когда сокращенный URL уже занят другим исходным URL.
+type ShortURLExistsError struct {
+	err      error
+	shortURL string
+}
+
+// NewShortURLExistsError создает экземпляр ошибки.
+func NewShortURLExistsError(shortURL string, err error) *ShortURLExistsError {
+	return &ShortURLExistsError{
+		err:      err,
+		shortURL: shortURL,
+	}
+}
+
+// Error возвращает текст ошибки.
+func (u *ShortURLExistsError) Error() string {
+	if u.err == nil {
+		return fmt.Sprintf("short URL %q already exists", u.shortURL)
+	}
+
+	return fmt.Sprintf("short URL %q already exists: %v", u.shortURL, u.err.Error())
+}
+
+// GetShortURL возвращает занятый сокращенный URL.
+func (u *ShortURLExistsError) GetShortURL() string {
+	return u.shortURL
+}
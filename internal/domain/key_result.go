@@ -0,0 +1,45 @@
+package domain
+
+// KeyStatus определяет результат операции над одним сокращенным URL из набора.
+type KeyStatus string
+
+// Результаты операции над сокращенным URL.
+const (
+	KeyDeleted  KeyStatus = "deleted"   // сокращенный URL удален
+	KeyRestored KeyStatus = "restored"  // сокращенный URL восстановлен
+	KeyNotOwned KeyStatus = "not_owned" // сокращенный URL добавлен другим пользователем
+	KeyNotFound KeyStatus = "not_found" // сокращенный URL не найден
+)
+
+// Результаты импорта сокращенного URL.
+const (
+	KeyImported  KeyStatus = "imported"   // сокращенный URL добавлен
+	KeyTaken     KeyStatus = "key_taken"  // сокращенный URL уже занят
+	KeyURLExists KeyStatus = "url_exists" // исходный URL уже сокращен
+	KeyInvalid   KeyStatus = "invalid"    // данные сокращенного URL не прошли проверку
+)
+
+// KeyResult содержит результат операции над сокращенным URL.
+type KeyResult struct {
+	ShortURL string    // сокращенный URL
+	Status   KeyStatus // результат операции
+}
+
+// NewKeyResults возвращает результаты операции над набором сокращенных URL по их владельцам.
+// Операция считается выполненной со статусом done для URL, добавленных указанным пользователем.
+func NewKeyResults(shortURLs []string, owners map[string]UserID, userID UserID, done KeyStatus) []KeyResult {
+	results := make([]KeyResult, len(shortURLs))
+
+	for i, shortURL := range shortURLs {
+		results[i] = KeyResult{ShortURL: shortURL, Status: done}
+		owner, ok := owners[shortURL]
+
+		if !ok {
+			results[i].Status = KeyNotFound
+		} else if owner != userID {
+			results[i].Status = KeyNotOwned
+		}
+	}
+
+	return results
+}
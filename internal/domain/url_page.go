@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor возвращается, если курсор страницы не удалось разобрать.
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+const cursorSeparator = "|"
+
+// SortOrder определяет порядок сортировки URL по времени создания.
+type SortOrder int
+
+// Порядок сортировки URL.
+const (
+	SortDesc SortOrder = iota // сначала новые
+	SortAsc                   // сначала старые
+)
+
+// URLCursor указывает на последний URL предыдущей страницы.
+type URLCursor struct {
+	CreatedAt time.Time // время создания сокращенного URL
+	ShortURL  string    // сокращенный URL
+}
+
+// String возвращает курсор в виде непрозрачной строки.
+func (c URLCursor) String() string {
+	s := c.CreatedAt.UTC().Format(time.RFC3339Nano) + cursorSeparator + c.ShortURL
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseURLCursor восстанавливает курсор из строки, полученной методом String.
+func ParseURLCursor(s string) (URLCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return URLCursor{}, ErrInvalidCursor
+	}
+
+	createdAt, shortURL, ok := strings.Cut(string(data), cursorSeparator)
+
+	if !ok || shortURL == "" {
+		return URLCursor{}, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+
+	if err != nil {
+		return URLCursor{}, ErrInvalidCursor
+	}
+
+	return URLCursor{CreatedAt: t, ShortURL: shortURL}, nil
+}
+
+// UserURLsQuery содержит параметры запроса страницы URL, сокращенных пользователем.
+type UserURLsQuery struct {
+	After  *URLCursor // курсор, после которого начинается страница; nil - с начала
+	Filter string     // подстрока, которую должен содержать исходный URL
+	Tag    string     // метка, которой должен быть отмечен URL; пустое значение - без отбора по метке
+	Limit  int        // максимальное количество URL на странице
+	Order  SortOrder  // порядок сортировки по времени создания
+}
+
+// URLPage содержит страницу URL, сокращенных пользователем.
+type URLPage struct {
+	Next *URLCursor // курсор следующей страницы; nil - страница последняя
+	URLs []URLPair  // URL страницы
+}
+
+// NewURLPage выбирает из коллекции URL страницу, соответствующую запросу.
+func NewURLPage(pairs []URLPair, query UserURLsQuery) URLPage {
+	filtered := make([]URLPair, 0, len(pairs))
+
+	for _, pair := range pairs {
+		if !strings.Contains(pair.OriginalURL, query.Filter) {
+			continue
+		}
+
+		if query.Tag != "" && !pair.HasTag(query.Tag) {
+			continue
+		}
+
+		if query.After != nil && !isAfter(pair, *query.After, query.Order) {
+			continue
+		}
+
+		filtered = append(filtered, pair)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return isAfter(filtered[j], cursorOf(filtered[i]), query.Order)
+	})
+
+	return NewURLPageFromSorted(filtered, query.Limit)
+}
+
+// NewURLPageFromSorted формирует страницу из отсортированной коллекции URL. Если URL больше лимита,
+// лишние отбрасываются, а страница получает курсор следующей страницы.
+func NewURLPageFromSorted(pairs []URLPair, limit int) URLPage {
+	page := URLPage{URLs: pairs}
+
+	if limit > 0 && len(pairs) > limit {
+		page.URLs = pairs[:limit]
+		next := cursorOf(page.URLs[limit-1])
+		page.Next = &next
+	}
+
+	return page
+}
+
+func cursorOf(pair URLPair) URLCursor {
+	return URLCursor{CreatedAt: pair.CreatedAt, ShortURL: pair.ShortURL}
+}
+
+// CompareURLPairs сравнивает URL по времени создания, а при его совпадении - по сокращенному URL.
+// Возвращает -1, если a создан раньше b, 0 - если URL совпадают, и +1 - если a создан позже b.
+func CompareURLPairs(a, b URLPair) int {
+	cmp := a.CreatedAt.Compare(b.CreatedAt)
+
+	if cmp == 0 {
+		cmp = strings.Compare(a.ShortURL, b.ShortURL)
+	}
+
+	return cmp
+}
+
+// isAfter возвращает true, если URL следует за курсором в указанном порядке сортировки.
+func isAfter(pair URLPair, cursor URLCursor, order SortOrder) bool {
+	cmp := CompareURLPairs(pair, URLPair{CreatedAt: cursor.CreatedAt, ShortURL: cursor.ShortURL})
+
+	if order == SortAsc {
+		return cmp > 0
+	}
+
+	return cmp < 0
+}
@@ -1,19 +1,136 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+// Режимы выбора варианта исходного URL при переходе по сокращенному URL с несколькими вариантами.
+const (
+	RotationRandom = "random" // вариант выбирается случайно с учетом весов при каждом переходе
+	RotationSticky = "sticky" // вариант, выбранный при первом переходе, закрепляется за клиентом
+)
+
+// Destination описывает вариант исходного URL, между которыми распределяются переходы по сокращенному URL,
+// например при A/B-тестировании.
+type Destination struct {
+	Name   string // имя варианта, по которому переходы учитываются в статистике
+	URL    string // исходный URL варианта
+	Weight int    // вес варианта; доля переходов пропорциональна весу, 0 - вариант отключен
+}
+
+// RoutingRule описывает правило выбора исходного URL в зависимости от клиента, выполняющего переход.
+// Пустое условие правила выполняется для любого клиента.
+type RoutingRule struct {
+	OS       string // операционная система клиента, например ios или android
+	Device   string // класс устройства клиента: mobile, tablet или desktop
+	Language string // язык клиента из Accept-Language, например en или pt-br
+	URL      string // исходный URL, на который выполняется переход при выполнении условий правила
+}
 
 // URLPair хранит пару исходный и сокращенный URL.
 type URLPair struct {
-	ShortURL    string // сокращенный URL
-	OriginalURL string // исходный URL
+	CreatedAt      time.Time     // время создания сокращенного URL
+	ActiveFrom     time.Time     // время начала действия сокращенного URL; нулевое значение - действует сразу
+	ExpiresAt      time.Time     // время окончания действия сокращенного URL; нулевое значение - без ограничения
+	ShortURL       string        // сокращенный URL
+	OriginalURL    string        // исходный URL
+	CanonicalURL   string        // канонический вид исходного URL; пустое значение - совпадает с исходным URL
+	PasswordHash   string        // хеш пароля для перехода по сокращенному URL; пустое значение - без пароля
+	Title          string        // заголовок сокращенного URL, заданный владельцем
+	Tags           []string      // метки сокращенного URL, заданные владельцем; nil - без меток
+	Destinations   []Destination // варианты исходного URL; nil - переход выполняется на OriginalURL
+	Rotation       string        // режим выбора варианта: RotationRandom или RotationSticky
+	Rules          []RoutingRule // правила выбора исходного URL по клиенту; nil - без правил
+	ForwardPath    bool          // признак добавления к исходному URL суффикса пути после ключа сокращенного URL
+	RedirectStatus int           // код ответа перенаправления; 0 - код по умолчанию сервера
+	MaxClicks      int           // максимальное количество переходов по сокращенному URL; 0 - без ограничения
+	ClicksLeft     int           // оставшееся количество переходов; учитывается, если задано MaxClicks
+}
+
+// IsProtected возвращает true, если для перехода по сокращенному URL требуется пароль.
+func (p URLPair) IsProtected() bool {
+	return p.PasswordHash != ""
+}
+
+// IsExpired возвращает true, если срок действия сокращенного URL истек к указанному моменту времени.
+func (p URLPair) IsExpired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// IsScheduled возвращает true, если сокращенный URL еще не начал действовать к указанному моменту времени.
+func (p URLPair) IsScheduled(now time.Time) bool {
+	return !p.ActiveFrom.IsZero() && now.Before(p.ActiveFrom)
+}
+
+// IsExhausted возвращает true, если переходы по сокращенному URL с ограниченным количеством переходов
+// исчерпаны.
+func (p URLPair) IsExhausted() bool {
+	return p.MaxClicks > 0 && p.ClicksLeft <= 0
 }
 
+// HasDestinations возвращает true, если переходы по сокращенному URL распределяются между вариантами.
+func (p URLPair) HasDestinations() bool {
+	return len(p.Destinations) > 0
+}
+
+// Canonical возвращает канонический вид исходного URL, по которому выявляются повторно сокращаемые URL.
+// Если канонический вид не задан, возвращается исходный URL.
+func (p URLPair) Canonical() string {
+	if p.CanonicalURL == "" {
+		return p.OriginalURL
+	}
+
+	return p.CanonicalURL
+}
+
+// HasTag возвращает true, если сокращенный URL отмечен указанной меткой.
+func (p URLPair) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithCreatedAt возвращает пару, в которой задано время создания. Если время создания уже задано,
+// пара возвращается без изменений.
+func (p URLPair) WithCreatedAt(now time.Time) URLPair {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = now
+	}
+
+	return p
+}
+
+// URLVisitor вызывается для каждого сокращенного URL при обходе хранилища. Если функция возвращает ошибку,
+// обход прекращается, а ошибка возвращается вызывающей стороне.
+type URLVisitor func(pair URLPair, isDeleted bool) error
+
 // URLStore определяет интерфейс хранилища сокращенных URL.
 type URLStore interface {
 	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
+	GetURL(ctx context.Context, shortURL string) (URLPair, error)
 	AddURL(ctx context.Context, pair URLPair, userID UserID) error
 	AddURLs(ctx context.Context, pairs []URLPair, userID UserID) error
+	FindConflicts(ctx context.Context, pairs []URLPair) (map[string]KeyStatus, error)
 	GetUserURLs(ctx context.Context, userID UserID) ([]URLPair, error)
-	DeleteUserURLs(ctx context.Context, shortURLs []string, userID UserID) error
+	GetDeletedUserURLs(ctx context.Context, userID UserID) ([]URLPair, error)
+	GetUserURLsPage(ctx context.Context, userID UserID, query UserURLsQuery) (URLPage, error)
+	WalkUserURLs(ctx context.Context, userID UserID, visit URLVisitor) error
+	UpdateOriginalURL(ctx context.Context, shortURL, originalURL, canonicalURL string, userID UserID) error
+	UpdateTags(ctx context.Context, shortURL string, tags []string, userID UserID) error
+	UpdateRoutingRules(ctx context.Context, shortURL string, rules []RoutingRule, userID UserID) error
+	DeleteUserURLs(ctx context.Context, shortURLs []string, userID UserID) ([]KeyResult, error)
+	RestoreUserURLs(ctx context.Context, shortURLs []string, userID UserID) ([]KeyResult, error)
+	DeleteExpiredURLs(ctx context.Context, now time.Time) error
+	ConsumeClick(ctx context.Context, shortURL string) error
+	AddClicks(ctx context.Context, clicks []Click) error
+	GetClickStats(ctx context.Context, shortURL string, userID UserID) (ClickStats, error)
+	GetURLsCount(ctx context.Context) (int, error)
+	GetUserURLsCount(ctx context.Context, userID UserID) (int, error)
+	GetUsersCount(ctx context.Context) (int, error)
 	IsAvailable(ctx context.Context) bool
 }
@@ -2,7 +2,11 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,6 +38,97 @@ func (c URLStoreContract) Test(t *testing.T) {
 		assert.Equal(t, pair.OriginalURL, got)
 	})
 
+	t.Run("get url with password hash", func(t *testing.T) {
+		ctx := context.Background()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		pair := URLPair{
+			OriginalURL:  "http://example.com",
+			ShortURL:     "abc",
+			PasswordHash: "hash",
+			CreatedAt:    createdAt,
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+
+		require.NoError(t, err)
+		assert.Equal(t, pair, got)
+	})
+
+	t.Run("get url with title", func(t *testing.T) {
+		ctx := context.Background()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.com", ShortURL: "abc", Title: "Example"},
+			{OriginalURL: "http://example.org", ShortURL: "def", Title: "Example org"},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		require.NoError(t, sut.AddURL(ctx, pairs[0], NewUserID()))
+		require.NoError(t, sut.AddURLs(ctx, pairs[1:], NewUserID()))
+
+		for _, pair := range pairs {
+			got, err := sut.GetURL(ctx, pair.ShortURL)
+
+			require.NoError(t, err)
+			assert.Equal(t, pair.Title, got.Title)
+		}
+	})
+
+	t.Run("get url with tags", func(t *testing.T) {
+		ctx := context.Background()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.com", ShortURL: "abc", Tags: []string{"promo", "spring"}},
+			{OriginalURL: "http://example.org", ShortURL: "def", Tags: []string{"team"}},
+			{OriginalURL: "http://example.net", ShortURL: "ghi"},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		require.NoError(t, sut.AddURL(ctx, pairs[0], NewUserID()))
+		require.NoError(t, sut.AddURLs(ctx, pairs[1:], NewUserID()))
+
+		for _, pair := range pairs {
+			got, err := sut.GetURL(ctx, pair.ShortURL)
+
+			require.NoError(t, err)
+			assert.Equal(t, pair.Tags, got.Tags)
+		}
+	})
+
+	t.Run("get url of batch with password hash", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL:  "http://example.com",
+			ShortURL:     "abc",
+			PasswordHash: "hash",
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, []URLPair{pair}, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+
+		require.NoError(t, err)
+		assert.Equal(t, pair.PasswordHash, got.PasswordHash)
+		assert.True(t, got.IsProtected())
+	})
+
+	t.Run("get url that is not stored", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		_, err := sut.GetURL(context.Background(), "abc")
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
 	t.Run("original url not found by short url", func(t *testing.T) {
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
@@ -54,193 +149,1523 @@ func (c URLStoreContract) Test(t *testing.T) {
 
 		err := sut.AddURL(ctx, pair, userID)
 		require.NoError(t, err)
-
-		err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+		require.NoError(t, err)
+
+		_, err = sut.GetOriginalURL(ctx, pair.ShortURL)
+		assert.ErrorIs(t, err, ErrOriginalURLIsDeleted)
+	})
+
+	t.Run("get original url that is expired", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			ExpiresAt:   time.Now().Add(-time.Minute),
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.GetOriginalURL(ctx, pair.ShortURL)
+		assert.ErrorIs(t, err, ErrOriginalURLIsExpired)
+	})
+
+	t.Run("get url with max clicks", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			MaxClicks:   3,
+			ClicksLeft:  3,
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, pair.MaxClicks, got.MaxClicks)
+		assert.Equal(t, pair.ClicksLeft, got.ClicksLeft)
+	})
+
+	t.Run("consume clicks until exhausted", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			MaxClicks:   2,
+			ClicksLeft:  2,
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+		err := sut.AddURLs(ctx, []URLPair{pair}, NewUserID())
+		require.NoError(t, err)
+
+		err = sut.ConsumeClick(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.ClicksLeft)
+
+		err = sut.ConsumeClick(ctx, pair.ShortURL)
+		require.NoError(t, err)
+
+		err = sut.ConsumeClick(ctx, pair.ShortURL)
+		assert.ErrorIs(t, err, ErrOriginalURLIsExhausted)
+		_, err = sut.GetOriginalURL(ctx, pair.ShortURL)
+		assert.ErrorIs(t, err, ErrOriginalURLIsExhausted)
+	})
+
+	t.Run("consume clicks concurrently", func(t *testing.T) {
+		const (
+			maxClicks = 5
+			clicks    = 20
+		)
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			MaxClicks:   maxClicks,
+			ClicksLeft:  maxClicks,
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var consumed atomic.Int32
+		for i := 0; i < clicks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if err := sut.ConsumeClick(ctx, pair.ShortURL); err == nil {
+					consumed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(maxClicks), consumed.Load())
+	})
+
+	t.Run("consume click of url without max clicks", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		err = sut.ConsumeClick(ctx, pair.ShortURL)
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, pair.OriginalURL, got.OriginalURL)
+		assert.Equal(t, 0, got.MaxClicks)
+	})
+
+	t.Run("consume click of url that is not stored", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.ConsumeClick(context.Background(), "abc")
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("consume click of url that is deleted", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			MaxClicks:   1,
+			ClicksLeft:  1,
+		}
+		userID := NewUserID()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+		require.NoError(t, err)
+
+		err = sut.ConsumeClick(ctx, pair.ShortURL)
+
+		assert.ErrorIs(t, err, ErrOriginalURLIsDeleted)
+	})
+
+	t.Run("get original url that is not expired yet", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetOriginalURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, pair.OriginalURL, got)
+	})
+
+	t.Run("get url with activation time", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			ActiveFrom:  time.Now().Add(time.Hour),
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.WithinDuration(t, pair.ActiveFrom, got.ActiveFrom, time.Millisecond)
+		assert.True(t, got.IsScheduled(time.Now()))
+	})
+
+	t.Run("get url with destinations", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com/a",
+			ShortURL:    "abc",
+			Destinations: []Destination{
+				{Name: "a", URL: "http://example.com/a", Weight: 70},
+				{Name: "b", URL: "http://example.com/b", Weight: 30},
+			},
+			Rotation: RotationSticky,
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, []URLPair{pair}, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, pair.Destinations, got.Destinations)
+		assert.Equal(t, RotationSticky, got.Rotation)
+		assert.True(t, got.HasDestinations())
+	})
+
+	t.Run("get url without destinations", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{OriginalURL: "http://example.com", ShortURL: "abc"}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Nil(t, got.Destinations)
+		assert.False(t, got.HasDestinations())
+	})
+
+	t.Run("get url with redirect status", func(t *testing.T) {
+		ctx := context.Background()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.com", ShortURL: "abc", RedirectStatus: 301},
+			{OriginalURL: "http://example.org", ShortURL: "def"},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, pairs, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, 301, got.RedirectStatus)
+		got, err = sut.GetURL(ctx, "def")
+		require.NoError(t, err)
+		assert.Zero(t, got.RedirectStatus)
+	})
+
+	t.Run("get url with forwarded path", func(t *testing.T) {
+		ctx := context.Background()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.com/docs", ShortURL: "abc", ForwardPath: true},
+			{OriginalURL: "http://example.org/docs", ShortURL: "def"},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, pairs, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.True(t, got.ForwardPath)
+		got, err = sut.GetURL(ctx, "def")
+		require.NoError(t, err)
+		assert.False(t, got.ForwardPath)
+	})
+
+	t.Run("get url with routing rules", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+			Rules: []RoutingRule{
+				{OS: "ios", URL: "https://apps.apple.com/app/id1"},
+				{OS: "android", Device: "mobile", Language: "en", URL: "https://play.google.com/store/apps"},
+			},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, pair.Rules, got.Rules)
+	})
+
+	t.Run("get user urls with activation time", func(t *testing.T) {
+		ctx := context.Background()
+		userID := NewUserID()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.com", ShortURL: "abc", ActiveFrom: time.Now().Add(time.Hour)},
+			{OriginalURL: "http://yandex.ru", ShortURL: "def"},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+
+		got, err := sut.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		for _, pair := range got {
+			assert.Equal(t, pair.ShortURL == "abc", pair.IsScheduled(time.Now()), pair.ShortURL)
+		}
+	})
+
+	t.Run("store is available", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		got := sut.IsAvailable(context.Background())
+		assert.True(t, got)
+	})
+
+	t.Run("add batch of urls", func(t *testing.T) {
+		ctx := context.Background()
+		userID := NewUserID()
+		pairs := []URLPair{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+			},
+			{
+				ShortURL:    "123",
+				OriginalURL: "http://yandex.ru",
+			},
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, pairs, userID)
+
+		assert.NoError(t, err)
+
+		for i := 0; i < len(pairs); i++ {
+			got, err := sut.GetOriginalURL(ctx, pairs[i].ShortURL)
+			require.NoError(t, err)
+			assert.Equal(t, pairs[i].OriginalURL, got)
+		}
+	})
+
+	t.Run("find conflicts with stored urls", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		stored := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com"},
+			{ShortURL: "def", OriginalURL: "http://example.org"},
+		}
+		err := sut.AddURLs(ctx, stored, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{"def"}, userID)
+		require.NoError(t, err)
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.net"},
+			{ShortURL: "ghi", OriginalURL: "http://example.com"},
+			{ShortURL: "def", OriginalURL: "http://example.io"},
+			{ShortURL: "jkl", OriginalURL: "http://example.ru"},
+		}
+
+		got, err := sut.FindConflicts(ctx, pairs)
+
+		require.NoError(t, err)
+		want := map[string]KeyStatus{
+			"abc": KeyTaken,
+			"ghi": KeyURLExists,
+			"def": KeyTaken,
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("add same url twice", func(t *testing.T) {
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+		}
+		ctx := context.Background()
+		userID := NewUserID()
+		var want *OriginalURLExistsError
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, userID)
+
+		require.NoError(t, err)
+
+		got := sut.AddURL(ctx, pair, userID)
+
+		assert.ErrorAs(t, got, &want)
+		assert.Equal(t, pair.ShortURL, want.GetShortURL())
+	})
+
+	t.Run("add urls with taken short url", func(t *testing.T) {
+		ctx := context.Background()
+		stored := URLPair{OriginalURL: "http://example.com", ShortURL: "abc"}
+		pairs := []URLPair{
+			{OriginalURL: "http://example.org", ShortURL: "def"},
+			{OriginalURL: "http://example.net", ShortURL: "abc"},
+		}
+		var want *ShortURLExistsError
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		require.NoError(t, sut.AddURL(ctx, stored, NewUserID()))
+
+		err := sut.AddURLs(ctx, pairs, NewUserID())
+
+		require.ErrorAs(t, err, &want)
+		assert.Equal(t, "abc", want.GetShortURL())
+		got, err := sut.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, stored.OriginalURL, got)
+		_, err = sut.GetURL(ctx, "def")
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("add urls with stored original url", func(t *testing.T) {
+		ctx := context.Background()
+		stored := URLPair{OriginalURL: "http://example.com", ShortURL: "abc"}
+		pairs := []URLPair{
+			{OriginalURL: "http://example.org", ShortURL: "def"},
+			{OriginalURL: "http://example.com", ShortURL: "ghi"},
+		}
+		var want *OriginalURLExistsError
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		require.NoError(t, sut.AddURL(ctx, stored, NewUserID()))
+
+		err := sut.AddURLs(ctx, pairs, NewUserID())
+
+		require.ErrorAs(t, err, &want)
+		assert.Equal(t, "abc", want.GetShortURL())
+		_, err = sut.GetURL(ctx, "def")
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+		_, err = sut.GetURL(ctx, "ghi")
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("add urls with duplicate short url in batch", func(t *testing.T) {
+		ctx := context.Background()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.org", ShortURL: "abc"},
+			{OriginalURL: "http://example.net", ShortURL: "abc"},
+		}
+		var want *ShortURLExistsError
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, pairs, NewUserID())
+
+		require.ErrorAs(t, err, &want)
+		assert.Equal(t, "abc", want.GetShortURL())
+		_, err = sut.GetURL(ctx, "abc")
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("add urls with duplicate original url in batch", func(t *testing.T) {
+		ctx := context.Background()
+		pairs := []URLPair{
+			{OriginalURL: "http://example.org", ShortURL: "abc"},
+			{OriginalURL: "http://example.org", ShortURL: "def"},
+		}
+		var want *OriginalURLExistsError
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURLs(ctx, pairs, NewUserID())
+
+		require.ErrorAs(t, err, &want)
+		assert.Equal(t, "abc", want.GetShortURL())
+		_, err = sut.GetURL(ctx, "abc")
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+		_, err = sut.GetURL(ctx, "def")
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("get url with canonical url", func(t *testing.T) {
+		ctx := context.Background()
+		pair := URLPair{
+			OriginalURL:  "HTTP://Example.com:80",
+			CanonicalURL: "http://example.com/",
+			ShortURL:     "abc",
+		}
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+
+		require.NoError(t, err)
+		assert.Equal(t, pair.OriginalURL, got.OriginalURL)
+		assert.Equal(t, pair.CanonicalURL, got.CanonicalURL)
+	})
+
+	t.Run("add url with same canonical url", func(t *testing.T) {
+		ctx := context.Background()
+		userID := NewUserID()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, URLPair{ShortURL: "abc", OriginalURL: "http://example.com/"}, userID)
+		require.NoError(t, err)
+
+		pair := URLPair{ShortURL: "def", OriginalURL: "HTTP://EXAMPLE.COM", CanonicalURL: "http://example.com/"}
+		err = sut.AddURL(ctx, pair, userID)
+
+		var want *OriginalURLExistsError
+		require.ErrorAs(t, err, &want)
+		assert.Equal(t, "abc", want.GetShortURL())
+		conflicts, err := sut.FindConflicts(ctx, []URLPair{pair})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]KeyStatus{"def": KeyURLExists}, conflicts)
+	})
+
+	t.Run("update original url to url with same canonical url", func(t *testing.T) {
+		ctx := context.Background()
+		userID := NewUserID()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com", CanonicalURL: "http://example.com/"},
+			{ShortURL: "def", OriginalURL: "http://example.org", CanonicalURL: "http://example.org/"},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateOriginalURL(ctx, "def", "http://Example.com:80/", "http://example.com/", userID)
+
+		var want *OriginalURLExistsError
+		require.ErrorAs(t, err, &want)
+		assert.Equal(t, "abc", want.GetShortURL())
+	})
+
+	t.Run("add url with short url taken by other url", func(t *testing.T) {
+		pair := URLPair{
+			OriginalURL: "http://example.com",
+			ShortURL:    "abc",
+		}
+		otherPair := URLPair{
+			OriginalURL: "http://yandex.ru",
+			ShortURL:    pair.ShortURL,
+		}
+		ctx := context.Background()
+		var want *ShortURLExistsError
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.AddURL(ctx, pair, NewUserID())
+
+		require.NoError(t, err)
+
+		got := sut.AddURL(ctx, otherPair, NewUserID())
+
+		assert.ErrorAs(t, got, &want)
+		assert.Equal(t, pair.ShortURL, want.GetShortURL())
+
+		originalURL, err := sut.GetOriginalURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, pair.OriginalURL, originalURL)
+	})
+
+	t.Run("get user urls", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		urls := []URLPair{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+				CreatedAt:   createdAt,
+			},
+			{
+				ShortURL:    "123",
+				OriginalURL: "http://yandex.ru",
+				CreatedAt:   createdAt,
+			},
+			{
+				ShortURL:    "456",
+				OriginalURL: "http://mail.ru",
+				CreatedAt:   createdAt,
+			},
+		}
+		err := sut.AddURLs(ctx, urls[:2], userID)
+		require.NoError(t, err)
+
+		err = sut.AddURL(ctx, urls[2], userID)
+		require.NoError(t, err)
+
+		otherUserID := NewUserID()
+		otherUrls := []URLPair{
+			{
+				ShortURL:    "xyz",
+				OriginalURL: "http://google.com",
+			},
+		}
+		err = sut.AddURLs(ctx, otherUrls, otherUserID)
+		require.NoError(t, err)
+
+		userURLs, err := sut.GetUserURLs(ctx, userID)
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, urls, userURLs)
+	})
+
+	t.Run("walk user urls", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		pairs := []URLPair{
+			{ShortURL: "def", OriginalURL: "http://example.org", CreatedAt: createdAt.Add(time.Hour)},
+			{ShortURL: "abc", OriginalURL: "http://example.com", CreatedAt: createdAt, Tags: []string{"promo"}},
+			{ShortURL: "ghi", OriginalURL: "http://example.net", CreatedAt: createdAt.Add(2 * time.Hour)},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		err = sut.AddURL(ctx, URLPair{ShortURL: "jkl", OriginalURL: "http://example.io"}, NewUserID())
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{"def"}, userID)
+		require.NoError(t, err)
+
+		var visited []URLPair
+		var deleted []bool
+		err = sut.WalkUserURLs(ctx, userID, func(pair URLPair, isDeleted bool) error {
+			visited = append(visited, pair)
+			deleted = append(deleted, isDeleted)
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"abc", "def", "ghi"}, shortURLsOf(visited))
+		assert.Equal(t, []bool{false, true, false}, deleted)
+		assert.Equal(t, []string{"promo"}, visited[0].Tags)
+		assert.True(t, createdAt.Equal(visited[0].CreatedAt))
+	})
+
+	t.Run("walk user urls stops on visitor error", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com"},
+			{ShortURL: "def", OriginalURL: "http://example.org"},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		errStop := errors.New("stop")
+
+		visits := 0
+		err = sut.WalkUserURLs(ctx, userID, func(pair URLPair, isDeleted bool) error {
+			visits++
+			return errStop
+		})
+
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, visits)
+	})
+
+	t.Run("update original url", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.con"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateOriginalURL(ctx, pair.ShortURL, "http://example.com", "http://example.com/", userID)
+
+		require.NoError(t, err)
+		got, err := sut.GetOriginalURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com", got)
+	})
+
+	t.Run("update original url to the same url", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateOriginalURL(ctx, pair.ShortURL, pair.OriginalURL, pair.OriginalURL, userID)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("update original url to url shortened before", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com"},
+			{ShortURL: "def", OriginalURL: "http://example.org"},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateOriginalURL(ctx, "abc", "http://example.org", "http://example.org", userID)
+
+		var originalURLExistsErr *OriginalURLExistsError
+		require.ErrorAs(t, err, &originalURLExistsErr)
+		assert.Equal(t, "def", originalURLExistsErr.GetShortURL())
+		got, err := sut.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com", got)
+	})
+
+	t.Run("update original url added by other user", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		err = sut.UpdateOriginalURL(ctx, pair.ShortURL, "http://example.org", "http://example.org/", NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotOwned)
+	})
+
+	t.Run("update original url that is deleted", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateOriginalURL(ctx, pair.ShortURL, "http://example.org", "http://example.org/", userID)
+
+		assert.ErrorIs(t, err, ErrOriginalURLIsDeleted)
+	})
+
+	t.Run("update original url that is not stored", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.UpdateOriginalURL(context.Background(), "abc", "http://example.org", "http://example.org/", NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("update tags", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com", Tags: []string{"promo"}}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateTags(ctx, pair.ShortURL, []string{"spring", "team"}, userID)
+
+		require.NoError(t, err)
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"spring", "team"}, got.Tags)
+	})
+
+	t.Run("remove all tags", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com", Tags: []string{"promo"}}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateTags(ctx, pair.ShortURL, nil, userID)
+
+		require.NoError(t, err)
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Nil(t, got.Tags)
+	})
+
+	t.Run("update tags of url added by other user", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		err = sut.UpdateTags(ctx, pair.ShortURL, []string{"promo"}, NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotOwned)
+	})
+
+	t.Run("update tags of url that is deleted", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateTags(ctx, pair.ShortURL, []string{"promo"}, userID)
+
+		assert.ErrorIs(t, err, ErrOriginalURLIsDeleted)
+	})
+
+	t.Run("update tags of url that is not stored", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.UpdateTags(context.Background(), "abc", []string{"promo"}, NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("update routing rules", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURLs(ctx, []URLPair{pair}, userID)
+		require.NoError(t, err)
+		rules := []RoutingRule{
+			{OS: "ios", URL: "https://apps.apple.com/app/id1"},
+			{Device: "tablet", URL: "http://example.com/tablet"},
+		}
+
+		err = sut.UpdateRoutingRules(ctx, pair.ShortURL, rules, userID)
+
+		require.NoError(t, err)
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, rules, got.Rules)
+		assert.Equal(t, pair.OriginalURL, got.OriginalURL)
+	})
+
+	t.Run("remove all routing rules", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{
+			ShortURL:    "abc",
+			OriginalURL: "http://example.com",
+			Rules:       []RoutingRule{{OS: "ios", URL: "https://apps.apple.com/app/id1"}},
+		}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateRoutingRules(ctx, pair.ShortURL, nil, userID)
+
+		require.NoError(t, err)
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Nil(t, got.Rules)
+	})
+
+	t.Run("update url concurrently", func(t *testing.T) {
+		const updates = 50
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		tags := []string{"promo"}
+		rules := []RoutingRule{{OS: "ios", URL: "https://apps.apple.com/app/id1"}}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < updates; i++ {
+				assert.NoError(t, sut.UpdateTags(ctx, pair.ShortURL, tags, userID))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < updates; i++ {
+				assert.NoError(t, sut.UpdateRoutingRules(ctx, pair.ShortURL, rules, userID))
+			}
+		}()
+		wg.Wait()
+
+		got, err := sut.GetURL(ctx, pair.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, tags, got.Tags)
+		assert.Equal(t, rules, got.Rules)
+	})
+
+	t.Run("update routing rules of url added by other user", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, NewUserID())
+		require.NoError(t, err)
+
+		err = sut.UpdateRoutingRules(ctx, pair.ShortURL, []RoutingRule{{OS: "ios", URL: "http://example.org"}}, NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotOwned)
+	})
+
+	t.Run("update routing rules of url that is deleted", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+		require.NoError(t, err)
+
+		err = sut.UpdateRoutingRules(ctx, pair.ShortURL, []RoutingRule{{OS: "ios", URL: "http://example.org"}}, userID)
+
+		assert.ErrorIs(t, err, ErrOriginalURLIsDeleted)
+	})
+
+	t.Run("update routing rules of url that is not stored", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		err := sut.UpdateRoutingRules(context.Background(), "abc", nil, NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("delete requested user urls", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		urls := []URLPair{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+				CreatedAt:   createdAt,
+			},
+			{
+				ShortURL:    "123",
+				OriginalURL: "http://yandex.ru",
+				CreatedAt:   createdAt,
+			},
+			{
+				ShortURL:    "456",
+				OriginalURL: "http://mail.ru",
+				CreatedAt:   createdAt,
+			},
+		}
+		err := sut.AddURLs(ctx, urls, userID)
+		require.NoError(t, err)
+
+		shortURLs := []string{urls[0].ShortURL, urls[1].ShortURL}
+		results, err := sut.DeleteUserURLs(ctx, shortURLs, userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []KeyResult{{"abc", KeyDeleted}, {"123", KeyDeleted}}, results)
+		userURLs, err := sut.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, urls[2:], userURLs)
+	})
+
+	t.Run("other user attempts delete requested url", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		urls := []URLPair{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+				CreatedAt:   createdAt,
+			},
+		}
+		err := sut.AddURLs(ctx, urls, userID)
+		require.NoError(t, err)
+
+		shortURLs := []string{urls[0].ShortURL}
+		otherUserID := NewUserID()
+		results, err := sut.DeleteUserURLs(ctx, shortURLs, otherUserID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []KeyResult{{"abc", KeyNotOwned}}, results)
+		userURLs, err := sut.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, urls, userURLs)
+	})
+
+	t.Run("delete requested url that is not stored", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		urls := []URLPair{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+			},
+		}
+		err := sut.AddURLs(ctx, urls, userID)
+		require.NoError(t, err)
+
+		shortURLs := []string{"123", urls[0].ShortURL}
+		results, err := sut.DeleteUserURLs(ctx, shortURLs, userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []KeyResult{{"123", KeyNotFound}, {"abc", KeyDeleted}}, results)
+		userURLs, err := sut.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+
+		assert.Empty(t, userURLs)
+	})
+
+	t.Run("restore deleted user urls", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		urls := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com", CreatedAt: createdAt},
+			{ShortURL: "123", OriginalURL: "http://yandex.ru", CreatedAt: createdAt},
+		}
+		err := sut.AddURLs(ctx, urls, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{"abc", "123"}, userID)
+		require.NoError(t, err)
+
+		results, err := sut.RestoreUserURLs(ctx, []string{"abc", "456"}, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, []KeyResult{{"abc", KeyRestored}, {"456", KeyNotFound}}, results)
+		got, err := sut.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, urls[0].OriginalURL, got)
+		deleted, err := sut.GetDeletedUserURLs(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, urls[1:], deleted)
+	})
+
+	t.Run("other user attempts restore deleted url", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
 		require.NoError(t, err)
 
+		results, err := sut.RestoreUserURLs(ctx, []string{pair.ShortURL}, NewUserID())
+
+		require.NoError(t, err)
+		assert.Equal(t, []KeyResult{{"abc", KeyNotOwned}}, results)
 		_, err = sut.GetOriginalURL(ctx, pair.ShortURL)
 		assert.ErrorIs(t, err, ErrOriginalURLIsDeleted)
 	})
 
-	t.Run("store is available", func(t *testing.T) {
+	t.Run("get deleted user urls of user without deleted urls", func(t *testing.T) {
+		ctx := context.Background()
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
 
-		got := sut.IsAvailable(context.Background())
-		assert.True(t, got)
+		userID := NewUserID()
+		err := sut.AddURL(ctx, URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}, userID)
+		require.NoError(t, err)
+
+		got, err := sut.GetDeletedUserURLs(ctx, userID)
+
+		require.NoError(t, err)
+		assert.Empty(t, got)
 	})
 
-	t.Run("add batch of urls", func(t *testing.T) {
+	t.Run("delete expired urls", func(t *testing.T) {
 		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		now := time.Now()
 		userID := NewUserID()
-		pairs := []URLPair{
+		urls := []URLPair{
 			{
 				ShortURL:    "abc",
 				OriginalURL: "http://example.com",
+				ExpiresAt:   now.Add(-time.Minute),
 			},
 			{
 				ShortURL:    "123",
 				OriginalURL: "http://yandex.ru",
+				ExpiresAt:   now.Add(time.Hour),
+			},
+			{
+				ShortURL:    "456",
+				OriginalURL: "http://mail.ru",
 			},
 		}
-		sut, tearDown := c.NewURLStore()
-		t.Cleanup(tearDown)
+		err := sut.AddURLs(ctx, urls, userID)
+		require.NoError(t, err)
 
-		err := sut.AddURLs(ctx, pairs, userID)
+		err = sut.DeleteExpiredURLs(ctx, now)
 
 		assert.NoError(t, err)
+		_, err = sut.GetOriginalURL(ctx, urls[0].ShortURL)
+		assert.ErrorIs(t, err, ErrOriginalURLIsExpired)
 
-		for i := 0; i < len(pairs); i++ {
-			got, err := sut.GetOriginalURL(ctx, pairs[i].ShortURL)
+		for _, url := range urls[1:] {
+			got, err := sut.GetOriginalURL(ctx, url.ShortURL)
 			require.NoError(t, err)
-			assert.Equal(t, pairs[i].OriginalURL, got)
+			assert.Equal(t, url.OriginalURL, got)
 		}
 	})
 
-	t.Run("add same url twice", func(t *testing.T) {
+	t.Run("expired urls are not listed as deleted and can't be restored", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		now := time.Now()
+		userID := NewUserID()
+		urls := []URLPair{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+				ExpiresAt:   now.Add(-time.Minute),
+			},
+			{
+				ShortURL:    "123",
+				OriginalURL: "http://yandex.ru",
+			},
+		}
+		err := sut.AddURLs(ctx, urls, userID)
+		require.NoError(t, err)
+		err = sut.DeleteExpiredURLs(ctx, now)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{urls[1].ShortURL}, userID)
+		require.NoError(t, err)
+
+		deleted, err := sut.GetDeletedUserURLs(ctx, userID)
+
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+		assert.Equal(t, urls[1].ShortURL, deleted[0].ShortURL)
+
+		results, err := sut.RestoreUserURLs(ctx, []string{urls[0].ShortURL, urls[1].ShortURL}, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, []KeyResult{
+			{ShortURL: urls[0].ShortURL, Status: KeyNotFound},
+			{ShortURL: urls[1].ShortURL, Status: KeyRestored},
+		}, results)
+		_, err = sut.GetOriginalURL(ctx, urls[0].ShortURL)
+		assert.ErrorIs(t, err, ErrOriginalURLIsExpired)
+	})
+
+	t.Run("get click stats", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
 		pair := URLPair{
-			OriginalURL: "http://example.com",
 			ShortURL:    "abc",
+			OriginalURL: "http://example.com",
+		}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		day := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		clicks := []Click{
+			{ShortURL: pair.ShortURL, Timestamp: day, Referrer: "http://ya.ru", IP: "10.0.0.0"},
+			{ShortURL: pair.ShortURL, Timestamp: day.Add(time.Hour), UserAgent: "curl/8.0"},
+			{ShortURL: pair.ShortURL, Timestamp: day.Add(24 * time.Hour)},
+			{ShortURL: "123", Timestamp: day},
 		}
+		err = sut.AddClicks(ctx, clicks)
+		require.NoError(t, err)
+
+		got, err := sut.GetClickStats(ctx, pair.ShortURL, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, got.Total)
+		require.Len(t, got.Daily, 2)
+		assert.Equal(t, "2024-03-01", got.Daily[0].Date.Format(time.DateOnly))
+		assert.Equal(t, 2, got.Daily[0].Count)
+		assert.Equal(t, "2024-03-02", got.Daily[1].Date.Format(time.DateOnly))
+		assert.Equal(t, 1, got.Daily[1].Count)
+		assert.Empty(t, got.Variants)
+	})
+
+	t.Run("get click stats by variant", func(t *testing.T) {
 		ctx := context.Background()
-		userID := NewUserID()
-		var want *OriginalURLExistsError
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
 
+		userID := NewUserID()
+		pair := URLPair{
+			ShortURL:    "abc",
+			OriginalURL: "http://example.com/a",
+			Destinations: []Destination{
+				{Name: "a", URL: "http://example.com/a", Weight: 1},
+				{Name: "b", URL: "http://example.com/b", Weight: 1},
+			},
+		}
 		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
 
+		now := time.Now()
+		clicks := []Click{
+			{ShortURL: pair.ShortURL, Timestamp: now, Variant: "b"},
+			{ShortURL: pair.ShortURL, Timestamp: now, Variant: "a"},
+			{ShortURL: pair.ShortURL, Timestamp: now, Variant: "b"},
+			{ShortURL: "123", Timestamp: now, Variant: "a"},
+		}
+		err = sut.AddClicks(ctx, clicks)
 		require.NoError(t, err)
 
-		got := sut.AddURL(ctx, pair, userID)
+		got, err := sut.GetClickStats(ctx, pair.ShortURL, userID)
 
-		assert.ErrorAs(t, got, &want)
-		assert.Equal(t, pair.ShortURL, want.GetShortURL())
+		require.NoError(t, err)
+		assert.Equal(t, 3, got.Total)
+		want := []VariantClicks{{Variant: "a", Count: 1}, {Variant: "b", Count: 2}}
+		assert.Equal(t, want, got.Variants)
 	})
 
-	t.Run("get user urls", func(t *testing.T) {
+	t.Run("get click stats of url without clicks", func(t *testing.T) {
 		ctx := context.Background()
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
 
 		userID := NewUserID()
-		urls := []URLPair{
-			{
-				ShortURL:    "abc",
-				OriginalURL: "http://example.com",
-			},
-			{
-				ShortURL:    "123",
-				OriginalURL: "http://yandex.ru",
-			},
-			{
-				ShortURL:    "456",
-				OriginalURL: "http://mail.ru",
-			},
+		pair := URLPair{
+			ShortURL:    "abc",
+			OriginalURL: "http://example.com",
 		}
-		err := sut.AddURLs(ctx, urls[:2], userID)
+		err := sut.AddURL(ctx, pair, userID)
 		require.NoError(t, err)
 
-		err = sut.AddURL(ctx, urls[2], userID)
+		got, err := sut.GetClickStats(ctx, pair.ShortURL, userID)
+
 		require.NoError(t, err)
+		assert.Equal(t, 0, got.Total)
+		assert.Empty(t, got.Daily)
+	})
 
-		otherUserID := NewUserID()
-		otherUrls := []URLPair{
-			{
-				ShortURL:    "xyz",
-				OriginalURL: "http://google.com",
-			},
+	t.Run("get click stats of url added by other user", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		pair := URLPair{
+			ShortURL:    "abc",
+			OriginalURL: "http://example.com",
 		}
-		err = sut.AddURLs(ctx, otherUrls, otherUserID)
+		err := sut.AddURL(ctx, pair, NewUserID())
 		require.NoError(t, err)
 
-		userURLs, err := sut.GetUserURLs(ctx, userID)
+		_, err = sut.GetClickStats(ctx, pair.ShortURL, NewUserID())
 
-		assert.NoError(t, err)
-		assert.ElementsMatch(t, urls, userURLs)
+		assert.ErrorIs(t, err, ErrOriginalURLNotOwned)
 	})
 
-	t.Run("delete requested user urls", func(t *testing.T) {
+	t.Run("get click stats of url that is not stored", func(t *testing.T) {
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		_, err := sut.GetClickStats(context.Background(), "abc", NewUserID())
+
+		assert.ErrorIs(t, err, ErrOriginalURLNotFound)
+	})
+
+	t.Run("get urls and users count", func(t *testing.T) {
 		ctx := context.Background()
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
 
 		userID := NewUserID()
-		urls := []URLPair{
-			{
-				ShortURL:    "abc",
-				OriginalURL: "http://example.com",
-			},
-			{
-				ShortURL:    "123",
-				OriginalURL: "http://yandex.ru",
-			},
-			{
-				ShortURL:    "456",
-				OriginalURL: "http://mail.ru",
-			},
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com"},
+			{ShortURL: "def", OriginalURL: "http://example.org"},
 		}
-		err := sut.AddURLs(ctx, urls, userID)
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		otherPair := URLPair{ShortURL: "ghi", OriginalURL: "http://example.net"}
+		err = sut.AddURL(ctx, otherPair, NewUserID())
 		require.NoError(t, err)
 
-		shortURLs := []string{urls[0].ShortURL, urls[1].ShortURL}
-		err = sut.DeleteUserURLs(ctx, shortURLs, userID)
+		urlsCount, err := sut.GetURLsCount(ctx)
+		require.NoError(t, err)
+		usersCount, err := sut.GetUsersCount(ctx)
+		require.NoError(t, err)
 
-		assert.NoError(t, err)
-		userURLs, err := sut.GetUserURLs(ctx, userID)
+		assert.Equal(t, 3, urlsCount)
+		assert.Equal(t, 2, usersCount)
+	})
+
+	t.Run("get urls and users count of empty store", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		urlsCount, err := sut.GetURLsCount(ctx)
+		require.NoError(t, err)
+		usersCount, err := sut.GetUsersCount(ctx)
 		require.NoError(t, err)
 
-		assert.ElementsMatch(t, urls[2:], userURLs)
+		assert.Equal(t, 0, urlsCount)
+		assert.Equal(t, 0, usersCount)
 	})
 
-	t.Run("other user attempts delete requested url", func(t *testing.T) {
+	t.Run("deleted urls are not counted", func(t *testing.T) {
 		ctx := context.Background()
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
 
 		userID := NewUserID()
-		urls := []URLPair{
-			{
-				ShortURL:    "abc",
-				OriginalURL: "http://example.com",
-			},
+		pair := URLPair{ShortURL: "abc", OriginalURL: "http://example.com"}
+		err := sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+		require.NoError(t, err)
+
+		urlsCount, err := sut.GetURLsCount(ctx)
+		require.NoError(t, err)
+		usersCount, err := sut.GetUsersCount(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, urlsCount)
+		assert.Equal(t, 0, usersCount)
+	})
+
+	t.Run("get user urls count", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com"},
+			{ShortURL: "def", OriginalURL: "http://example.org"},
+			{ShortURL: "ghi", OriginalURL: "http://example.net"},
+			{ShortURL: "mno", OriginalURL: "http://example.io", ExpiresAt: time.Now().Add(-time.Hour)},
+			{ShortURL: "pqr", OriginalURL: "http://example.ru", MaxClicks: 1, ClicksLeft: 0},
 		}
-		err := sut.AddURLs(ctx, urls, userID)
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		err = sut.AddURL(ctx, URLPair{ShortURL: "jkl", OriginalURL: "http://example.edu"}, NewUserID())
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{"def"}, userID)
 		require.NoError(t, err)
 
-		shortURLs := []string{urls[0].ShortURL}
-		otherUserID := NewUserID()
-		err = sut.DeleteUserURLs(ctx, shortURLs, otherUserID)
+		count, err := sut.GetUserURLsCount(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
 
-		assert.NoError(t, err)
-		userURLs, err := sut.GetUserURLs(ctx, userID)
+		count, err = sut.GetUserURLsCount(ctx, NewUserID())
 		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
 
-		assert.ElementsMatch(t, urls, userURLs)
+	t.Run("get user urls page by page", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com", CreatedAt: createdAt},
+			{ShortURL: "def", OriginalURL: "http://example.org", CreatedAt: createdAt.Add(time.Hour)},
+			{ShortURL: "ghi", OriginalURL: "http://example.net", CreatedAt: createdAt.Add(2 * time.Hour)},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		err = sut.AddURL(ctx, URLPair{ShortURL: "jkl", OriginalURL: "http://example.io"}, NewUserID())
+		require.NoError(t, err)
+
+		first, err := sut.GetUserURLsPage(ctx, userID, UserURLsQuery{Limit: 2, Order: SortAsc})
+		require.NoError(t, err)
+		require.NotNil(t, first.Next)
+		second, err := sut.GetUserURLsPage(ctx, userID, UserURLsQuery{Limit: 2, Order: SortAsc, After: first.Next})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"abc", "def"}, shortURLsOf(first.URLs))
+		assert.Equal(t, []string{"ghi"}, shortURLsOf(second.URLs))
+		assert.Nil(t, second.Next)
 	})
 
-	t.Run("delete requested url that is not stored", func(t *testing.T) {
+	t.Run("get user urls page sorted by creation time descending", func(t *testing.T) {
 		ctx := context.Background()
 		sut, tearDown := c.NewURLStore()
 		t.Cleanup(tearDown)
 
 		userID := NewUserID()
-		urls := []URLPair{
-			{
-				ShortURL:    "abc",
-				OriginalURL: "http://example.com",
-			},
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com", CreatedAt: createdAt},
+			{ShortURL: "def", OriginalURL: "http://example.org", CreatedAt: createdAt.Add(time.Hour)},
 		}
-		err := sut.AddURLs(ctx, urls, userID)
+		err := sut.AddURLs(ctx, pairs, userID)
 		require.NoError(t, err)
 
-		shortURLs := []string{"123", urls[0].ShortURL}
-		err = sut.DeleteUserURLs(ctx, shortURLs, userID)
+		got, err := sut.GetUserURLsPage(ctx, userID, UserURLsQuery{Limit: 10, Order: SortDesc})
 
-		assert.NoError(t, err)
-		userURLs, err := sut.GetUserURLs(ctx, userID)
 		require.NoError(t, err)
+		assert.Equal(t, []string{"def", "abc"}, shortURLsOf(got.URLs))
+		assert.True(t, createdAt.Equal(got.URLs[1].CreatedAt))
+		assert.Nil(t, got.Next)
+	})
 
-		assert.Empty(t, userURLs)
+	t.Run("get user urls page filtered by original url", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com/docs"},
+			{ShortURL: "def", OriginalURL: "http://example.org"},
+			{ShortURL: "ghi", OriginalURL: "http://example.net/docs/1"},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{"ghi"}, userID)
+		require.NoError(t, err)
+
+		got, err := sut.GetUserURLsPage(ctx, userID, UserURLsQuery{Filter: "/docs"})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"abc"}, shortURLsOf(got.URLs))
+	})
+
+	t.Run("get user urls page filtered by tag", func(t *testing.T) {
+		ctx := context.Background()
+		sut, tearDown := c.NewURLStore()
+		t.Cleanup(tearDown)
+
+		userID := NewUserID()
+		pairs := []URLPair{
+			{ShortURL: "abc", OriginalURL: "http://example.com", Tags: []string{"promo", "spring"}},
+			{ShortURL: "def", OriginalURL: "http://example.org", Tags: []string{"team"}},
+			{ShortURL: "ghi", OriginalURL: "http://example.net"},
+		}
+		err := sut.AddURLs(ctx, pairs, userID)
+		require.NoError(t, err)
+		err = sut.AddURL(ctx, URLPair{ShortURL: "jkl", OriginalURL: "http://example.io", Tags: []string{"promo"}},
+			NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.GetUserURLsPage(ctx, userID, UserURLsQuery{Tag: "promo"})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"abc"}, shortURLsOf(got.URLs))
+		assert.Equal(t, []string{"promo", "spring"}, got.URLs[0].Tags)
 	})
 }
+
+func shortURLsOf(pairs []URLPair) []string {
+	shortURLs := make([]string, len(pairs))
+	for i, pair := range pairs {
+		shortURLs[i] = pair.ShortURL
+	}
+	return shortURLs
+}
@@ -3,18 +3,35 @@ package domain
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // A URLStoreDelegate allows to extend the behavior of the test double for negative scenarios
 // for URLStore consumers.
 type URLStoreDelegate struct {
-	GetOriginalURLFunc func(ctx context.Context, shortURL string) (string, error)
-	AddURLFunc         func(ctx context.Context, pair URLPair, userID UserID) error
-	AddURLsFunc        func(ctx context.Context, pairs []URLPair, userID UserID) error
-	IsAvailableFunc    func(ctx context.Context) bool
-	GetUserURLsFunc    func(ctx context.Context, userID UserID) ([]URLPair, error)
-	DeleteUserURLsFunc func(ctx context.Context, shortURLs []string, userID UserID) error
-	delegate           URLStore
+	GetOriginalURLFunc     func(ctx context.Context, shortURL string) (string, error)
+	GetURLFunc             func(ctx context.Context, shortURL string) (URLPair, error)
+	AddURLFunc             func(ctx context.Context, pair URLPair, userID UserID) error
+	AddURLsFunc            func(ctx context.Context, pairs []URLPair, userID UserID) error
+	FindConflictsFunc      func(ctx context.Context, pairs []URLPair) (map[string]KeyStatus, error)
+	IsAvailableFunc        func(ctx context.Context) bool
+	GetUserURLsFunc        func(ctx context.Context, userID UserID) ([]URLPair, error)
+	GetDeletedURLsFunc     func(ctx context.Context, userID UserID) ([]URLPair, error)
+	GetUserURLsPageFunc    func(ctx context.Context, userID UserID, query UserURLsQuery) (URLPage, error)
+	WalkUserURLsFunc       func(ctx context.Context, userID UserID, visit URLVisitor) error
+	UpdateOriginalURLFunc  func(ctx context.Context, shortURL, originalURL, canonicalURL string, userID UserID) error
+	UpdateTagsFunc         func(ctx context.Context, shortURL string, tags []string, userID UserID) error
+	UpdateRoutingRulesFunc func(ctx context.Context, shortURL string, rules []RoutingRule, userID UserID) error
+	DeleteUserURLsFunc     func(ctx context.Context, shortURLs []string, userID UserID) ([]KeyResult, error)
+	RestoreUserURLsFunc    func(ctx context.Context, shortURLs []string, userID UserID) ([]KeyResult, error)
+	DeleteExpiredURLsFunc  func(ctx context.Context, now time.Time) error
+	ConsumeClickFunc       func(ctx context.Context, shortURL string) error
+	AddClicksFunc          func(ctx context.Context, clicks []Click) error
+	GetClickStatsFunc      func(ctx context.Context, shortURL string, userID UserID) (ClickStats, error)
+	GetURLsCountFunc       func(ctx context.Context) (int, error)
+	GetUserURLsCountFunc   func(ctx context.Context, userID UserID) (int, error)
+	GetUsersCountFunc      func(ctx context.Context) (int, error)
+	delegate               URLStore
 }
 
 // NewURLStoreDelegate создает вспомогательный компонент URLStoreDelegate.
@@ -36,6 +53,21 @@ func (u *URLStoreDelegate) GetOriginalURL(ctx context.Context, shortURL string)
 	return url, nil
 }
 
+// GetURL возвращает пару исходного и сокращенного URL или ошибку.
+func (u *URLStoreDelegate) GetURL(ctx context.Context, shortURL string) (URLPair, error) {
+	if u.GetURLFunc != nil {
+		return u.GetURLFunc(ctx, shortURL)
+	}
+
+	pair, err := u.delegate.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return URLPair{}, fmt.Errorf("get url pair from store delegate: %w", err)
+	}
+
+	return pair, nil
+}
+
 // AddURL добавляет в хранилище пару исходный и сокращенный URL.
 func (u *URLStoreDelegate) AddURL(ctx context.Context, pair URLPair, userID UserID) error {
 	if u.AddURLFunc != nil {
@@ -74,6 +106,21 @@ func (u *URLStoreDelegate) AddURLs(ctx context.Context, pairs []URLPair, userID
 	return nil
 }
 
+// FindConflicts возвращает статус конфликта с сохраненными URL для каждой конфликтующей пары.
+func (u *URLStoreDelegate) FindConflicts(ctx context.Context, pairs []URLPair) (map[string]KeyStatus, error) {
+	if u.FindConflictsFunc != nil {
+		return u.FindConflictsFunc(ctx, pairs)
+	}
+
+	conflicts, err := u.delegate.FindConflicts(ctx, pairs)
+
+	if err != nil {
+		return nil, fmt.Errorf("find conflicts in store delegate: %w", err)
+	}
+
+	return conflicts, nil
+}
+
 // GetUserURLs возвращает коллекцию пар исходного и сокращенного URL, которые были добавлены указанным пользователем.
 func (u *URLStoreDelegate) GetUserURLs(ctx context.Context, userID UserID) ([]URLPair, error) {
 	if u.GetUserURLsFunc != nil {
@@ -89,18 +136,234 @@ func (u *URLStoreDelegate) GetUserURLs(ctx context.Context, userID UserID) ([]UR
 	return urls, nil
 }
 
-// DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
+// GetDeletedUserURLs возвращает коллекцию удаленных пар исходного и сокращенного URL,
 // которые были добавлены указанным пользователем.
-func (u *URLStoreDelegate) DeleteUserURLs(ctx context.Context, shortURLs []string, userID UserID) error {
+func (u *URLStoreDelegate) GetDeletedUserURLs(ctx context.Context, userID UserID) ([]URLPair, error) {
+	if u.GetDeletedURLsFunc != nil {
+		return u.GetDeletedURLsFunc(ctx, userID)
+	}
+
+	urls, err := u.delegate.GetDeletedUserURLs(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("get deleted user urls from store delegate: %w", err)
+	}
+
+	return urls, nil
+}
+
+// GetUserURLsPage возвращает страницу URL, сокращенных указанным пользователем.
+func (u *URLStoreDelegate) GetUserURLsPage(ctx context.Context, userID UserID, query UserURLsQuery) (URLPage, error) {
+	if u.GetUserURLsPageFunc != nil {
+		return u.GetUserURLsPageFunc(ctx, userID, query)
+	}
+
+	page, err := u.delegate.GetUserURLsPage(ctx, userID, query)
+
+	if err != nil {
+		return URLPage{}, fmt.Errorf("get user urls page from store delegate: %w", err)
+	}
+
+	return page, nil
+}
+
+// WalkUserURLs обходит все URL, сокращенные указанным пользователем, включая удаленные.
+func (u *URLStoreDelegate) WalkUserURLs(ctx context.Context, userID UserID, visit URLVisitor) error {
+	if u.WalkUserURLsFunc != nil {
+		return u.WalkUserURLsFunc(ctx, userID, visit)
+	}
+
+	err := u.delegate.WalkUserURLs(ctx, userID, visit)
+
+	if err != nil {
+		return fmt.Errorf("walk user urls in store delegate: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateOriginalURL заменяет исходный URL и его канонический вид для сокращенного URL, добавленного
+// указанным пользователем.
+func (u *URLStoreDelegate) UpdateOriginalURL(ctx context.Context, shortURL, originalURL, canonicalURL string,
+	userID UserID) error {
+	if u.UpdateOriginalURLFunc != nil {
+		return u.UpdateOriginalURLFunc(ctx, shortURL, originalURL, canonicalURL, userID)
+	}
+
+	err := u.delegate.UpdateOriginalURL(ctx, shortURL, originalURL, canonicalURL, userID)
+
+	if err != nil {
+		return fmt.Errorf("update original url in store delegate: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTags заменяет метки сокращенного URL, добавленного указанным пользователем.
+func (u *URLStoreDelegate) UpdateTags(ctx context.Context, shortURL string, tags []string, userID UserID) error {
+	if u.UpdateTagsFunc != nil {
+		return u.UpdateTagsFunc(ctx, shortURL, tags, userID)
+	}
+
+	err := u.delegate.UpdateTags(ctx, shortURL, tags, userID)
+
+	if err != nil {
+		return fmt.Errorf("update tags in store delegate: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRoutingRules заменяет правила выбора исходного URL сокращенного URL, добавленного указанным пользователем.
+func (u *URLStoreDelegate) UpdateRoutingRules(ctx context.Context, shortURL string, rules []RoutingRule,
+	userID UserID) error {
+	if u.UpdateRoutingRulesFunc != nil {
+		return u.UpdateRoutingRulesFunc(ctx, shortURL, rules, userID)
+	}
+
+	err := u.delegate.UpdateRoutingRules(ctx, shortURL, rules, userID)
+
+	if err != nil {
+		return fmt.Errorf("update routing rules in store delegate: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
+// которые были добавлены указанным пользователем. Возвращает результат удаления каждого URL.
+func (u *URLStoreDelegate) DeleteUserURLs(ctx context.Context, shortURLs []string, userID UserID) ([]KeyResult, error) {
 	if u.DeleteUserURLsFunc != nil {
 		return u.DeleteUserURLsFunc(ctx, shortURLs, userID)
 	}
 
-	err := u.delegate.DeleteUserURLs(ctx, shortURLs, userID)
+	results, err := u.delegate.DeleteUserURLs(ctx, shortURLs, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("delete user urls from store delegate: %w", err)
+	}
+
+	return results, nil
+}
+
+// RestoreUserURLs восстанавливает удаленные сокращенные URL, которые были добавлены указанным пользователем.
+// Возвращает результат восстановления каждого URL.
+func (u *URLStoreDelegate) RestoreUserURLs(ctx context.Context, shortURLs []string, userID UserID) ([]KeyResult, error) {
+	if u.RestoreUserURLsFunc != nil {
+		return u.RestoreUserURLsFunc(ctx, shortURLs, userID)
+	}
+
+	results, err := u.delegate.RestoreUserURLs(ctx, shortURLs, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("restore user urls from store delegate: %w", err)
+	}
+
+	return results, nil
+}
+
+// DeleteExpiredURLs удаляет из хранилища сокращенные URL, срок действия которых истек к указанному моменту.
+func (u *URLStoreDelegate) DeleteExpiredURLs(ctx context.Context, now time.Time) error {
+	if u.DeleteExpiredURLsFunc != nil {
+		return u.DeleteExpiredURLsFunc(ctx, now)
+	}
+
+	err := u.delegate.DeleteExpiredURLs(ctx, now)
+
+	if err != nil {
+		return fmt.Errorf("delete expired urls from store delegate: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeClick списывает переход по сокращенному URL с ограниченным количеством переходов.
+func (u *URLStoreDelegate) ConsumeClick(ctx context.Context, shortURL string) error {
+	if u.ConsumeClickFunc != nil {
+		return u.ConsumeClickFunc(ctx, shortURL)
+	}
+
+	err := u.delegate.ConsumeClick(ctx, shortURL)
+
+	if err != nil {
+		return fmt.Errorf("consume click in store delegate: %w", err)
+	}
+
+	return nil
+}
+
+// AddClicks добавляет в хранилище коллекцию переходов по сокращенным URL.
+func (u *URLStoreDelegate) AddClicks(ctx context.Context, clicks []Click) error {
+	if u.AddClicksFunc != nil {
+		return u.AddClicksFunc(ctx, clicks)
+	}
+
+	err := u.delegate.AddClicks(ctx, clicks)
 
 	if err != nil {
-		return fmt.Errorf("delete user urls from store delegate: %w", err)
+		return fmt.Errorf("add clicks to store delegate: %w", err)
 	}
 
 	return nil
 }
+
+// GetClickStats возвращает статистику переходов по сокращенному URL, добавленному указанным пользователем.
+func (u *URLStoreDelegate) GetClickStats(ctx context.Context, shortURL string, userID UserID) (ClickStats, error) {
+	if u.GetClickStatsFunc != nil {
+		return u.GetClickStatsFunc(ctx, shortURL, userID)
+	}
+
+	stats, err := u.delegate.GetClickStats(ctx, shortURL, userID)
+
+	if err != nil {
+		return ClickStats{}, fmt.Errorf("get click stats from store delegate: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetURLsCount возвращает количество сокращенных URL в хранилище.
+func (u *URLStoreDelegate) GetURLsCount(ctx context.Context) (int, error) {
+	if u.GetURLsCountFunc != nil {
+		return u.GetURLsCountFunc(ctx)
+	}
+
+	count, err := u.delegate.GetURLsCount(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("get urls count from store delegate: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetUserURLsCount возвращает количество действующих URL, сокращенных пользователем: неудаленных, не истекших
+// и не исчерпавших переходы.
+func (u *URLStoreDelegate) GetUserURLsCount(ctx context.Context, userID UserID) (int, error) {
+	if u.GetUserURLsCountFunc != nil {
+		return u.GetUserURLsCountFunc(ctx, userID)
+	}
+
+	count, err := u.delegate.GetUserURLsCount(ctx, userID)
+
+	if err != nil {
+		return 0, fmt.Errorf("get user urls count from store delegate: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetUsersCount возвращает количество пользователей, которые сократили URL.
+func (u *URLStoreDelegate) GetUsersCount(ctx context.Context) (int, error) {
+	if u.GetUsersCountFunc != nil {
+		return u.GetUsersCountFunc(ctx)
+	}
+
+	count, err := u.delegate.GetUsersCount(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("get users count from store delegate: %w", err)
+	}
+
+	return count, nil
+}
@@ -48,18 +48,30 @@ func newPGSQLStore(ctx context.Context, conf conf.Config, logger *zap.Logger) (d
 }
 
 func newFileStore(ctx context.Context, conf conf.Config, logger *zap.Logger) (domain.URLStore, func()) {
-	const ownerReadWritePermission os.FileMode = 0600
+	const (
+		ownerReadWritePermission os.FileMode = 0600
+		clicksFileSuffix                     = ".clicks"
+	)
 	file, err := os.OpenFile(conf.FileStoragePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, ownerReadWritePermission)
 	if err != nil {
 		logger.Fatal(err.Error(), zap.String(eventKey, "open file"))
 	}
 
-	store, err := filestore.New(ctx, file)
+	clicksPath := conf.FileStoragePath + clicksFileSuffix
+	clicksFile, err := os.OpenFile(clicksPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, ownerReadWritePermission)
+	if err != nil {
+		logger.Fatal(err.Error(), zap.String(eventKey, "open clicks file"))
+	}
+
+	store, err := filestore.New(ctx, file, filestore.WithClicks(clicksFile))
 	if err != nil {
 		logger.Fatal(err.Error(), zap.String(eventKey, "create store"))
 	}
 
-	closer := func() { _ = file.Close() }
+	closer := func() {
+		_ = clicksFile.Close()
+		_ = file.Close()
+	}
 	return store, closer
 }
 
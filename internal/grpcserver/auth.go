@@ -0,0 +1,61 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// UserAuthMetadataKey определяет ключ метаданных, в котором передается токен пользователя.
+const UserAuthMetadataKey = "userauth"
+
+// AuthInterceptor добавляет в контекст запроса данные для аутентификации пользователя.
+// Токен пользователя передается в метаданных запроса. Если токен отсутствует или недействителен,
+// создается новый пользователь, токен которого возвращается в заголовке ответа.
+func (s *Server) AuthInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	userID, isNew := s.createOrGetUserID(ctx)
+
+	if isNew {
+		token, err := s.authorizer.CreateToken(userID)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to add user id")
+		}
+
+		if err = grpc.SetHeader(ctx, metadata.Pairs(UserAuthMetadataKey, token)); err != nil {
+			return nil, status.Error(codes.Internal, "failed to add user id")
+		}
+	}
+
+	user := customctx.NewUser(userID, isNew)
+	return handler(customctx.SetUser(ctx, user), req)
+}
+
+func (s *Server) createOrGetUserID(ctx context.Context) (domain.UserID, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !ok {
+		return domain.NewUserID(), true
+	}
+
+	values := md.Get(UserAuthMetadataKey)
+
+	if len(values) == 0 {
+		return domain.NewUserID(), true
+	}
+
+	userID, err := s.authorizer.ParseJWT(values[0])
+
+	if err != nil {
+		return domain.NewUserID(), true
+	}
+
+	return userID, false
+}
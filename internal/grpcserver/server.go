@@ -0,0 +1,369 @@
+// Package grpcserver реализует gRPC API сервиса сокращения ссылок.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nestjam/yap-shortener/internal/auth"
+	"github.com/nestjam/yap-shortener/internal/canonical"
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/policy"
+	pb "github.com/nestjam/yap-shortener/internal/proto"
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+// Server реализует gRPC API сервиса сокращения ссылок.
+type Server struct {
+	pb.UnimplementedShortenerServer
+	logger              *zap.Logger
+	urlRemover          service.URLRemover
+	authorizer          *auth.UserAuth
+	service             *service.URLService
+	canonicalizer       *canonical.Canonicalizer
+	domainPolicy        *policy.DomainPolicy
+	quotas              service.Quotas
+	baseURL             string
+	shortenURLsMaxCount int
+}
+
+// Option определяет опцию настройки сервера.
+type Option func(*Server)
+
+// New создает gRPC сервер сокращения ссылок на основе хранилища, базового адреса сокращенных URL
+// и набора опций.
+func New(store domain.URLStore, baseURL string, options ...Option) *Server {
+	s := &Server{
+		baseURL:    baseURL,
+		logger:     zap.NewNop(),
+		authorizer: auth.New(auth.DefaultSecret, auth.DefaultTokenExp),
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	if s.service == nil {
+		s.service = s.newService(store)
+	}
+
+	return s
+}
+
+// newService создает сервис сокращения ссылок с настройками сервера, если сервис не задан опцией WithService.
+func (s *Server) newService(store domain.URLStore) *service.URLService {
+	serviceOptions := []service.Option{service.WithShortenURLsMaxCount(s.shortenURLsMaxCount)}
+
+	if s.urlRemover != nil {
+		serviceOptions = append(serviceOptions, service.WithURLRemover(s.urlRemover))
+	}
+
+	if s.canonicalizer != nil {
+		serviceOptions = append(serviceOptions, service.WithCanonicalizer(s.canonicalizer))
+	}
+
+	if s.domainPolicy != nil {
+		serviceOptions = append(serviceOptions, service.WithDomainPolicy(s.domainPolicy))
+	}
+
+	serviceOptions = append(serviceOptions, service.WithQuotas(s.quotas))
+	return service.New(store, serviceOptions...)
+}
+
+// Shorten сокращает URL.
+func (s *Server) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	user, _ := customctx.GetUser(ctx)
+	shortURL, err := s.service.Shorten(ctx, service.ShortenRequest{
+		URL:            req.GetUrl(),
+		Alias:          req.GetAlias(),
+		ExpiresAt:      toTime(req.GetExpiresAt()),
+		ActiveFrom:     toTime(req.GetActiveFrom()),
+		TTL:            req.GetTtl(),
+		Password:       req.GetPassword(),
+		Title:          req.GetTitle(),
+		Tags:           req.GetTags(),
+		MaxClicks:      int(req.GetMaxClicks()),
+		Destinations:   toDestinations(req.GetDestinations()),
+		Rotation:       req.GetRotation(),
+		Rules:          toRoutingRules(req.GetRules()),
+		ForwardPath:    req.GetForwardPath(),
+		RedirectStatus: int(req.GetRedirectStatus()),
+	}, user.ID)
+
+	var originalURLAlreadyExists *domain.OriginalURLExistsError
+	if errors.As(err, &originalURLAlreadyExists) {
+		return &pb.ShortenResponse{
+			Result:        joinPath(s.baseURL, shortURL),
+			AlreadyExists: true,
+		}, nil
+	}
+
+	if err != nil {
+		return nil, s.toStatus(err, "failed to store url")
+	}
+
+	return &pb.ShortenResponse{Result: joinPath(s.baseURL, shortURL)}, nil
+}
+
+// ShortenBatch сокращает коллекцию URL.
+func (s *Server) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	items := req.GetItems()
+	reqs := make([]service.ShortenRequest, len(items))
+	for i, item := range items {
+		reqs[i] = service.ShortenRequest{
+			URL:            item.GetOriginalUrl(),
+			ExpiresAt:      toTime(item.GetExpiresAt()),
+			ActiveFrom:     toTime(item.GetActiveFrom()),
+			TTL:            item.GetTtl(),
+			Password:       item.GetPassword(),
+			Title:          item.GetTitle(),
+			Tags:           item.GetTags(),
+			MaxClicks:      int(item.GetMaxClicks()),
+			Destinations:   toDestinations(item.GetDestinations()),
+			Rotation:       item.GetRotation(),
+			Rules:          toRoutingRules(item.GetRules()),
+			ForwardPath:    item.GetForwardPath(),
+			RedirectStatus: int(item.GetRedirectStatus()),
+		}
+	}
+
+	user, _ := customctx.GetUser(ctx)
+	shortURLs, err := s.service.ShortenBatch(ctx, reqs, user.ID)
+
+	if err != nil {
+		return nil, s.toStatus(err, "failed to store url")
+	}
+
+	resp := &pb.ShortenBatchResponse{Items: make([]*pb.ShortenBatchResponse_Item, len(items))}
+	for i, item := range items {
+		resp.Items[i] = &pb.ShortenBatchResponse_Item{
+			CorrelationId: item.GetCorrelationId(),
+			ShortUrl:      joinPath(s.baseURL, shortURLs[i]),
+		}
+	}
+
+	return resp, nil
+}
+
+// Expand возвращает исходный URL по ключу сокращенного URL.
+func (s *Server) Expand(ctx context.Context, req *pb.ExpandRequest) (*pb.ExpandResponse, error) {
+	url, err := s.service.Expand(ctx, req.GetShortUrl(), req.GetPassword())
+
+	if err != nil {
+		return nil, s.toStatus(err, "failed to get url")
+	}
+
+	return &pb.ExpandResponse{OriginalUrl: url}, nil
+}
+
+// ListUserURLs возвращает страницу URL, сокращенных пользователем. Курсор следующей страницы
+// передается в ответе; пустой курсор означает, что страница последняя.
+func (s *Server) ListUserURLs(ctx context.Context, req *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	page, err := s.service.GetUserURLsPage(ctx, service.UserURLsRequest{
+		Cursor: req.GetCursor(),
+		Filter: req.GetFilter(),
+		Tag:    req.GetTag(),
+		Order:  req.GetOrder(),
+		Limit:  int(req.GetLimit()),
+	}, user.ID)
+
+	if err != nil {
+		return nil, s.toStatus(err, "failed to get user urls")
+	}
+
+	resp := &pb.ListUserURLsResponse{
+		Urls:       make([]*pb.UserURL, len(page.URLs)),
+		NextCursor: page.NextCursor,
+	}
+	for i, pair := range page.URLs {
+		resp.Urls[i] = &pb.UserURL{
+			ShortUrl:    joinPath(s.baseURL, pair.ShortURL),
+			OriginalUrl: pair.OriginalURL,
+			Tags:        pair.Tags,
+		}
+	}
+
+	return resp, nil
+}
+
+// DeleteUserURLs удаляет URL, сокращенные пользователем, и возвращает идентификатор задания удаления.
+// Состояние задания доступно по запросу GET /api/user/jobs/{id}.
+func (s *Server) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	user, _ := customctx.GetUser(ctx)
+	jobID, err := s.service.DeleteUserURLs(ctx, req.GetShortUrls(), user.ID)
+
+	if err != nil {
+		return nil, s.toStatus(err, "failed to delete urls")
+	}
+
+	return &pb.DeleteUserURLsResponse{JobId: jobID}, nil
+}
+
+// Ping проверяет доступность хранилища.
+func (s *Server) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	if !s.service.IsAvailable(ctx) {
+		return nil, status.Error(codes.Unavailable, "store is not available")
+	}
+
+	return &pb.PingResponse{}, nil
+}
+
+// toStatus возвращает ошибку gRPC с кодом, соответствующим ошибке сервиса.
+func (s *Server) toStatus(err error, message string) error {
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		return status.Error(codes.InvalidArgument, validationErr.Error())
+	}
+
+	var shortURLAlreadyExists *domain.ShortURLExistsError
+	if errors.As(err, &shortURLAlreadyExists) {
+		return status.Error(codes.AlreadyExists, "alias is already taken")
+	}
+
+	var violationErr *policy.ViolationError
+	if errors.As(err, &violationErr) {
+		return status.Error(codes.PermissionDenied, violationErr.Error())
+	}
+
+	var quotaExceeded *service.QuotaExceededError
+	if errors.As(err, &quotaExceeded) {
+		return status.Error(codes.ResourceExhausted, quotaExceeded.Error())
+	}
+
+	switch {
+	case errors.Is(err, service.ErrPasswordRequired):
+		return status.Error(codes.PermissionDenied, service.ErrPasswordRequired.Error())
+	case errors.Is(err, service.ErrPasswordIsInvalid):
+		return status.Error(codes.PermissionDenied, service.ErrPasswordIsInvalid.Error())
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, service.ErrTooManyAttempts.Error())
+	case errors.Is(err, service.ErrTooManyURLs):
+		return status.Error(codes.PermissionDenied, service.ErrTooManyURLs.Error())
+	case errors.Is(err, domain.ErrOriginalURLNotFound), errors.Is(err, domain.ErrOriginalURLIsNotActive):
+		return status.Error(codes.NotFound, domain.ErrOriginalURLNotFound.Error())
+	case errors.Is(err, domain.ErrOriginalURLIsDeleted):
+		return status.Error(codes.FailedPrecondition, "url is deleted")
+	case errors.Is(err, domain.ErrOriginalURLIsExpired):
+		return status.Error(codes.FailedPrecondition, domain.ErrOriginalURLIsExpired.Error())
+	case errors.Is(err, domain.ErrOriginalURLIsExhausted):
+		return status.Error(codes.FailedPrecondition, domain.ErrOriginalURLIsExhausted.Error())
+	}
+
+	s.logger.Error(message, zap.Error(err))
+	return status.Error(codes.Internal, message)
+}
+
+func toTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+
+	t := ts.AsTime()
+	return &t
+}
+
+func toDestinations(destinations []*pb.Destination) []domain.Destination {
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	resp := make([]domain.Destination, len(destinations))
+	for i, d := range destinations {
+		resp[i] = domain.Destination{Name: d.GetName(), URL: d.GetUrl(), Weight: int(d.GetWeight())}
+	}
+
+	return resp
+}
+
+func toRoutingRules(rules []*pb.RoutingRule) []domain.RoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	resp := make([]domain.RoutingRule, len(rules))
+	for i, rule := range rules {
+		resp[i] = domain.RoutingRule{
+			OS:       rule.GetOs(),
+			Device:   rule.GetDevice(),
+			Language: rule.GetLanguage(),
+			URL:      rule.GetUrl(),
+		}
+	}
+
+	return resp
+}
+
+func joinPath(base, elem string) string {
+	return fmt.Sprintf("%s/%s", base, elem)
+}
+
+// WithLogger задает логгер.
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithService задает сервис сокращения ссылок, общий с HTTP сервером.
+// Опции, которые настраивают сервис, при этом не применяются.
+func WithService(svc *service.URLService) Option {
+	return func(s *Server) {
+		s.service = svc
+	}
+}
+
+// WithShortenURLsMaxCount определяет максимальное количество URL в запросе на сокращение коллекции URL.
+func WithShortenURLsMaxCount(count int) Option {
+	return func(s *Server) {
+		s.shortenURLsMaxCount = count
+	}
+}
+
+// WithURLsRemover задает компонент, который выполняет удаление сохраненных URL.
+func WithURLsRemover(remover service.URLRemover) Option {
+	return func(s *Server) {
+		s.urlRemover = remover
+	}
+}
+
+// WithUserAuth задает компонент аутентификации пользователей.
+func WithUserAuth(authorizer *auth.UserAuth) Option {
+	return func(s *Server) {
+		s.authorizer = authorizer
+	}
+}
+
+// WithCanonicalizer задает компонент проверки исходных URL и приведения их к каноническому виду.
+func WithCanonicalizer(canonicalizer *canonical.Canonicalizer) Option {
+	return func(s *Server) {
+		s.canonicalizer = canonicalizer
+	}
+}
+
+// WithDomainPolicy задает политику доменов исходных URL.
+func WithDomainPolicy(domainPolicy *policy.DomainPolicy) Option {
+	return func(s *Server) {
+		s.domainPolicy = domainPolicy
+	}
+}
+
+// WithQuotas задает квоты пользователей на количество сокращенных URL.
+func WithQuotas(quotas service.Quotas) Option {
+	return func(s *Server) {
+		s.quotas = quotas
+	}
+}
@@ -0,0 +1,455 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nestjam/yap-shortener/internal/auth"
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+	pb "github.com/nestjam/yap-shortener/internal/proto"
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+const (
+	testURL = "https://practicum.yandex.ru/"
+	baseURL = "http://localhost:8080"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("shorten url", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		resp, err := client.Shorten(context.Background(), &pb.ShortenRequest{Url: testURL})
+
+		require.NoError(t, err)
+		assert.Contains(t, resp.GetResult(), baseURL+"/")
+		assert.False(t, resp.GetAlreadyExists())
+	})
+
+	t.Run("shorten same url twice", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		ctx := context.Background()
+		first, err := client.Shorten(ctx, &pb.ShortenRequest{Url: testURL})
+		require.NoError(t, err)
+
+		second, err := client.Shorten(ctx, &pb.ShortenRequest{Url: testURL})
+
+		require.NoError(t, err)
+		assert.Equal(t, first.GetResult(), second.GetResult())
+		assert.True(t, second.GetAlreadyExists())
+	})
+
+	t.Run("url is empty", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		_, err := client.Shorten(context.Background(), &pb.ShortenRequest{})
+
+		assertStatus(t, codes.InvalidArgument, service.ErrURLIsEmpty.Error(), err)
+	})
+
+	t.Run("alias is taken", func(t *testing.T) {
+		const alias = "promo25"
+		client := newClient(t, inmemory.New())
+		ctx := context.Background()
+		_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: testURL, Alias: alias})
+		require.NoError(t, err)
+
+		_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://ya.ru/", Alias: alias})
+
+		assertStatus(t, codes.AlreadyExists, "alias is already taken", err)
+	})
+
+	t.Run("shorten url with redirect options", func(t *testing.T) {
+		const alias = "promo25"
+		store := inmemory.New()
+		client := newClient(t, store)
+		activeFrom := time.Now().Add(time.Hour).UTC()
+		req := &pb.ShortenRequest{
+			Url:            testURL,
+			Alias:          alias,
+			MaxClicks:      10,
+			ActiveFrom:     timestamppb.New(activeFrom),
+			Rules:          []*pb.RoutingRule{{Os: "ios", Device: "mobile", Language: "en", Url: "https://ya.ru/"}},
+			ForwardPath:    true,
+			RedirectStatus: 308,
+		}
+
+		_, err := client.Shorten(context.Background(), req)
+
+		require.NoError(t, err)
+		got, err := store.GetURL(context.Background(), alias)
+		require.NoError(t, err)
+		assert.Equal(t, 10, got.MaxClicks)
+		assert.True(t, activeFrom.Equal(got.ActiveFrom))
+		assert.Equal(t, []domain.RoutingRule{{OS: "ios", Device: "mobile", Language: "en", URL: "https://ya.ru/"}},
+			got.Rules)
+		assert.True(t, got.ForwardPath)
+		assert.Equal(t, 308, got.RedirectStatus)
+	})
+
+	t.Run("shorten url with destinations", func(t *testing.T) {
+		const alias = "promo25"
+		store := inmemory.New()
+		client := newClient(t, store)
+		req := &pb.ShortenRequest{
+			Alias: alias,
+			Destinations: []*pb.Destination{
+				{Name: "a", Url: testURL, Weight: 1},
+				{Name: "b", Url: "https://ya.ru/", Weight: 3},
+			},
+			Rotation: domain.RotationSticky,
+		}
+
+		_, err := client.Shorten(context.Background(), req)
+
+		require.NoError(t, err)
+		got, err := store.GetURL(context.Background(), alias)
+		require.NoError(t, err)
+		want := []domain.Destination{
+			{Name: "a", URL: testURL, Weight: 1},
+			{Name: "b", URL: "https://ya.ru/", Weight: 3},
+		}
+		assert.Equal(t, want, got.Destinations)
+		assert.Equal(t, domain.RotationSticky, got.Rotation)
+	})
+
+	t.Run("redirect status is invalid", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		_, err := client.Shorten(context.Background(), &pb.ShortenRequest{Url: testURL, RedirectStatus: 303})
+
+		assertStatus(t, codes.InvalidArgument, service.ErrRedirectStatusIsInvalid.Error(), err)
+	})
+
+	t.Run("shorten batch", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		req := &pb.ShortenBatchRequest{
+			Items: []*pb.ShortenBatchRequest_Item{
+				{CorrelationId: "1", OriginalUrl: testURL},
+				{CorrelationId: "2", OriginalUrl: "https://ya.ru/"},
+			},
+		}
+
+		resp, err := client.ShortenBatch(context.Background(), req)
+
+		require.NoError(t, err)
+		require.Len(t, resp.GetItems(), 2)
+		assert.Equal(t, "1", resp.GetItems()[0].GetCorrelationId())
+		assert.Equal(t, "2", resp.GetItems()[1].GetCorrelationId())
+	})
+
+	t.Run("shorten batch with redirect options", func(t *testing.T) {
+		store := inmemory.New()
+		client := newClient(t, store)
+		activeFrom := time.Now().Add(time.Hour).UTC()
+		req := &pb.ShortenBatchRequest{
+			Items: []*pb.ShortenBatchRequest_Item{
+				{
+					CorrelationId:  "1",
+					OriginalUrl:    testURL,
+					MaxClicks:      10,
+					ActiveFrom:     timestamppb.New(activeFrom),
+					Rules:          []*pb.RoutingRule{{Device: "mobile", Url: "https://m.ya.ru/"}},
+					ForwardPath:    true,
+					RedirectStatus: 301,
+				},
+				{
+					CorrelationId: "2",
+					Destinations:  []*pb.Destination{{Name: "a", Url: "https://ya.ru/", Weight: 1}},
+					Rotation:      domain.RotationRandom,
+				},
+			},
+		}
+
+		resp, err := client.ShortenBatch(context.Background(), req)
+
+		require.NoError(t, err)
+		require.Len(t, resp.GetItems(), 2)
+		first, err := store.GetURL(context.Background(), strings.TrimPrefix(resp.GetItems()[0].GetShortUrl(), baseURL+"/"))
+		require.NoError(t, err)
+		assert.Equal(t, 10, first.MaxClicks)
+		assert.True(t, activeFrom.Equal(first.ActiveFrom))
+		assert.Equal(t, []domain.RoutingRule{{Device: "mobile", URL: "https://m.ya.ru/"}}, first.Rules)
+		assert.True(t, first.ForwardPath)
+		assert.Equal(t, 301, first.RedirectStatus)
+		second, err := store.GetURL(context.Background(), strings.TrimPrefix(resp.GetItems()[1].GetShortUrl(), baseURL+"/"))
+		require.NoError(t, err)
+		assert.Equal(t, []domain.Destination{{Name: "a", URL: "https://ya.ru/", Weight: 1}}, second.Destinations)
+		assert.Equal(t, domain.RotationRandom, second.Rotation)
+	})
+
+	t.Run("batch is empty", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		_, err := client.ShortenBatch(context.Background(), &pb.ShortenBatchRequest{})
+
+		assertStatus(t, codes.InvalidArgument, service.ErrBatchIsEmpty.Error(), err)
+	})
+
+	t.Run("batch exceeds quota", func(t *testing.T) {
+		client := newClient(t, inmemory.New(), WithQuotas(service.Quotas{Default: 1}))
+		req := &pb.ShortenBatchRequest{
+			Items: []*pb.ShortenBatchRequest_Item{
+				{CorrelationId: "1", OriginalUrl: testURL},
+				{CorrelationId: "2", OriginalUrl: "https://ya.ru/"},
+			},
+		}
+
+		_, err := client.ShortenBatch(context.Background(), req)
+
+		assertStatus(t, codes.ResourceExhausted, "url quota exceeded: 0 of 1 urls used", err)
+	})
+
+	t.Run("shared service", func(t *testing.T) {
+		store := inmemory.New()
+		svc := service.New(store, service.WithQuotas(service.Quotas{Default: 1}))
+		client := newClient(t, store, WithService(svc))
+		req := &pb.ShortenBatchRequest{
+			Items: []*pb.ShortenBatchRequest_Item{
+				{CorrelationId: "1", OriginalUrl: testURL},
+				{CorrelationId: "2", OriginalUrl: "https://ya.ru/"},
+			},
+		}
+
+		_, err := client.ShortenBatch(context.Background(), req)
+
+		assertStatus(t, codes.ResourceExhausted, "url quota exceeded: 0 of 1 urls used", err)
+	})
+
+	t.Run("expand url", func(t *testing.T) {
+		const shortURL = "EwHXdJfB"
+		store := inmemory.New()
+		pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL}
+		require.NoError(t, store.AddURL(context.Background(), pair, domain.NewUserID()))
+		client := newClient(t, store)
+
+		resp, err := client.Expand(context.Background(), &pb.ExpandRequest{ShortUrl: shortURL})
+
+		require.NoError(t, err)
+		assert.Equal(t, testURL, resp.GetOriginalUrl())
+	})
+
+	t.Run("expand url that is not found", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		_, err := client.Expand(context.Background(), &pb.ExpandRequest{ShortUrl: "EwHXdJfB"})
+
+		assertStatus(t, codes.NotFound, domain.ErrOriginalURLNotFound.Error(), err)
+	})
+
+	t.Run("expand url that is deleted", func(t *testing.T) {
+		const shortURL = "EwHXdJfB"
+		store := inmemory.New()
+		userID := domain.NewUserID()
+		ctx := context.Background()
+		pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL}
+		require.NoError(t, store.AddURL(ctx, pair, userID))
+		_, err := store.DeleteUserURLs(ctx, []string{shortURL}, userID)
+		require.NoError(t, err)
+		client := newClient(t, store)
+
+		_, err = client.Expand(ctx, &pb.ExpandRequest{ShortUrl: shortURL})
+
+		assertStatus(t, codes.FailedPrecondition, "url is deleted", err)
+	})
+
+	t.Run("expand protected url", func(t *testing.T) {
+		const password = "secret"
+		client := newClient(t, inmemory.New())
+		ctx := context.Background()
+		shortenResp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: testURL, Password: password})
+		require.NoError(t, err)
+		shortURL := shortenResp.GetResult()[len(baseURL)+1:]
+
+		_, err = client.Expand(ctx, &pb.ExpandRequest{ShortUrl: shortURL})
+		assertStatus(t, codes.PermissionDenied, service.ErrPasswordRequired.Error(), err)
+
+		_, err = client.Expand(ctx, &pb.ExpandRequest{ShortUrl: shortURL, Password: "wrong"})
+		assertStatus(t, codes.PermissionDenied, service.ErrPasswordIsInvalid.Error(), err)
+
+		resp, err := client.Expand(ctx, &pb.ExpandRequest{ShortUrl: shortURL, Password: password})
+		require.NoError(t, err)
+		assert.Equal(t, testURL, resp.GetOriginalUrl())
+	})
+
+	t.Run("list user urls", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		var header metadata.MD
+		shortenResp, err := client.Shorten(context.Background(), &pb.ShortenRequest{Url: testURL}, grpc.Header(&header))
+		require.NoError(t, err)
+		ctx := withToken(t, header)
+
+		resp, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+
+		require.NoError(t, err)
+		require.Len(t, resp.GetUrls(), 1)
+		assert.Equal(t, shortenResp.GetResult(), resp.GetUrls()[0].GetShortUrl())
+		assert.Equal(t, testURL, resp.GetUrls()[0].GetOriginalUrl())
+	})
+
+	t.Run("list user urls with tags", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		var header metadata.MD
+		req := &pb.ShortenRequest{Url: testURL, Tags: []string{"team", "promo"}}
+		_, err := client.Shorten(context.Background(), req, grpc.Header(&header))
+		require.NoError(t, err)
+		ctx := withToken(t, header)
+
+		resp, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+
+		require.NoError(t, err)
+		require.Len(t, resp.GetUrls(), 1)
+		assert.Equal(t, []string{"promo", "team"}, resp.GetUrls()[0].GetTags())
+	})
+
+	t.Run("list user urls by pages", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		var header metadata.MD
+		req := &pb.ShortenBatchRequest{
+			Items: []*pb.ShortenBatchRequest_Item{
+				{CorrelationId: "1", OriginalUrl: testURL},
+				{CorrelationId: "2", OriginalUrl: "https://ya.ru/"},
+				{CorrelationId: "3", OriginalUrl: "https://mail.ru/"},
+			},
+		}
+		_, err := client.ShortenBatch(context.Background(), req, grpc.Header(&header))
+		require.NoError(t, err)
+		ctx := withToken(t, header)
+
+		first, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Limit: 2})
+
+		require.NoError(t, err)
+		assert.Len(t, first.GetUrls(), 2)
+		require.NotEmpty(t, first.GetNextCursor())
+		second, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Limit: 2, Cursor: first.GetNextCursor()})
+		require.NoError(t, err)
+		assert.Len(t, second.GetUrls(), 1)
+		assert.Empty(t, second.GetNextCursor())
+	})
+
+	t.Run("list user urls with invalid limit", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		var header metadata.MD
+		_, err := client.Shorten(context.Background(), &pb.ShortenRequest{Url: testURL}, grpc.Header(&header))
+		require.NoError(t, err)
+		ctx := withToken(t, header)
+
+		_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Limit: -1})
+
+		assertStatus(t, codes.InvalidArgument, service.ErrLimitIsInvalid.Error(), err)
+	})
+
+	t.Run("list urls of new user", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		_, err := client.ListUserURLs(context.Background(), &pb.ListUserURLsRequest{})
+
+		assertStatus(t, codes.Unauthenticated, "unauthorized", err)
+	})
+
+	t.Run("list urls with invalid token", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+		a := auth.New("wrong_secret", time.Hour)
+		token, err := a.CreateToken(domain.NewUserID())
+		require.NoError(t, err)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), UserAuthMetadataKey, token)
+
+		_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+
+		assertStatus(t, codes.Unauthenticated, "unauthorized", err)
+	})
+
+	t.Run("delete user urls", func(t *testing.T) {
+		store := inmemory.New()
+		client := newClient(t, store)
+		var header metadata.MD
+		shortenResp, err := client.Shorten(context.Background(), &pb.ShortenRequest{Url: testURL}, grpc.Header(&header))
+		require.NoError(t, err)
+		ctx := withToken(t, header)
+		shortURL := shortenResp.GetResult()[len(baseURL)+1:]
+
+		resp, err := client.DeleteUserURLs(ctx, &pb.DeleteUserURLsRequest{ShortUrls: []string{shortURL}})
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.GetJobId())
+		_, err = store.GetOriginalURL(ctx, shortURL)
+		assert.ErrorIs(t, err, domain.ErrOriginalURLIsDeleted)
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		client := newClient(t, inmemory.New())
+
+		_, err := client.Ping(context.Background(), &pb.PingRequest{})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("store is not available", func(t *testing.T) {
+		store := &domain.URLStoreDelegate{
+			IsAvailableFunc: func(ctx context.Context) bool {
+				return false
+			},
+		}
+		client := newClient(t, store)
+
+		_, err := client.Ping(context.Background(), &pb.PingRequest{})
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func newClient(t *testing.T, store domain.URLStore, options ...Option) pb.ShortenerClient {
+	t.Helper()
+	const bufSize = 1024 * 1024
+	listener := bufconn.Listen(bufSize)
+	sut := New(store, baseURL, options...)
+	server := grpc.NewServer(grpc.UnaryInterceptor(sut.AuthInterceptor))
+	pb.RegisterShortenerServer(server, sut)
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+	})
+
+	return pb.NewShortenerClient(conn)
+}
+
+func withToken(t *testing.T, header metadata.MD) context.Context {
+	t.Helper()
+	values := header.Get(UserAuthMetadataKey)
+	require.Len(t, values, 1)
+	return metadata.AppendToOutgoingContext(context.Background(), UserAuthMetadataKey, values[0])
+}
+
+func assertStatus(t *testing.T, want codes.Code, message string, err error) {
+	t.Helper()
+	got, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, want, got.Code())
+	assert.Equal(t, message, got.Message())
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+const realIPHeader = "X-Real-IP"
+
+// ClientIP возвращает IP-адрес клиента. Заголовок X-Real-IP учитывается, только если соединение
+// установлено с прокси-сервера из доверенной подсети trustedProxy; иначе используется адрес соединения,
+// чтобы клиент не мог подменить свой адрес. Если адрес не удается разобрать, возвращается nil.
+func ClientIP(r *http.Request, trustedProxy *net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remoteIP := net.ParseIP(host)
+
+	if trustedProxy != nil && remoteIP != nil && trustedProxy.Contains(remoteIP) {
+		if ip := net.ParseIP(r.Header.Get(realIPHeader)); ip != nil {
+			return ip
+		}
+	}
+
+	return remoteIP
+}
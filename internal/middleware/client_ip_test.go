@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	_, trustedProxy, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		proxy      *net.IPNet
+		want       string
+	}{
+		{
+			name:       "remote address",
+			remoteAddr: "203.0.113.7:1234",
+			proxy:      trustedProxy,
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "real ip from trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "203.0.113.7",
+			proxy:      trustedProxy,
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "real ip from untrusted address",
+			remoteAddr: "198.51.100.1:1234",
+			realIP:     "203.0.113.7",
+			proxy:      trustedProxy,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "real ip without trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "203.0.113.7",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "invalid real ip from trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "invalid",
+			proxy:      trustedProxy,
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "remote address without port",
+			remoteAddr: "203.0.113.7",
+			want:       "203.0.113.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				request.Header.Set(realIPHeader, tt.realIP)
+			}
+
+			got := ClientIP(request, tt.proxy)
+
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
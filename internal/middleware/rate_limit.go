@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+)
+
+// Заголовки ответа с состоянием ограничения частоты запросов.
+const (
+	retryAfterHeader         = "Retry-After"
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// ErrRateIsInvalid возвращается, если ограничение частоты запросов задано в неверном формате.
+var ErrRateIsInvalid = errors.New("rate must be in format <requests>/<period>, e.g. 10/1m")
+
+// Rate задает ограничение частоты запросов по алгоритму корзины токенов: корзина вмещает Requests токенов
+// и полностью пополняется за Period. Нулевое значение означает отсутствие ограничения.
+type Rate struct {
+	Period   time.Duration // время полного пополнения корзины
+	Requests int           // емкость корзины: количество запросов, которые можно выполнить подряд
+}
+
+// ParseRate разбирает ограничение частоты запросов в формате <requests>/<period>, где period задается
+// в формате time.ParseDuration, например 10/1m. Для пустой строки возвращается нулевое значение.
+func ParseRate(s string) (Rate, error) {
+	if s == "" {
+		return Rate{}, nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+
+	if !ok {
+		return Rate{}, ErrRateIsInvalid
+	}
+
+	count, err := strconv.Atoi(requests)
+
+	if err != nil || count <= 0 {
+		return Rate{}, ErrRateIsInvalid
+	}
+
+	duration, err := time.ParseDuration(period)
+
+	if err != nil || duration <= 0 {
+		return Rate{}, ErrRateIsInvalid
+	}
+
+	return Rate{Requests: count, Period: duration}, nil
+}
+
+// IsZero возвращает true, если ограничение не задано.
+func (r Rate) IsZero() bool {
+	return r.Requests <= 0 || r.Period <= 0
+}
+
+// perSecond возвращает скорость пополнения корзины в токенах в секунду.
+func (r Rate) perSecond() float64 {
+	return float64(r.Requests) / r.Period.Seconds()
+}
+
+// RateLimitResult содержит результат проверки ограничения частоты запросов.
+type RateLimitResult struct {
+	Reset      time.Duration // время до полного пополнения корзины
+	RetryAfter time.Duration // время до появления токена; задано, если запрос отклонен
+	Limit      int           // емкость корзины
+	Remaining  int           // количество запросов, которые можно выполнить сейчас
+	Allowed    bool          // признак разрешенного запроса
+}
+
+// RateLimiter ограничивает частоту запросов по ключам с помощью корзин токенов. Корзины, которые
+// полностью пополнились, периодически удаляются.
+type RateLimiter struct {
+	lastSweep time.Time
+	buckets   map[string]bucket
+	rate      Rate
+	mu        sync.Mutex
+}
+
+type bucket struct {
+	updatedAt time.Time
+	tokens    float64
+}
+
+// NewRateLimiter создает ограничитель частоты запросов.
+func NewRateLimiter(rate Rate) *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]bucket),
+		rate:    rate,
+	}
+}
+
+// Take берет по токену из корзины каждого ключа. Запрос разрешается, только если токен есть во всех
+// корзинах; иначе токены не расходуются. Результат описывает корзину с наименьшим запасом.
+func (l *RateLimiter) Take(now time.Time, keys ...string) RateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	capacity := float64(l.rate.Requests)
+	buckets := make([]bucket, len(keys))
+	allowed := true
+
+	for i, key := range keys {
+		buckets[i] = l.refill(key, now)
+		allowed = allowed && buckets[i].tokens >= 1
+	}
+
+	result := RateLimitResult{Limit: l.rate.Requests, Remaining: l.rate.Requests, Allowed: allowed}
+	for i, key := range keys {
+		b := buckets[i]
+
+		if allowed {
+			b.tokens--
+			l.buckets[key] = b
+		} else if b.tokens < 1 {
+			result.RetryAfter = max(result.RetryAfter, l.timeToRefill(1-b.tokens))
+		}
+
+		result.Remaining = min(result.Remaining, int(math.Floor(b.tokens)))
+		result.Reset = max(result.Reset, l.timeToRefill(capacity-b.tokens))
+	}
+
+	return result
+}
+
+// refill возвращает корзину ключа, пополненную на момент now.
+func (l *RateLimiter) refill(key string, now time.Time) bucket {
+	capacity := float64(l.rate.Requests)
+	b, ok := l.buckets[key]
+
+	if !ok {
+		return bucket{tokens: capacity, updatedAt: now}
+	}
+
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*l.rate.perSecond())
+		b.updatedAt = now
+	}
+
+	return b
+}
+
+func (l *RateLimiter) timeToRefill(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / l.rate.perSecond() * float64(time.Second))
+}
+
+// sweep удаляет полностью пополнившиеся корзины не чаще одного раза за период пополнения.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.rate.Period {
+		return
+	}
+
+	l.lastSweep = now
+	capacity := float64(l.rate.Requests)
+
+	for key := range l.buckets {
+		if l.refill(key, now).tokens >= capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimit возвращает посредника, который ограничивает частоту запросов для пользователя и для IP-адреса
+// клиента. Пользователь берется из контекста запроса, если он добавлен туда посредником Auth и не является
+// новым. IP-адрес клиента определяется функцией ClientIP с доверенной подсетью прокси-серверов trustedProxy.
+// Отклоненные запросы завершаются ответом 429 с заголовком Retry-After; состояние ограничения передается
+// в заголовках RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset.
+func RateLimit(limiter *RateLimiter, trustedProxy *net.IPNet) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		limit := func(w http.ResponseWriter, r *http.Request) {
+			result := limiter.Take(time.Now(), rateLimitKeys(r, trustedProxy)...)
+
+			w.Header().Set(rateLimitLimitHeader, strconv.Itoa(result.Limit))
+			w.Header().Set(rateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			w.Header().Set(rateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				w.Header().Set(retryAfterHeader, strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(limit)
+	}
+}
+
+// rateLimitKeys возвращает ключи корзин запроса: IP-адрес клиента и, если он известен, идентификатор
+// пользователя.
+func rateLimitKeys(r *http.Request, trustedProxy *net.IPNet) []string {
+	keys := []string{"ip:" + ClientIP(r, trustedProxy).String()}
+
+	if user, ok := customctx.GetUser(r.Context()); ok && !user.IsNew {
+		keys = append(keys, "user:"+uuid.UUID(user.ID).String())
+	}
+
+	return keys
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
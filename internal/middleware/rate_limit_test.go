@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want Rate
+	}{
+		{
+			name: "requests per minute",
+			s:    "10/1m",
+			want: Rate{Requests: 10, Period: time.Minute},
+		},
+		{
+			name: "requests per second",
+			s:    "100/1s",
+			want: Rate{Requests: 100, Period: time.Second},
+		},
+		{
+			name: "empty",
+			s:    "",
+			want: Rate{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRate(tt.s)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("invalid rate", func(t *testing.T) {
+		for _, s := range []string{"10", "x/1m", "0/1m", "-1/1m", "10/m", "10/0s"} {
+			_, err := ParseRate(s)
+
+			assert.ErrorIs(t, err, ErrRateIsInvalid, s)
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	rate := Rate{Requests: 2, Period: 10 * time.Second}
+
+	t.Run("take tokens until bucket is empty", func(t *testing.T) {
+		sut := NewRateLimiter(rate)
+
+		got := sut.Take(now, "a")
+		assert.Equal(t, RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}, got)
+
+		got = sut.Take(now, "a")
+		assert.Equal(t, RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second}, got)
+
+		got = sut.Take(now, "a")
+		assert.False(t, got.Allowed)
+		assert.Equal(t, 0, got.Remaining)
+		assert.Equal(t, 5*time.Second, got.RetryAfter)
+	})
+
+	t.Run("bucket is refilled over time", func(t *testing.T) {
+		sut := NewRateLimiter(rate)
+		sut.Take(now, "a")
+		sut.Take(now, "a")
+
+		got := sut.Take(now.Add(5*time.Second), "a")
+		assert.True(t, got.Allowed)
+
+		got = sut.Take(now.Add(5*time.Second), "a")
+		assert.False(t, got.Allowed)
+	})
+
+	t.Run("buckets of keys are independent", func(t *testing.T) {
+		sut := NewRateLimiter(rate)
+		sut.Take(now, "a")
+		sut.Take(now, "a")
+
+		got := sut.Take(now, "b")
+
+		assert.True(t, got.Allowed)
+	})
+
+	t.Run("tokens are not taken if one of buckets is empty", func(t *testing.T) {
+		sut := NewRateLimiter(rate)
+		sut.Take(now, "a")
+		sut.Take(now, "a")
+
+		got := sut.Take(now, "a", "b")
+		assert.False(t, got.Allowed)
+
+		got = sut.Take(now, "b")
+		assert.Equal(t, 1, got.Remaining)
+	})
+
+	t.Run("full buckets are swept", func(t *testing.T) {
+		sut := NewRateLimiter(rate)
+		sut.Take(now, "a")
+		sut.Take(now.Add(8*time.Second), "b")
+
+		sut.Take(now.Add(rate.Period+time.Second), "c")
+
+		assert.Len(t, sut.buckets, 2)
+		assert.NotContains(t, sut.buckets, "a")
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	okHandlerFunc := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	rate := Rate{Requests: 1, Period: time.Hour}
+	_, trustedProxy, _ := net.ParseCIDR("192.0.2.0/24")
+
+	t.Run("too many requests from ip", func(t *testing.T) {
+		sut := RateLimit(NewRateLimiter(rate), trustedProxy)(okHandlerFunc)
+
+		response := httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.1", nil))
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "1", response.Header().Get(rateLimitLimitHeader))
+		assert.Equal(t, "0", response.Header().Get(rateLimitRemainingHeader))
+		assert.Equal(t, "3600", response.Header().Get(rateLimitResetHeader))
+		assert.Empty(t, response.Header().Get(retryAfterHeader))
+
+		response = httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.1", nil))
+
+		assert.Equal(t, http.StatusTooManyRequests, response.Code)
+		assert.Equal(t, "3600", response.Header().Get(retryAfterHeader))
+		assert.Equal(t, "0", response.Header().Get(rateLimitRemainingHeader))
+
+		response = httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.2", nil))
+
+		assert.Equal(t, http.StatusOK, response.Code)
+	})
+
+	t.Run("too many requests from user", func(t *testing.T) {
+		sut := RateLimit(NewRateLimiter(rate), trustedProxy)(okHandlerFunc)
+		user := customctx.NewUser(domain.NewUserID(), false)
+
+		response := httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.1", &user))
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		response = httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.2", &user))
+		assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	})
+
+	t.Run("new user is limited by ip only", func(t *testing.T) {
+		sut := RateLimit(NewRateLimiter(rate), trustedProxy)(okHandlerFunc)
+
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			user := customctx.NewUser(domain.NewUserID(), true)
+			response := httptest.NewRecorder()
+			sut.ServeHTTP(response, newRateLimitRequest(ip, &user))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+		}
+	})
+
+	t.Run("ip is taken from remote address", func(t *testing.T) {
+		sut := RateLimit(NewRateLimiter(rate), trustedProxy)(okHandlerFunc)
+
+		response := httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("", nil))
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		response = httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("", nil))
+		assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	})
+
+	t.Run("real ip header is ignored without trusted proxy", func(t *testing.T) {
+		sut := RateLimit(NewRateLimiter(rate), nil)(okHandlerFunc)
+
+		response := httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.1", nil))
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		response = httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.2", nil))
+		assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	})
+
+	t.Run("real ip header is ignored from untrusted address", func(t *testing.T) {
+		_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+		sut := RateLimit(NewRateLimiter(rate), subnet)(okHandlerFunc)
+
+		response := httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.1", nil))
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		response = httptest.NewRecorder()
+		sut.ServeHTTP(response, newRateLimitRequest("10.0.0.2", nil))
+		assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	})
+}
+
+func newRateLimitRequest(realIP string, user *customctx.User) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if realIP != "" {
+		request.Header.Set(realIPHeader, realIP)
+	}
+
+	if user != nil {
+		request = request.WithContext(customctx.SetUser(request.Context(), *user))
+	}
+
+	return request
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// TrustedSubnet возвращает посредника, который пропускает только запросы из доверенной подсети.
+// IP-адрес клиента передается в заголовке X-Real-IP. Если доверенная подсеть не задана,
+// все запросы отклоняются.
+func TrustedSubnet(subnet *net.IPNet) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		check := func(w http.ResponseWriter, r *http.Request) {
+			if subnet == nil {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			ip := net.ParseIP(r.Header.Get(realIPHeader))
+
+			if ip == nil || !subnet.Contains(ip) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(check)
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		subnet *net.IPNet
+		name   string
+		realIP string
+		want   int
+	}{
+		{
+			name:   "ip in trusted subnet",
+			subnet: subnet,
+			realIP: "192.168.1.10",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "ip is not in trusted subnet",
+			subnet: subnet,
+			realIP: "10.0.0.1",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "ip is not set",
+			subnet: subnet,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "ip is invalid",
+			subnet: subnet,
+			realIP: "192.168.1",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "trusted subnet is not set",
+			realIP: "192.168.1.10",
+			want:   http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.realIP != "" {
+				request.Header.Set(realIPHeader, tt.realIP)
+			}
+			response := httptest.NewRecorder()
+			okHandlerFunc := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			sut := TrustedSubnet(tt.subnet)(okHandlerFunc)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tt.want, response.Code)
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,21 +16,174 @@ import (
 
 // FileURLStore реализует хранилище ссылок на основе файла.
 type FileURLStore struct {
-	encoder *json.Encoder
-	m       map[string]StoredURL
-	mu      sync.Mutex
+	encoder      *json.Encoder
+	clicksRW     io.ReadWriter
+	clickEncoder *json.Encoder
+	m            map[string]StoredURL
+	clicks       map[string][]domain.Click
+	mu           sync.Mutex
+}
+
+// Option определяет опцию настройки файлового хранилища.
+type Option func(*FileURLStore)
+
+// WithClicks задает источник, в котором сохраняются переходы по сокращенным ссылкам.
+// Если источник не задан, переходы хранятся только в памяти.
+func WithClicks(rw io.ReadWriter) Option {
+	return func(s *FileURLStore) {
+		s.clicksRW = rw
+	}
+}
+
+// StoredClick описывает данные перехода по сокращенной ссылке.
+type StoredClick struct {
+	Timestamp time.Time `json:"timestamp"`         // время перехода
+	ShortURL  string    `json:"short_url"`         // сокращенный URL
+	Referrer  string    `json:"referrer"`          // адрес страницы, с которой выполнен переход
+	UserAgent string    `json:"user_agent"`        // клиент пользователя
+	IP        string    `json:"ip"`                // анонимизированный IP-адрес пользователя
+	Variant   string    `json:"variant,omitempty"` // имя варианта исходного URL
+}
+
+// StoredDestination описывает вариант исходного URL сокращенной ссылки.
+type StoredDestination struct {
+	Name   string `json:"name"`   // имя варианта
+	URL    string `json:"url"`    // исходный URL варианта
+	Weight int    `json:"weight"` // вес варианта
+}
+
+// StoredRoutingRule описывает правило выбора исходного URL сокращенной ссылки.
+type StoredRoutingRule struct {
+	OS       string `json:"os,omitempty"`       // операционная система клиента
+	Device   string `json:"device,omitempty"`   // класс устройства клиента
+	Language string `json:"language,omitempty"` // язык клиента
+	URL      string `json:"url"`                // исходный URL правила
 }
 
 // StoredURL описывает данные сокращенной ссылки.
 type StoredURL struct {
-	ShortURL    string        `json:"short_url"`    // сокращенный URL
-	OriginalURL string        `json:"original_url"` // исходный URL
-	UserID      domain.UserID `json:"user_id"`      // идентификатор пользователя
-	IsDeleted   bool          `json:"is_deleted"`   // признак удаленной ссылки
+	ShortURL       string              `json:"short_url"`                 // сокращенный URL
+	OriginalURL    string              `json:"original_url"`              // исходный URL
+	CanonicalURL   string              `json:"canonical_url,omitempty"`   // канонический вид исходного URL
+	UserID         domain.UserID       `json:"user_id"`                   // идентификатор пользователя
+	IsDeleted      bool                `json:"is_deleted"`                // признак удаленной ссылки
+	ActiveFrom     time.Time           `json:"active_from"`               // время начала действия ссылки
+	ExpiresAt      time.Time           `json:"expires_at"`                // время окончания действия ссылки
+	CreatedAt      time.Time           `json:"created_at"`                // время создания ссылки
+	PasswordHash   string              `json:"password_hash,omitempty"`   // хеш пароля для перехода по ссылке
+	Title          string              `json:"title,omitempty"`           // заголовок ссылки, заданный владельцем
+	Tags           []string            `json:"tags,omitempty"`            // метки ссылки, заданные владельцем
+	MaxClicks      int                 `json:"max_clicks,omitempty"`      // максимальное количество переходов по ссылке
+	ClicksLeft     int                 `json:"clicks_left,omitempty"`     // оставшееся количество переходов по ссылке
+	Destinations   []StoredDestination `json:"destinations,omitempty"`    // варианты исходного URL
+	Rotation       string              `json:"rotation,omitempty"`        // режим выбора варианта
+	Rules          []StoredRoutingRule `json:"rules,omitempty"`           // правила выбора исходного URL по клиенту
+	ForwardPath    bool                `json:"forward_path,omitempty"`    // признак передачи суффикса пути в исходный URL
+	RedirectStatus int                 `json:"redirect_status,omitempty"` // код ответа перенаправления
+}
+
+func newStoredURL(pair domain.URLPair, userID domain.UserID) StoredURL {
+	return StoredURL{
+		ShortURL:       pair.ShortURL,
+		OriginalURL:    pair.OriginalURL,
+		CanonicalURL:   pair.CanonicalURL,
+		ActiveFrom:     pair.ActiveFrom,
+		ExpiresAt:      pair.ExpiresAt,
+		CreatedAt:      pair.WithCreatedAt(time.Now()).CreatedAt,
+		UserID:         userID,
+		PasswordHash:   pair.PasswordHash,
+		Title:          pair.Title,
+		Tags:           slices.Clone(pair.Tags),
+		MaxClicks:      pair.MaxClicks,
+		ClicksLeft:     pair.ClicksLeft,
+		Destinations:   newStoredDestinations(pair.Destinations),
+		Rotation:       pair.Rotation,
+		Rules:          newStoredRoutingRules(pair.Rules),
+		ForwardPath:    pair.ForwardPath,
+		RedirectStatus: pair.RedirectStatus,
+	}
+}
+
+func (s StoredURL) toURLPair() domain.URLPair {
+	return domain.URLPair{
+		ShortURL:       s.ShortURL,
+		OriginalURL:    s.OriginalURL,
+		CanonicalURL:   s.CanonicalURL,
+		CreatedAt:      s.CreatedAt,
+		ActiveFrom:     s.ActiveFrom,
+		ExpiresAt:      s.ExpiresAt,
+		PasswordHash:   s.PasswordHash,
+		Title:          s.Title,
+		Tags:           slices.Clone(s.Tags),
+		MaxClicks:      s.MaxClicks,
+		ClicksLeft:     s.ClicksLeft,
+		Destinations:   toDestinations(s.Destinations),
+		Rotation:       s.Rotation,
+		Rules:          toRoutingRules(s.Rules),
+		ForwardPath:    s.ForwardPath,
+		RedirectStatus: s.RedirectStatus,
+	}
+}
+
+func newStoredDestinations(destinations []domain.Destination) []StoredDestination {
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	stored := make([]StoredDestination, len(destinations))
+	for i, d := range destinations {
+		stored[i] = StoredDestination(d)
+	}
+
+	return stored
+}
+
+func toDestinations(stored []StoredDestination) []domain.Destination {
+	if len(stored) == 0 {
+		return nil
+	}
+
+	destinations := make([]domain.Destination, len(stored))
+	for i, d := range stored {
+		destinations[i] = domain.Destination(d)
+	}
+
+	return destinations
+}
+
+func newStoredRoutingRules(rules []domain.RoutingRule) []StoredRoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	stored := make([]StoredRoutingRule, len(rules))
+	for i, r := range rules {
+		stored[i] = StoredRoutingRule(r)
+	}
+
+	return stored
+}
+
+func toRoutingRules(stored []StoredRoutingRule) []domain.RoutingRule {
+	if len(stored) == 0 {
+		return nil
+	}
+
+	rules := make([]domain.RoutingRule, len(stored))
+	for i, r := range stored {
+		rules[i] = domain.RoutingRule(r)
+	}
+
+	return rules
+}
+
+// canonical возвращает канонический вид исходного URL.
+func (s StoredURL) canonical() string {
+	return domain.URLPair{OriginalURL: s.OriginalURL, CanonicalURL: s.CanonicalURL}.Canonical()
 }
 
 // New создает экземпляр файлового хранилища.
-func New(ctx context.Context, rw io.ReadWriter) (*FileURLStore, error) {
+func New(ctx context.Context, rw io.ReadWriter, options ...Option) (*FileURLStore, error) {
 	const op = "new file storage"
 	m, err := readURLs(rw)
 
@@ -39,10 +194,44 @@ func New(ctx context.Context, rw io.ReadWriter) (*FileURLStore, error) {
 	store := FileURLStore{
 		encoder: json.NewEncoder(rw),
 		m:       m,
+		clicks:  make(map[string][]domain.Click),
+	}
+
+	for _, opt := range options {
+		opt(&store)
 	}
+
+	if store.clicksRW != nil {
+		store.clicks, err = readClicks(store.clicksRW)
+
+		if err != nil {
+			return nil, errors.Wrap(err, op)
+		}
+
+		store.clickEncoder = json.NewEncoder(store.clicksRW)
+	}
+
 	return &store, nil
 }
 
+func readClicks(r io.Reader) (map[string][]domain.Click, error) {
+	dec := json.NewDecoder(r)
+	m := make(map[string][]domain.Click)
+
+	for dec.More() {
+		var rec StoredClick
+		err := dec.Decode(&rec)
+
+		if err != nil {
+			return nil, fmt.Errorf("get clicks: %w", err)
+		}
+
+		m[rec.ShortURL] = append(m[rec.ShortURL], domain.Click(rec))
+	}
+
+	return m, nil
+}
+
 func readURLs(rw io.ReadWriter) (map[string]StoredURL, error) {
 	dec := json.NewDecoder(rw)
 	m := make(map[string]StoredURL)
@@ -56,7 +245,7 @@ func readURLs(rw io.ReadWriter) (map[string]StoredURL, error) {
 		}
 
 		if _, ok := m[rec.ShortURL]; !ok {
-			if shortURL, ok := findShortURL(m, rec.OriginalURL); ok {
+			if shortURL, ok := findShortURL(m, rec.canonical()); ok {
 				return nil, domain.NewOriginalURLExistsError(shortURL, nil)
 			}
 		}
@@ -69,20 +258,41 @@ func readURLs(rw io.ReadWriter) (map[string]StoredURL, error) {
 
 // GetOriginalURL возвращает исходный URL для сокращенного URL или ошибку.
 func (u *FileURLStore) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+	pair, err := u.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	return pair.OriginalURL, nil
+}
+
+// GetURL возвращает пару исходного и сокращенного URL или ошибку.
+func (u *FileURLStore) GetURL(ctx context.Context, shortURL string) (domain.URLPair, error) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
 	rec, ok := u.m[shortURL]
 
 	if !ok {
-		return "", domain.ErrOriginalURLNotFound
+		return domain.URLPair{}, domain.ErrOriginalURLNotFound
+	}
+
+	// Истекшие URL удаляются при очистке, поэтому срок действия проверяется раньше признака удаления.
+	pair := rec.toURLPair()
+	if pair.IsExpired(time.Now()) {
+		return domain.URLPair{}, domain.ErrOriginalURLIsExpired
 	}
 
 	if rec.IsDeleted {
-		return "", domain.ErrOriginalURLIsDeleted
+		return domain.URLPair{}, domain.ErrOriginalURLIsDeleted
+	}
+
+	if pair.IsExhausted() {
+		return domain.URLPair{}, domain.ErrOriginalURLIsExhausted
 	}
 
-	return rec.OriginalURL, nil
+	return pair, nil
 }
 
 // AddURL добавляет в хранилище пару исходный и сокращенный URL.
@@ -91,15 +301,15 @@ func (u *FileURLStore) AddURL(ctx context.Context, pair domain.URLPair, userID d
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	if shortURL, ok := findShortURL(u.m, pair.OriginalURL); ok {
+	if shortURL, ok := findShortURL(u.m, pair.Canonical()); ok {
 		return domain.NewOriginalURLExistsError(shortURL, nil)
 	}
 
-	rec := StoredURL{
-		ShortURL:    pair.ShortURL,
-		OriginalURL: pair.OriginalURL,
-		UserID:      userID,
+	if _, ok := u.m[pair.ShortURL]; ok {
+		return domain.NewShortURLExistsError(pair.ShortURL, nil)
 	}
+
+	rec := newStoredURL(pair, userID)
 	u.m[rec.ShortURL] = rec
 
 	err := u.encoder.Encode(rec)
@@ -111,9 +321,10 @@ func (u *FileURLStore) AddURL(ctx context.Context, pair domain.URLPair, userID d
 	return nil
 }
 
-func findShortURL(m map[string]StoredURL, originalURL string) (string, bool) {
+// findShortURL возвращает сокращенный URL для исходного URL с указанным каноническим видом.
+func findShortURL(m map[string]StoredURL, canonicalURL string) (string, bool) {
 	for k, v := range m {
-		if v.OriginalURL == originalURL {
+		if v.canonical() == canonicalURL {
 			return k, true
 		}
 	}
@@ -121,17 +332,19 @@ func findShortURL(m map[string]StoredURL, originalURL string) (string, bool) {
 	return "", false
 }
 
-// AddURLs добавляет в хранилище коллекцию пар исходного и сокращенного URL.
+// AddURLs добавляет в хранилище коллекцию пар исходного и сокращенного URL. Если сокращенный или исходный URL
+// какой-либо пары уже сохранен, коллекция не добавляется и возвращается ошибка domain.ShortURLExistsError
+// или domain.OriginalURLExistsError.
 func (u *FileURLStore) AddURLs(ctx context.Context, pairs []domain.URLPair, userID domain.UserID) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
+	if err := findConflict(u.m, pairs); err != nil {
+		return err
+	}
+
 	for _, url := range pairs {
-		rec := StoredURL{
-			ShortURL:    url.ShortURL,
-			OriginalURL: url.OriginalURL,
-			UserID:      userID,
-		}
+		rec := newStoredURL(url, userID)
 		u.m[rec.ShortURL] = rec
 
 		err := u.encoder.Encode(rec)
@@ -143,6 +356,59 @@ func (u *FileURLStore) AddURLs(ctx context.Context, pairs []domain.URLPair, user
 	return nil
 }
 
+// findConflict возвращает ошибку, если сокращенный или исходный URL какой-либо пары уже сохранен
+// или повторяется в коллекции.
+func findConflict(m map[string]StoredURL, pairs []domain.URLPair) error {
+	originals := make(map[string]string, len(m))
+	for k, v := range m {
+		originals[v.canonical()] = k
+	}
+
+	keys := make(map[string]struct{}, len(pairs))
+	for _, pair := range pairs {
+		if _, ok := keys[pair.ShortURL]; ok {
+			return domain.NewShortURLExistsError(pair.ShortURL, nil)
+		}
+
+		if _, ok := m[pair.ShortURL]; ok {
+			return domain.NewShortURLExistsError(pair.ShortURL, nil)
+		}
+
+		if shortURL, ok := originals[pair.Canonical()]; ok {
+			return domain.NewOriginalURLExistsError(shortURL, nil)
+		}
+
+		keys[pair.ShortURL] = struct{}{}
+		originals[pair.Canonical()] = pair.ShortURL
+	}
+
+	return nil
+}
+
+// FindConflicts возвращает статус конфликта с сохраненными URL для каждой конфликтующей пары:
+// domain.KeyTaken, если сокращенный URL пары занят, и domain.KeyURLExists, если исходный URL уже сокращен.
+func (u *FileURLStore) FindConflicts(ctx context.Context, pairs []domain.URLPair) (map[string]domain.KeyStatus,
+	error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	originals := make(map[string]struct{}, len(u.m))
+	for _, rec := range u.m {
+		originals[rec.canonical()] = struct{}{}
+	}
+
+	conflicts := make(map[string]domain.KeyStatus)
+	for _, pair := range pairs {
+		if _, ok := u.m[pair.ShortURL]; ok {
+			conflicts[pair.ShortURL] = domain.KeyTaken
+		} else if _, ok := originals[pair.Canonical()]; ok {
+			conflicts[pair.ShortURL] = domain.KeyURLExists
+		}
+	}
+
+	return conflicts, nil
+}
+
 // IsAvailable позволяет проверить доступность хранилща.
 func (u *FileURLStore) IsAvailable(ctx context.Context) bool {
 	return true
@@ -150,50 +416,364 @@ func (u *FileURLStore) IsAvailable(ctx context.Context) bool {
 
 // GetUserURLs возвращает коллекцию пар исходного и сокращенного URL, которые были добавлены указанным пользователем.
 func (u *FileURLStore) GetUserURLs(ctx context.Context, userID domain.UserID) ([]domain.URLPair, error) {
+	return u.userURLs(userID, false), nil
+}
+
+// GetDeletedUserURLs возвращает коллекцию удаленных пар исходного и сокращенного URL,
+// которые были добавлены указанным пользователем. Истекшие URL не возвращаются.
+func (u *FileURLStore) GetDeletedUserURLs(ctx context.Context, userID domain.UserID) ([]domain.URLPair, error) {
+	return u.userURLs(userID, true), nil
+}
+
+func (u *FileURLStore) userURLs(userID domain.UserID, isDeleted bool) []domain.URLPair {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
 	userURLs := []domain.URLPair{}
+	now := time.Now()
 
-	for k, v := range u.m {
-		if v.UserID != userID || v.IsDeleted {
+	for _, v := range u.m {
+		if v.UserID != userID || v.IsDeleted != isDeleted {
 			continue
 		}
 
-		url := domain.URLPair{
-			ShortURL:    k,
-			OriginalURL: v.OriginalURL,
+		pair := v.toURLPair()
+		if isDeleted && pair.IsExpired(now) {
+			continue
+		}
+
+		userURLs = append(userURLs, pair)
+	}
+
+	return userURLs
+}
+
+// GetUserURLsPage возвращает страницу URL, сокращенных указанным пользователем.
+func (u *FileURLStore) GetUserURLsPage(ctx context.Context, userID domain.UserID,
+	query domain.UserURLsQuery) (domain.URLPage, error) {
+	userURLs, err := u.GetUserURLs(ctx, userID)
+
+	if err != nil {
+		return domain.URLPage{}, err
+	}
+
+	return domain.NewURLPage(userURLs, query), nil
+}
+
+// WalkUserURLs обходит все URL, сокращенные указанным пользователем, включая удаленные, в порядке создания.
+// Функция обхода вызывается без блокировки хранилища.
+func (u *FileURLStore) WalkUserURLs(ctx context.Context, userID domain.UserID, visit domain.URLVisitor) error {
+	u.mu.Lock()
+	var recs []StoredURL
+	for _, rec := range u.m {
+		if rec.UserID == userID {
+			recs = append(recs, rec)
 		}
-		userURLs = append(userURLs, url)
+	}
+	u.mu.Unlock()
+
+	slices.SortFunc(recs, func(a, b StoredURL) int {
+		return domain.CompareURLPairs(a.toURLPair(), b.toURLPair())
+	})
+
+	for _, rec := range recs {
+		if err := visit(rec.toURLPair(), rec.IsDeleted); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateOriginalURL заменяет исходный URL и его канонический вид для сокращенного URL, добавленного
+// указанным пользователем.
+func (u *FileURLStore) UpdateOriginalURL(ctx context.Context, shortURL, originalURL, canonicalURL string,
+	userID domain.UserID) error {
+	pair := domain.URLPair{OriginalURL: originalURL, CanonicalURL: canonicalURL}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rec, ok := u.m[shortURL]
+
+	if !ok {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	if rec.UserID != userID {
+		return domain.ErrOriginalURLNotOwned
+	}
+
+	if rec.IsDeleted {
+		return domain.ErrOriginalURLIsDeleted
 	}
 
-	return userURLs, nil
+	if existing, ok := findShortURL(u.m, pair.Canonical()); ok && existing != shortURL {
+		return domain.NewOriginalURLExistsError(existing, nil)
+	}
+
+	rec.OriginalURL = pair.OriginalURL
+	rec.CanonicalURL = pair.CanonicalURL
+	u.m[shortURL] = rec
+
+	if err := u.encoder.Encode(rec); err != nil {
+		return errors.Wrap(err, "failed write url")
+	}
+
+	return nil
+}
+
+// UpdateTags заменяет метки сокращенного URL, добавленного указанным пользователем.
+func (u *FileURLStore) UpdateTags(ctx context.Context, shortURL string, tags []string,
+	userID domain.UserID) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rec, ok := u.m[shortURL]
+
+	if !ok {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	if rec.UserID != userID {
+		return domain.ErrOriginalURLNotOwned
+	}
+
+	if rec.IsDeleted {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	rec.Tags = slices.Clone(tags)
+	u.m[shortURL] = rec
+
+	if err := u.encoder.Encode(rec); err != nil {
+		return errors.Wrap(err, "failed write url")
+	}
+
+	return nil
+}
+
+// UpdateRoutingRules заменяет правила выбора исходного URL сокращенного URL, добавленного указанным пользователем.
+func (u *FileURLStore) UpdateRoutingRules(ctx context.Context, shortURL string, rules []domain.RoutingRule,
+	userID domain.UserID) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rec, ok := u.m[shortURL]
+
+	if !ok {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	if rec.UserID != userID {
+		return domain.ErrOriginalURLNotOwned
+	}
+
+	if rec.IsDeleted {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	rec.Rules = newStoredRoutingRules(rules)
+	u.m[shortURL] = rec
+
+	if err := u.encoder.Encode(rec); err != nil {
+		return errors.Wrap(err, "failed write url")
+	}
+
+	return nil
 }
 
 // DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
-// которые были добавлены указанным пользователем.
-func (u *FileURLStore) DeleteUserURLs(ctx context.Context, shortURLs []string, userID domain.UserID) error {
+// которые были добавлены указанным пользователем. Возвращает результат удаления каждого URL.
+func (u *FileURLStore) DeleteUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	return u.setDeleted(shortURLs, userID, true, domain.KeyDeleted)
+}
+
+// RestoreUserURLs восстанавливает удаленные сокращенные URL, которые были добавлены указанным пользователем.
+// Возвращает результат восстановления каждого URL. Истекшие URL не восстанавливаются и считаются ненайденными.
+func (u *FileURLStore) RestoreUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	return u.setDeleted(shortURLs, userID, false, domain.KeyRestored)
+}
+
+// setDeleted устанавливает признак удаления сокращенным URL, добавленным указанным пользователем.
+func (u *FileURLStore) setDeleted(shortURLs []string, userID domain.UserID, isDeleted bool,
+	done domain.KeyStatus) ([]domain.KeyResult, error) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
+	owners := make(map[string]domain.UserID, len(shortURLs))
+	now := time.Now()
+
 	for _, shortURL := range shortURLs {
 		rec, ok := u.m[shortURL]
 
-		if !ok {
+		if !ok || !isDeleted && rec.toURLPair().IsExpired(now) {
 			continue
 		}
 
+		owners[shortURL] = rec.UserID
 		if rec.UserID == userID {
-			rec.IsDeleted = true
+			rec.IsDeleted = isDeleted
 			u.m[shortURL] = rec
 
 			err := u.encoder.Encode(rec)
 
 			if err != nil {
-				return errors.Wrap(err, "failed write url")
+				return nil, errors.Wrap(err, "failed write url")
 			}
 		}
 	}
 
+	return domain.NewKeyResults(shortURLs, owners, userID, done), nil
+}
+
+// DeleteExpiredURLs удаляет из хранилища сокращенные URL, срок действия которых истек к указанному моменту.
+func (u *FileURLStore) DeleteExpiredURLs(ctx context.Context, now time.Time) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for shortURL, rec := range u.m {
+		if rec.IsDeleted || !rec.toURLPair().IsExpired(now) {
+			continue
+		}
+
+		rec.IsDeleted = true
+		u.m[shortURL] = rec
+
+		err := u.encoder.Encode(rec)
+
+		if err != nil {
+			return errors.Wrap(err, "failed write url")
+		}
+	}
+
+	return nil
+}
+
+// ConsumeClick списывает переход по сокращенному URL с ограниченным количеством переходов.
+// Если переходы исчерпаны, возвращается ошибка domain.ErrOriginalURLIsExhausted.
+func (u *FileURLStore) ConsumeClick(ctx context.Context, shortURL string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rec, ok := u.m[shortURL]
+
+	if !ok {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	if rec.IsDeleted {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	if rec.MaxClicks <= 0 {
+		return nil
+	}
+
+	if rec.ClicksLeft <= 0 {
+		return domain.ErrOriginalURLIsExhausted
+	}
+
+	rec.ClicksLeft--
+	u.m[shortURL] = rec
+
+	if err := u.encoder.Encode(rec); err != nil {
+		return errors.Wrap(err, "failed write url")
+	}
+
+	return nil
+}
+
+// AddClicks добавляет в хранилище коллекцию переходов по сокращенным URL.
+func (u *FileURLStore) AddClicks(ctx context.Context, clicks []domain.Click) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, click := range clicks {
+		u.clicks[click.ShortURL] = append(u.clicks[click.ShortURL], click)
+
+		if u.clickEncoder == nil {
+			continue
+		}
+
+		err := u.clickEncoder.Encode(StoredClick(click))
+
+		if err != nil {
+			return errors.Wrap(err, "failed to add clicks")
+		}
+	}
+
 	return nil
 }
+
+// GetClickStats возвращает статистику переходов по сокращенному URL, добавленному указанным пользователем.
+func (u *FileURLStore) GetClickStats(ctx context.Context, shortURL string,
+	userID domain.UserID) (domain.ClickStats, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rec, ok := u.m[shortURL]
+
+	if !ok {
+		return domain.ClickStats{}, domain.ErrOriginalURLNotFound
+	}
+
+	if rec.UserID != userID {
+		return domain.ClickStats{}, domain.ErrOriginalURLNotOwned
+	}
+
+	return domain.NewClickStats(u.clicks[shortURL]), nil
+}
+
+// GetURLsCount возвращает количество сокращенных URL в хранилище.
+func (u *FileURLStore) GetURLsCount(ctx context.Context) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	count := 0
+	for _, rec := range u.m {
+		if !rec.IsDeleted {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// GetUserURLsCount возвращает количество действующих URL, сокращенных пользователем: неудаленных, не истекших
+// и не исчерпавших переходы.
+func (u *FileURLStore) GetUserURLsCount(ctx context.Context, userID domain.UserID) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	count := 0
+	now := time.Now()
+
+	for _, rec := range u.m {
+		if rec.IsDeleted || rec.UserID != userID {
+			continue
+		}
+
+		if pair := rec.toURLPair(); !pair.IsExpired(now) && !pair.IsExhausted() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// GetUsersCount возвращает количество пользователей, которые сократили URL.
+func (u *FileURLStore) GetUsersCount(ctx context.Context) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	users := make(map[domain.UserID]struct{})
+	for _, rec := range u.m {
+		if !rec.IsDeleted {
+			users[rec.UserID] = struct{}{}
+		}
+	}
+
+	return len(users), nil
+}
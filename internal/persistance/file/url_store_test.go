@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -103,14 +104,17 @@ func TestAddURL(t *testing.T) {
 		)
 		ctx := context.Background()
 		userID := domain.NewUserID()
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
 		want := StoredURL{
 			ShortURL:    shortURL,
 			OriginalURL: originalURL,
 			UserID:      userID,
+			CreatedAt:   createdAt,
 		}
 		pair := domain.URLPair{
 			ShortURL:    shortURL,
 			OriginalURL: originalURL,
+			CreatedAt:   createdAt,
 		}
 		urls := []StoredURL{}
 		rw := getReadWriter(t, urls)
@@ -125,15 +129,18 @@ func TestAddURL(t *testing.T) {
 func TestAddURLs(t *testing.T) {
 	t.Run("write batch of urls to writer", func(t *testing.T) {
 		var (
-			userID = domain.NewUserID()
-			urls   = []domain.URLPair{
+			userID    = domain.NewUserID()
+			createdAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			urls      = []domain.URLPair{
 				{
 					ShortURL:    "abc",
 					OriginalURL: "http://example.com",
+					CreatedAt:   createdAt,
 				},
 				{
 					ShortURL:    "123",
 					OriginalURL: "http://example2.com",
+					CreatedAt:   createdAt,
 				},
 			}
 			want = []StoredURL{
@@ -141,11 +148,13 @@ func TestAddURLs(t *testing.T) {
 					ShortURL:    urls[0].ShortURL,
 					OriginalURL: urls[0].OriginalURL,
 					UserID:      userID,
+					CreatedAt:   createdAt,
 				},
 				{
 					ShortURL:    urls[1].ShortURL,
 					OriginalURL: urls[1].OriginalURL,
 					UserID:      userID,
+					CreatedAt:   createdAt,
 				},
 			}
 			stored = []StoredURL{}
@@ -160,6 +169,68 @@ func TestAddURLs(t *testing.T) {
 	})
 }
 
+func TestAddClicks(t *testing.T) {
+	t.Run("write clicks to clicks writer", func(t *testing.T) {
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		pair := domain.URLPair{
+			ShortURL:    "abc",
+			OriginalURL: "http://example.com",
+		}
+		click := domain.Click{
+			ShortURL:  pair.ShortURL,
+			Timestamp: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+			Referrer:  "http://ya.ru",
+		}
+		urls := getReadWriter(t, []StoredURL{})
+		clicks := &bytes.Buffer{}
+		sut, err := New(ctx, urls, WithClicks(clicks))
+		require.NoError(t, err)
+		err = sut.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+
+		err = sut.AddClicks(ctx, []domain.Click{click})
+
+		require.NoError(t, err)
+		var got StoredClick
+		err = json.NewDecoder(bytes.NewReader(clicks.Bytes())).Decode(&got)
+		require.NoError(t, err)
+		assert.Equal(t, StoredClick(click), got)
+	})
+
+	t.Run("read stored clicks", func(t *testing.T) {
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		urls := getReadWriter(t, []StoredURL{
+			{
+				ShortURL:    "abc",
+				OriginalURL: "http://example.com",
+				UserID:      userID,
+			},
+		})
+		clicks := &bytes.Buffer{}
+		enc := json.NewEncoder(clicks)
+		require.NoError(t, enc.Encode(StoredClick{ShortURL: "abc", Timestamp: time.Now()}))
+		require.NoError(t, enc.Encode(StoredClick{ShortURL: "abc", Timestamp: time.Now()}))
+
+		sut, err := New(ctx, urls, WithClicks(clicks))
+		require.NoError(t, err)
+
+		stats, err := sut.GetClickStats(ctx, "abc", userID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Total)
+	})
+
+	t.Run("invalid clicks data", func(t *testing.T) {
+		urls := getReadWriter(t, []StoredURL{})
+		clicks := bytes.NewBufferString("invalid_data")
+
+		_, err := New(context.Background(), urls, WithClicks(clicks))
+
+		assert.Error(t, err)
+	})
+}
+
 func assertStoredURLs(t *testing.T, wantURLs []StoredURL, rw *bytes.Buffer) {
 	t.Helper()
 
@@ -3,74 +3,172 @@ package inmemory
 import (
 	"context"
 	"errors"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nestjam/yap-shortener/internal/domain"
 )
 
 // InmemoryURLStore реализует хранилище ссылок в памяти.
 type InmemoryURLStore struct {
-	m sync.Map
+	clicks   map[string][]domain.Click
+	m        sync.Map
+	clicksMu sync.Mutex
+	uniqueMu sync.Mutex // упорядочивает изменения, которые проверяют уникальность сокращенного и исходного URL
 }
 
 type urlRecord struct {
-	originalURL string
-	userID      domain.UserID
-	isDeleted   bool
+	createdAt      time.Time
+	activeFrom     time.Time
+	expiresAt      time.Time
+	originalURL    string
+	canonicalURL   string
+	passwordHash   string
+	title          string
+	tags           []string
+	destinations   []domain.Destination
+	rotation       string
+	rules          []domain.RoutingRule
+	forwardPath    bool
+	redirectStatus int
+	clicksLeft     *atomic.Int64 // общий для копий записи счетчик оставшихся переходов
+	maxClicks      int
+	userID         domain.UserID
+	isDeleted      bool
+}
+
+func newURLRecord(pair domain.URLPair, userID domain.UserID) *urlRecord {
+	clicksLeft := &atomic.Int64{}
+	clicksLeft.Store(int64(pair.ClicksLeft))
+
+	return &urlRecord{
+		createdAt:      pair.WithCreatedAt(time.Now()).CreatedAt,
+		originalURL:    pair.OriginalURL,
+		canonicalURL:   pair.CanonicalURL,
+		passwordHash:   pair.PasswordHash,
+		title:          pair.Title,
+		tags:           slices.Clone(pair.Tags),
+		destinations:   slices.Clone(pair.Destinations),
+		rotation:       pair.Rotation,
+		rules:          slices.Clone(pair.Rules),
+		forwardPath:    pair.ForwardPath,
+		redirectStatus: pair.RedirectStatus,
+		activeFrom:     pair.ActiveFrom,
+		expiresAt:      pair.ExpiresAt,
+		maxClicks:      pair.MaxClicks,
+		clicksLeft:     clicksLeft,
+		userID:         userID,
+	}
+}
+
+func (r urlRecord) toURLPair(shortURL string) domain.URLPair {
+	return domain.URLPair{
+		ShortURL:       shortURL,
+		OriginalURL:    r.originalURL,
+		CanonicalURL:   r.canonicalURL,
+		PasswordHash:   r.passwordHash,
+		Title:          r.title,
+		Tags:           slices.Clone(r.tags),
+		Destinations:   slices.Clone(r.destinations),
+		Rotation:       r.rotation,
+		Rules:          slices.Clone(r.rules),
+		ForwardPath:    r.forwardPath,
+		RedirectStatus: r.redirectStatus,
+		CreatedAt:      r.createdAt,
+		ActiveFrom:     r.activeFrom,
+		ExpiresAt:      r.expiresAt,
+		MaxClicks:      r.maxClicks,
+		ClicksLeft:     int(r.clicksLeft.Load()),
+	}
+}
+
+// canonical возвращает канонический вид исходного URL.
+func (r urlRecord) canonical() string {
+	return domain.URLPair{OriginalURL: r.originalURL, CanonicalURL: r.canonicalURL}.Canonical()
 }
 
 // New создает экземпляр хранилища.
 func New() *InmemoryURLStore {
-	return &InmemoryURLStore{}
+	return &InmemoryURLStore{
+		clicks: make(map[string][]domain.Click),
+	}
 }
 
 // GetOriginalURL возвращает исходный URL для сокращенного URL или ошибку.
 func (u *InmemoryURLStore) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+	pair, err := u.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	return pair.OriginalURL, nil
+}
+
+// GetURL возвращает пару исходного и сокращенного URL или ошибку.
+func (u *InmemoryURLStore) GetURL(ctx context.Context, shortURL string) (domain.URLPair, error) {
 	value, ok := u.m.Load(shortURL)
 
 	if !ok {
-		return "", domain.ErrOriginalURLNotFound
+		return domain.URLPair{}, domain.ErrOriginalURLNotFound
 	}
 
-	rec, ok := value.(urlRecord)
+	rec, ok := value.(*urlRecord)
 
 	if !ok {
-		return "", errors.New("failed type assertion")
+		return domain.URLPair{}, errors.New("failed type assertion")
+	}
+
+	// Истекшие URL удаляются при очистке, поэтому срок действия проверяется раньше признака удаления.
+	pair := rec.toURLPair(shortURL)
+	if pair.IsExpired(time.Now()) {
+		return domain.URLPair{}, domain.ErrOriginalURLIsExpired
 	}
 
 	if rec.isDeleted {
-		return "", domain.ErrOriginalURLIsDeleted
+		return domain.URLPair{}, domain.ErrOriginalURLIsDeleted
+	}
+
+	if pair.IsExhausted() {
+		return domain.URLPair{}, domain.ErrOriginalURLIsExhausted
 	}
 
-	return rec.originalURL, nil
+	return pair, nil
 }
 
 // AddURL добавляет в хранилище пару исходный и сокращенный URL.
 func (u *InmemoryURLStore) AddURL(ctx context.Context, pair domain.URLPair, userID domain.UserID) error {
-	if shortURL, ok := u.findShortURL(pair.OriginalURL); ok {
+	u.uniqueMu.Lock()
+	defer u.uniqueMu.Unlock()
+
+	if shortURL, ok := u.findShortURL(pair.Canonical()); ok {
 		return domain.NewOriginalURLExistsError(shortURL, nil)
 	}
 
-	rec := urlRecord{
-		originalURL: pair.OriginalURL,
-		userID:      userID,
+	rec := newURLRecord(pair, userID)
+
+	if _, loaded := u.m.LoadOrStore(pair.ShortURL, rec); loaded {
+		return domain.NewShortURLExistsError(pair.ShortURL, nil)
 	}
-	u.m.Store(pair.ShortURL, rec)
+
 	return nil
 }
 
-func (u *InmemoryURLStore) findShortURL(originalURL string) (string, bool) {
+// findShortURL возвращает сокращенный URL для исходного URL с указанным каноническим видом.
+func (u *InmemoryURLStore) findShortURL(canonicalURL string) (string, bool) {
 	shortURL := ""
 	found := false
 
 	u.m.Range(func(key, value any) bool {
-		rec, ok := value.(urlRecord)
+		rec, ok := value.(*urlRecord)
 
 		if !ok {
 			return true
 		}
 
-		if rec.originalURL == originalURL {
+		if rec.canonical() == canonicalURL {
 			found = true
 			shortURL, _ = key.(string)
 			return false
@@ -81,18 +179,80 @@ func (u *InmemoryURLStore) findShortURL(originalURL string) (string, bool) {
 	return shortURL, found
 }
 
-// AddURLs добавляет в хранилище коллекцию пар исходного и сокращенного URL.
+// AddURLs добавляет в хранилище коллекцию пар исходного и сокращенного URL. Если сокращенный или исходный URL
+// какой-либо пары уже сохранен, коллекция не добавляется и возвращается ошибка domain.ShortURLExistsError
+// или domain.OriginalURLExistsError.
 func (u *InmemoryURLStore) AddURLs(ctx context.Context, urls []domain.URLPair, userID domain.UserID) error {
+	u.uniqueMu.Lock()
+	defer u.uniqueMu.Unlock()
+
+	originals := make(map[string]string)
+	u.m.Range(func(key, value any) bool {
+		if rec, ok := value.(*urlRecord); ok {
+			originals[rec.canonical()], _ = key.(string)
+		}
+		return true
+	})
+
+	keys := make(map[string]struct{}, len(urls))
 	for _, url := range urls {
-		rec := urlRecord{
-			originalURL: url.OriginalURL,
-			userID:      userID,
+		if _, ok := keys[url.ShortURL]; ok {
+			return domain.NewShortURLExistsError(url.ShortURL, nil)
+		}
+
+		if _, ok := u.m.Load(url.ShortURL); ok {
+			return domain.NewShortURLExistsError(url.ShortURL, nil)
+		}
+
+		if shortURL, ok := originals[url.Canonical()]; ok {
+			return domain.NewOriginalURLExistsError(shortURL, nil)
 		}
-		u.m.Store(url.ShortURL, rec)
+
+		keys[url.ShortURL] = struct{}{}
+		originals[url.Canonical()] = url.ShortURL
+	}
+
+	for _, url := range urls {
+		u.m.Store(url.ShortURL, newURLRecord(url, userID))
 	}
 	return nil
 }
 
+// FindConflicts возвращает статус конфликта с сохраненными URL для каждой конфликтующей пары:
+// domain.KeyTaken, если сокращенный URL пары занят, и domain.KeyURLExists, если исходный URL уже сокращен.
+func (u *InmemoryURLStore) FindConflicts(ctx context.Context, pairs []domain.URLPair) (map[string]domain.KeyStatus,
+	error) {
+	originals := make(map[string]struct{}, len(pairs))
+	for _, pair := range pairs {
+		originals[pair.Canonical()] = struct{}{}
+	}
+
+	storedOriginals := make(map[string]struct{})
+	u.m.Range(func(key, value any) bool {
+		rec, ok := value.(*urlRecord)
+
+		if !ok {
+			return true
+		}
+
+		if _, ok = originals[rec.canonical()]; ok {
+			storedOriginals[rec.canonical()] = struct{}{}
+		}
+		return true
+	})
+
+	conflicts := make(map[string]domain.KeyStatus)
+	for _, pair := range pairs {
+		if _, ok := u.m.Load(pair.ShortURL); ok {
+			conflicts[pair.ShortURL] = domain.KeyTaken
+		} else if _, ok := storedOriginals[pair.Canonical()]; ok {
+			conflicts[pair.ShortURL] = domain.KeyURLExists
+		}
+	}
+
+	return conflicts, nil
+}
+
 // IsAvailable позволяет проверить доступность хранилща.
 func (u *InmemoryURLStore) IsAvailable(ctx context.Context) bool {
 	return true
@@ -100,47 +260,345 @@ func (u *InmemoryURLStore) IsAvailable(ctx context.Context) bool {
 
 // GetUserURLs возвращает коллекцию пар исходного и сокращенного URL, которые были добавлены указанным пользователем.
 func (u *InmemoryURLStore) GetUserURLs(ctx context.Context, userID domain.UserID) ([]domain.URLPair, error) {
+	return u.userURLs(userID, false), nil
+}
+
+// GetDeletedUserURLs возвращает коллекцию удаленных пар исходного и сокращенного URL,
+// которые были добавлены указанным пользователем. Истекшие URL не возвращаются.
+func (u *InmemoryURLStore) GetDeletedUserURLs(ctx context.Context, userID domain.UserID) ([]domain.URLPair, error) {
+	return u.userURLs(userID, true), nil
+}
+
+func (u *InmemoryURLStore) userURLs(userID domain.UserID, isDeleted bool) []domain.URLPair {
 	var userURLs []domain.URLPair
+	now := time.Now()
 
 	u.m.Range(func(key, value any) bool {
-		rec, ok := value.(urlRecord)
+		rec, ok := value.(*urlRecord)
 
-		if !ok || rec.userID != userID || rec.isDeleted {
+		if !ok || rec.userID != userID || rec.isDeleted != isDeleted {
 			return true
 		}
 
-		url := domain.URLPair{
-			ShortURL:    key.(string),
-			OriginalURL: rec.originalURL,
+		pair := rec.toURLPair(key.(string))
+		if isDeleted && pair.IsExpired(now) {
+			return true
 		}
-		userURLs = append(userURLs, url)
+
+		userURLs = append(userURLs, pair)
 		return true
 	})
 
-	return userURLs, nil
+	return userURLs
 }
 
-// DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
-// которые были добавлены указанным пользователем.
-func (u *InmemoryURLStore) DeleteUserURLs(ctx context.Context, shortURLs []string, userID domain.UserID) error {
-	for _, shortURL := range shortURLs {
+// GetUserURLsPage возвращает страницу URL, сокращенных указанным пользователем.
+func (u *InmemoryURLStore) GetUserURLsPage(ctx context.Context, userID domain.UserID,
+	query domain.UserURLsQuery) (domain.URLPage, error) {
+	userURLs, err := u.GetUserURLs(ctx, userID)
+
+	if err != nil {
+		return domain.URLPage{}, err
+	}
+
+	return domain.NewURLPage(userURLs, query), nil
+}
+
+// WalkUserURLs обходит все URL, сокращенные указанным пользователем, включая удаленные, в порядке создания.
+func (u *InmemoryURLStore) WalkUserURLs(ctx context.Context, userID domain.UserID, visit domain.URLVisitor) error {
+	var pairs []domain.URLPair
+	deleted := make(map[string]bool)
+
+	u.m.Range(func(key, value any) bool {
+		rec, ok := value.(*urlRecord)
+
+		if !ok || rec.userID != userID {
+			return true
+		}
+
+		pair := rec.toURLPair(key.(string))
+		pairs = append(pairs, pair)
+		deleted[pair.ShortURL] = rec.isDeleted
+		return true
+	})
+
+	slices.SortFunc(pairs, domain.CompareURLPairs)
+
+	for _, pair := range pairs {
+		if err := visit(pair, deleted[pair.ShortURL]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateOriginalURL заменяет исходный URL и его канонический вид для сокращенного URL, добавленного
+// указанным пользователем.
+func (u *InmemoryURLStore) UpdateOriginalURL(ctx context.Context, shortURL, originalURL, canonicalURL string,
+	userID domain.UserID) error {
+	u.uniqueMu.Lock()
+	defer u.uniqueMu.Unlock()
+
+	pair := domain.URLPair{OriginalURL: originalURL, CanonicalURL: canonicalURL}
+
+	if existing, ok := u.findShortURL(pair.Canonical()); ok && existing != shortURL {
+		return domain.NewOriginalURLExistsError(existing, nil)
+	}
+
+	return u.updateUserURL(shortURL, userID, func(rec *urlRecord) {
+		rec.originalURL = pair.OriginalURL
+		rec.canonicalURL = pair.CanonicalURL
+	})
+}
+
+// UpdateTags заменяет метки сокращенного URL, добавленного указанным пользователем.
+func (u *InmemoryURLStore) UpdateTags(ctx context.Context, shortURL string, tags []string,
+	userID domain.UserID) error {
+	tags = slices.Clone(tags)
+
+	return u.updateUserURL(shortURL, userID, func(rec *urlRecord) {
+		rec.tags = tags
+	})
+}
+
+// UpdateRoutingRules заменяет правила выбора исходного URL сокращенного URL, добавленного указанным пользователем.
+func (u *InmemoryURLStore) UpdateRoutingRules(ctx context.Context, shortURL string, rules []domain.RoutingRule,
+	userID domain.UserID) error {
+	rules = slices.Clone(rules)
+
+	return u.updateUserURL(shortURL, userID, func(rec *urlRecord) {
+		rec.rules = rules
+	})
+}
+
+// updateUserURL изменяет запись сокращенного URL, добавленного указанным пользователем. Запись заменяется
+// измененной копией в цикле сравнения с обменом, поэтому одновременные изменения записи не теряются.
+func (u *InmemoryURLStore) updateUserURL(shortURL string, userID domain.UserID, change func(rec *urlRecord)) error {
+	for {
 		value, ok := u.m.Load(shortURL)
 
 		if !ok {
-			continue
+			return domain.ErrOriginalURLNotFound
 		}
 
-		rec, ok := value.(urlRecord)
+		old, ok := value.(*urlRecord)
 
 		if !ok {
-			continue
+			return errors.New("failed type assertion")
 		}
 
-		if rec.userID == userID {
-			rec.isDeleted = true
-			_, _ = u.m.Swap(shortURL, rec)
+		if old.userID != userID {
+			return domain.ErrOriginalURLNotOwned
+		}
+
+		if old.isDeleted {
+			return domain.ErrOriginalURLIsDeleted
+		}
+
+		rec := *old
+		change(&rec)
+
+		if u.m.CompareAndSwap(shortURL, old, &rec) {
+			return nil
 		}
 	}
+}
+
+// DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
+// которые были добавлены указанным пользователем. Возвращает результат удаления каждого URL.
+func (u *InmemoryURLStore) DeleteUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	return u.setDeleted(shortURLs, userID, true, domain.KeyDeleted), nil
+}
+
+// RestoreUserURLs восстанавливает удаленные сокращенные URL, которые были добавлены указанным пользователем.
+// Возвращает результат восстановления каждого URL. Истекшие URL не восстанавливаются и считаются ненайденными.
+func (u *InmemoryURLStore) RestoreUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	return u.setDeleted(shortURLs, userID, false, domain.KeyRestored), nil
+}
+
+// setDeleted устанавливает признак удаления сокращенным URL, добавленным указанным пользователем.
+func (u *InmemoryURLStore) setDeleted(shortURLs []string, userID domain.UserID, isDeleted bool,
+	done domain.KeyStatus) []domain.KeyResult {
+	owners := make(map[string]domain.UserID, len(shortURLs))
+	now := time.Now()
+
+	for _, shortURL := range shortURLs {
+		for {
+			value, ok := u.m.Load(shortURL)
+
+			if !ok {
+				break
+			}
+
+			old, ok := value.(*urlRecord)
+
+			if !ok || !isDeleted && old.toURLPair(shortURL).IsExpired(now) {
+				break
+			}
+
+			owners[shortURL] = old.userID
+			if old.userID != userID {
+				break
+			}
+
+			rec := *old
+			rec.isDeleted = isDeleted
+
+			if u.m.CompareAndSwap(shortURL, old, &rec) {
+				break
+			}
+		}
+	}
+
+	return domain.NewKeyResults(shortURLs, owners, userID, done)
+}
+
+// DeleteExpiredURLs удаляет из хранилища сокращенные URL, срок действия которых истек к указанному моменту.
+func (u *InmemoryURLStore) DeleteExpiredURLs(ctx context.Context, now time.Time) error {
+	u.m.Range(func(key, value any) bool {
+		rec, ok := value.(*urlRecord)
+
+		if !ok || rec.isDeleted {
+			return true
+		}
+
+		shortURL, _ := key.(string)
+		if rec.toURLPair(shortURL).IsExpired(now) {
+			deleted := *rec
+			deleted.isDeleted = true
+			// Если запись изменилась после чтения, она будет проверена при следующей очистке.
+			_ = u.m.CompareAndSwap(key, rec, &deleted)
+		}
+		return true
+	})
 
 	return nil
 }
+
+// ConsumeClick списывает переход по сокращенному URL с ограниченным количеством переходов. Счетчик
+// уменьшается в цикле сравнения с обменом, поэтому одновременные переходы не превышают ограничение.
+// Если переходы исчерпаны, возвращается ошибка domain.ErrOriginalURLIsExhausted.
+func (u *InmemoryURLStore) ConsumeClick(ctx context.Context, shortURL string) error {
+	value, ok := u.m.Load(shortURL)
+
+	if !ok {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	rec, ok := value.(*urlRecord)
+
+	if !ok {
+		return errors.New("failed type assertion")
+	}
+
+	if rec.isDeleted {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	if rec.maxClicks <= 0 {
+		return nil
+	}
+
+	for {
+		left := rec.clicksLeft.Load()
+
+		if left <= 0 {
+			return domain.ErrOriginalURLIsExhausted
+		}
+
+		if rec.clicksLeft.CompareAndSwap(left, left-1) {
+			return nil
+		}
+	}
+}
+
+// AddClicks добавляет в хранилище коллекцию переходов по сокращенным URL.
+func (u *InmemoryURLStore) AddClicks(ctx context.Context, clicks []domain.Click) error {
+	u.clicksMu.Lock()
+	defer u.clicksMu.Unlock()
+
+	for _, click := range clicks {
+		u.clicks[click.ShortURL] = append(u.clicks[click.ShortURL], click)
+	}
+
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов по сокращенному URL, добавленному указанным пользователем.
+func (u *InmemoryURLStore) GetClickStats(ctx context.Context, shortURL string,
+	userID domain.UserID) (domain.ClickStats, error) {
+	value, ok := u.m.Load(shortURL)
+
+	if !ok {
+		return domain.ClickStats{}, domain.ErrOriginalURLNotFound
+	}
+
+	rec, ok := value.(*urlRecord)
+
+	if !ok {
+		return domain.ClickStats{}, errors.New("failed type assertion")
+	}
+
+	if rec.userID != userID {
+		return domain.ClickStats{}, domain.ErrOriginalURLNotOwned
+	}
+
+	u.clicksMu.Lock()
+	defer u.clicksMu.Unlock()
+
+	return domain.NewClickStats(u.clicks[shortURL]), nil
+}
+
+// GetURLsCount возвращает количество сокращенных URL в хранилище.
+func (u *InmemoryURLStore) GetURLsCount(ctx context.Context) (int, error) {
+	count := 0
+
+	u.m.Range(func(key, value any) bool {
+		if rec, ok := value.(*urlRecord); ok && !rec.isDeleted {
+			count++
+		}
+		return true
+	})
+
+	return count, nil
+}
+
+// GetUserURLsCount возвращает количество действующих URL, сокращенных пользователем: неудаленных, не истекших
+// и не исчерпавших переходы.
+func (u *InmemoryURLStore) GetUserURLsCount(ctx context.Context, userID domain.UserID) (int, error) {
+	count := 0
+	now := time.Now()
+
+	u.m.Range(func(key, value any) bool {
+		rec, ok := value.(*urlRecord)
+
+		if !ok || rec.isDeleted || rec.userID != userID {
+			return true
+		}
+
+		shortURL, _ := key.(string)
+		if pair := rec.toURLPair(shortURL); !pair.IsExpired(now) && !pair.IsExhausted() {
+			count++
+		}
+		return true
+	})
+
+	return count, nil
+}
+
+// GetUsersCount возвращает количество пользователей, которые сократили URL.
+func (u *InmemoryURLStore) GetUsersCount(ctx context.Context) (int, error) {
+	users := make(map[domain.UserID]struct{})
+
+	u.m.Range(func(key, value any) bool {
+		if rec, ok := value.(*urlRecord); ok && !rec.isDeleted {
+			users[rec.userID] = struct{}{}
+		}
+		return true
+	})
+
+	return len(users), nil
+}
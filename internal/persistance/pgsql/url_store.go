@@ -2,7 +2,10 @@ package pgsql
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
@@ -17,6 +20,39 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 )
 
+const shortURLUniqueConstraint = "url_short_url_key"
+
+// urlPairColumns перечисляет столбцы таблицы url, из которых формируется domain.URLPair.
+// Метки выбираются из таблицы url_tag в виде отсортированного массива.
+const urlPairColumns = "short_url, original_url, canonical_url, expires_at, created_at, password_hash, title, " +
+	"max_clicks, clicks_left, active_from, destinations, rotation, routing_rules, forward_path, redirect_status, ARRAY(SELECT tag FROM url_tag WHERE url_tag.short_url = url.short_url ORDER BY tag) AS tags"
+
+// canonicalURLExpr вычисляет канонический вид исходного URL. Для URL, сохраненных без канонического вида,
+// им считается исходный URL. По этому выражению построен уникальный индекс url_canonical_url_key.
+const canonicalURLExpr = "COALESCE(canonical_url, original_url)"
+
+// urlInsertColumns перечисляет столбцы таблицы url, заполняемые при добавлении сокращенного URL.
+var urlInsertColumns = []string{
+	"short_url", "original_url", "canonical_url", "user_id", "expires_at", "created_at", "password_hash", "title",
+	"max_clicks", "clicks_left", "active_from", "destinations", "rotation", "routing_rules",
+	"forward_path", "redirect_status",
+}
+
+// destinationJSON описывает вариант исходного URL в столбце destinations.
+type destinationJSON struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// routingRuleJSON описывает правило выбора исходного URL в столбце routing_rules.
+type routingRuleJSON struct {
+	OS       string `json:"os,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Language string `json:"language,omitempty"`
+	URL      string `json:"url"`
+}
+
 // PostgresURLStore реализует хранилище ссылок в БД.
 type PostgresURLStore struct {
 	pool       *pgxpool.Pool
@@ -77,28 +113,52 @@ func initPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
 
 // GetOriginalURL возвращает исходный URL для сокращенного URL или ошибку.
 func (u *PostgresURLStore) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
-	const op = "get original URL"
+	pair, err := u.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	return pair.OriginalURL, nil
+}
+
+// GetURL возвращает пару исходного и сокращенного URL или ошибку.
+func (u *PostgresURLStore) GetURL(ctx context.Context, shortURL string) (domain.URLPair, error) {
+	const op = "get URL"
 	conn, err := u.pool.Acquire(ctx)
 	defer conn.Release()
 
 	if err != nil {
-		return "", errors.Wrapf(err, op)
+		return domain.URLPair{}, errors.Wrapf(err, op)
 	}
 
-	var originalURL string
 	var isDeleted bool
-	row := conn.QueryRow(ctx, "SELECT original_url, is_deleted FROM url WHERE short_url=$1", shortURL)
-	err = row.Scan(&originalURL, &isDeleted)
+	const sql = "SELECT " + urlPairColumns + ", is_deleted FROM url WHERE short_url=$1"
+	row := conn.QueryRow(ctx, sql, shortURL)
+	pair, err := scanURLPair(row, &isDeleted)
 
 	if errors.Is(err, pgx.ErrNoRows) {
-		return "", domain.ErrOriginalURLNotFound
+		return domain.URLPair{}, domain.ErrOriginalURLNotFound
+	}
+
+	if err != nil {
+		return domain.URLPair{}, errors.Wrapf(err, op)
+	}
+
+	// Истекшие URL удаляются при очистке, поэтому срок действия проверяется раньше признака удаления.
+	if pair.IsExpired(time.Now()) {
+		return domain.URLPair{}, domain.ErrOriginalURLIsExpired
 	}
 
 	if isDeleted {
-		return "", domain.ErrOriginalURLIsDeleted
+		return domain.URLPair{}, domain.ErrOriginalURLIsDeleted
 	}
 
-	return originalURL, nil
+	if pair.IsExhausted() {
+		return domain.URLPair{}, domain.ErrOriginalURLIsExhausted
+	}
+
+	return pair, nil
 }
 
 // AddURL добавляет в хранилище пару исходный и сокращенный URL.
@@ -121,13 +181,22 @@ func (u *PostgresURLStore) AddURL(ctx context.Context, pair domain.URLPair, user
 
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	_, err = tx.Exec(ctx, "INSERT INTO url (short_url, original_url, user_id) VALUES ($1, $2, $3)",
-		pair.ShortURL, pair.OriginalURL, uuid.UUID(userID))
+	sql := buildInsertURLQuery()
+	_, err = tx.Exec(ctx, sql, newURLRow(pair.WithCreatedAt(time.Now()), userID)...)
+
+	if err == nil {
+		err = insertTags(ctx, tx, pair.ShortURL, pair.Tags)
+	}
 
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
 		_ = tx.Rollback(ctx)
-		shortURL, er := getShortURL(ctx, conn, pair.OriginalURL)
+
+		if pgErr.ConstraintName == shortURLUniqueConstraint {
+			return domain.NewShortURLExistsError(pair.ShortURL, nil)
+		}
+
+		shortURL, er := getShortURL(ctx, conn, pair.Canonical())
 
 		if er != nil {
 			return errors.Wrapf(er, op)
@@ -149,9 +218,66 @@ func (u *PostgresURLStore) AddURL(ctx context.Context, pair domain.URLPair, user
 	return nil
 }
 
-func getShortURL(ctx context.Context, conn *pgxpool.Conn, originalURL string) (string, error) {
+// findConflict возвращает ошибку конфликта первой пары, сокращенный или исходный URL которой уже сохранен,
+// в зависимости от нарушенного ограничения уникальности. Если такой пары нет, например если URL повторяются
+// в самой коллекции, возвращается nil.
+func findConflict(ctx context.Context, conn *pgxpool.Conn, pairs []domain.URLPair, constraint string) error {
+	for _, pair := range pairs {
+		if constraint == shortURLUniqueConstraint {
+			var exists bool
+			row := conn.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM url WHERE short_url=$1)", pair.ShortURL)
+
+			if err := row.Scan(&exists); err != nil {
+				return fmt.Errorf("find conflict: %w", err)
+			}
+
+			if exists {
+				return domain.NewShortURLExistsError(pair.ShortURL, nil)
+			}
+
+			continue
+		}
+
+		shortURL, err := getShortURL(ctx, conn, pair.Canonical())
+
+		if err == nil {
+			return domain.NewOriginalURLExistsError(shortURL, nil)
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("find conflict: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// findBatchConflict возвращает ошибку, если сокращенный или исходный URL повторяется внутри коллекции.
+// Такие повторы нарушают уникальность при копировании строк, но не находятся среди сохраненных URL.
+func findBatchConflict(pairs []domain.URLPair) error {
+	keys := make(map[string]struct{}, len(pairs))
+	originals := make(map[string]string, len(pairs))
+
+	for _, pair := range pairs {
+		if _, ok := keys[pair.ShortURL]; ok {
+			return domain.NewShortURLExistsError(pair.ShortURL, nil)
+		}
+
+		if shortURL, ok := originals[pair.Canonical()]; ok {
+			return domain.NewOriginalURLExistsError(shortURL, nil)
+		}
+
+		keys[pair.ShortURL] = struct{}{}
+		originals[pair.Canonical()] = pair.ShortURL
+	}
+
+	return nil
+}
+
+// getShortURL возвращает сокращенный URL для исходного URL с указанным каноническим видом.
+func getShortURL(ctx context.Context, conn *pgxpool.Conn, canonicalURL string) (string, error) {
 	var shortURL string
-	row := conn.QueryRow(ctx, "SELECT short_url FROM url WHERE original_url=$1", originalURL)
+	row := conn.QueryRow(ctx, "SELECT short_url FROM url WHERE "+canonicalURLExpr+"=$1", canonicalURL)
 	err := row.Scan(&shortURL)
 
 	if err != nil {
@@ -161,7 +287,9 @@ func getShortURL(ctx context.Context, conn *pgxpool.Conn, originalURL string) (s
 	return shortURL, nil
 }
 
-// AddURLs добавляет в хранилище коллекцию пар исходного и сокращенного URL.
+// AddURLs добавляет в хранилище коллекцию пар исходного и сокращенного URL. Если сокращенный или исходный URL
+// какой-либо пары уже сохранен или повторяется в коллекции, коллекция не добавляется и возвращается ошибка
+// domain.ShortURLExistsError или domain.OriginalURLExistsError.
 func (u *PostgresURLStore) AddURLs(ctx context.Context, pairs []domain.URLPair, userID domain.UserID) error {
 	const op = "add URLs"
 	conn, err := u.pool.Acquire(ctx)
@@ -180,9 +308,28 @@ func (u *PostgresURLStore) AddURLs(ctx context.Context, pairs []domain.URLPair,
 
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	columns := []string{"short_url", "original_url", "user_id"}
+	if err = findBatchConflict(pairs); err != nil {
+		return err
+	}
+
 	rows := pgx.CopyFromRows(prepareRows(pairs, userID))
-	_, err = conn.CopyFrom(ctx, pgx.Identifier{"url"}, columns, rows)
+	_, err = conn.CopyFrom(ctx, pgx.Identifier{"url"}, urlInsertColumns, rows)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		_ = tx.Rollback(ctx)
+
+		if er := findConflict(ctx, conn, pairs, pgErr.ConstraintName); er != nil {
+			return er
+		}
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	tagRows := pgx.CopyFromRows(prepareTagRows(pairs))
+	_, err = conn.CopyFrom(ctx, pgx.Identifier{"url_tag"}, []string{"short_url", "tag"}, tagRows)
 
 	if err != nil {
 		return errors.Wrapf(err, op)
@@ -199,15 +346,166 @@ func (u *PostgresURLStore) AddURLs(ctx context.Context, pairs []domain.URLPair,
 
 func prepareRows(pairs []domain.URLPair, userID domain.UserID) [][]any {
 	rows := make([][]any, len(pairs))
+	now := time.Now()
 
 	for i := 0; i < len(pairs); i++ {
-		pair := pairs[i]
-		rows[i] = []any{pair.ShortURL, pair.OriginalURL, uuid.UUID(userID)}
+		rows[i] = newURLRow(pairs[i].WithCreatedAt(now), userID)
+	}
+
+	return rows
+}
+
+func prepareTagRows(pairs []domain.URLPair) [][]any {
+	var rows [][]any
+
+	for _, pair := range pairs {
+		for _, tag := range pair.Tags {
+			rows = append(rows, []any{pair.ShortURL, tag})
+		}
 	}
 
 	return rows
 }
 
+// insertTags добавляет метки сокращенного URL.
+func insertTags(ctx context.Context, tx pgx.Tx, shortURL string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	const sql = "INSERT INTO url_tag (short_url, tag) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING"
+	if _, err := tx.Exec(ctx, sql, shortURL, tags); err != nil {
+		return fmt.Errorf("insert tags: %w", err)
+	}
+
+	return nil
+}
+
+// newURLRow возвращает значения столбцов urlInsertColumns для сокращенного URL.
+func newURLRow(pair domain.URLPair, userID domain.UserID) []any {
+	return []any{pair.ShortURL, pair.OriginalURL, toNullString(pair.CanonicalURL), uuid.UUID(userID),
+		toNullTime(pair.ExpiresAt), pair.CreatedAt, pair.PasswordHash, pair.Title, pair.MaxClicks, pair.ClicksLeft,
+		toNullTime(pair.ActiveFrom), toDestinationsJSON(pair.Destinations), pair.Rotation,
+		toRoutingRulesJSON(pair.Rules), pair.ForwardPath, pair.RedirectStatus}
+}
+
+// buildInsertURLQuery формирует запрос добавления сокращенного URL по столбцам urlInsertColumns.
+func buildInsertURLQuery() string {
+	params := make([]string, len(urlInsertColumns))
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	return fmt.Sprintf("INSERT INTO url (%s) VALUES (%s)",
+		strings.Join(urlInsertColumns, ", "), strings.Join(params, ", "))
+}
+
+// scanURLPair считывает столбцы urlPairColumns и дополнительные столбцы, следующие за ними.
+func scanURLPair(row pgx.Row, extra ...any) (domain.URLPair, error) {
+	var pair domain.URLPair
+	var expiresAt, activeFrom *time.Time
+	var canonicalURL, destinations, rules *string
+	dest := []any{&pair.ShortURL, &pair.OriginalURL, &canonicalURL, &expiresAt, &pair.CreatedAt,
+		&pair.PasswordHash, &pair.Title, &pair.MaxClicks, &pair.ClicksLeft, &activeFrom, &destinations,
+		&pair.Rotation, &rules, &pair.ForwardPath, &pair.RedirectStatus, &pair.Tags}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return domain.URLPair{}, fmt.Errorf("scan url: %w", err)
+	}
+
+	var err error
+	if pair.Destinations, err = fromDestinationsJSON(destinations); err != nil {
+		return domain.URLPair{}, fmt.Errorf("scan url: %w", err)
+	}
+
+	if pair.Rules, err = fromRoutingRulesJSON(rules); err != nil {
+		return domain.URLPair{}, fmt.Errorf("scan url: %w", err)
+	}
+
+	pair.ExpiresAt = fromNullTime(expiresAt)
+	pair.ActiveFrom = fromNullTime(activeFrom)
+	pair.CanonicalURL = fromNullString(canonicalURL)
+	pair.CreatedAt = pair.CreatedAt.UTC()
+
+	if len(pair.Tags) == 0 {
+		pair.Tags = nil
+	}
+
+	return pair, nil
+}
+
+// FindConflicts возвращает статус конфликта с сохраненными URL для каждой конфликтующей пары:
+// domain.KeyTaken, если сокращенный URL пары занят, и domain.KeyURLExists, если исходный URL уже сокращен.
+func (u *PostgresURLStore) FindConflicts(ctx context.Context, pairs []domain.URLPair) (map[string]domain.KeyStatus,
+	error) {
+	const op = "find conflicts"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	shortURLs := make([]string, len(pairs))
+	canonicalURLs := make([]string, len(pairs))
+	for i, pair := range pairs {
+		shortURLs[i] = pair.ShortURL
+		canonicalURLs[i] = pair.Canonical()
+	}
+
+	takenKeys, err := queryStrings(ctx, conn, "SELECT short_url FROM url WHERE short_url = ANY($1)", shortURLs)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	const sql = "SELECT " + canonicalURLExpr + " FROM url WHERE " + canonicalURLExpr + " = ANY($1)"
+	storedOriginals, err := queryStrings(ctx, conn, sql, canonicalURLs)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	conflicts := make(map[string]domain.KeyStatus)
+	for _, pair := range pairs {
+		if _, ok := takenKeys[pair.ShortURL]; ok {
+			conflicts[pair.ShortURL] = domain.KeyTaken
+		} else if _, ok := storedOriginals[pair.Canonical()]; ok {
+			conflicts[pair.ShortURL] = domain.KeyURLExists
+		}
+	}
+
+	return conflicts, nil
+}
+
+// queryStrings выполняет запрос, возвращающий один текстовый столбец, и возвращает множество его значений.
+func queryStrings(ctx context.Context, conn *pgxpool.Conn, sql string, args ...any) (map[string]struct{}, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("query strings: %w", err)
+	}
+
+	defer rows.Close()
+
+	values := make(map[string]struct{})
+	for rows.Next() {
+		var value string
+
+		if err = rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("query strings: %w", err)
+		}
+
+		values[value] = struct{}{}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("query strings: %w", err)
+	}
+
+	return values, nil
+}
+
 // IsAvailable позволяет проверить доступность хранилща.
 func (u *PostgresURLStore) IsAvailable(ctx context.Context) bool {
 	conn, err := u.pool.Acquire(ctx)
@@ -231,38 +529,171 @@ func (u *PostgresURLStore) GetUserURLs(ctx context.Context, userID domain.UserID
 		return nil, errors.Wrapf(err, op)
 	}
 
-	const sql = "SELECT short_url, original_url FROM url WHERE user_id = $1 AND is_deleted = false"
-	rows, err := conn.Query(ctx, sql, uuid.UUID(userID))
+	const sql = "SELECT " + urlPairColumns + " FROM url WHERE user_id = $1 AND is_deleted = false"
+	userURLs, err := queryURLPairs(ctx, conn, sql, uuid.UUID(userID))
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	return userURLs, nil
+}
+
+// GetDeletedUserURLs возвращает коллекцию удаленных пар исходного и сокращенного URL,
+// которые были добавлены указанным пользователем. Истекшие URL не возвращаются.
+func (u *PostgresURLStore) GetDeletedUserURLs(ctx context.Context, userID domain.UserID) ([]domain.URLPair, error) {
+	const op = "get deleted user URLs"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	const sql = "SELECT " + urlPairColumns + ` FROM url WHERE user_id = $1 AND is_deleted = true
+	AND (expires_at IS NULL OR expires_at > $2)`
+	userURLs, err := queryURLPairs(ctx, conn, sql, uuid.UUID(userID), time.Now())
 
 	if err != nil {
 		return nil, errors.Wrapf(err, op)
 	}
 
+	return userURLs, nil
+}
+
+// GetUserURLsPage возвращает страницу URL, сокращенных указанным пользователем.
+func (u *PostgresURLStore) GetUserURLsPage(ctx context.Context, userID domain.UserID,
+	query domain.UserURLsQuery) (domain.URLPage, error) {
+	const op = "get user URLs page"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return domain.URLPage{}, errors.Wrapf(err, op)
+	}
+
+	sql, args := buildUserURLsPageQuery(userID, query)
+	userURLs, err := queryURLPairs(ctx, conn, sql, args...)
+
+	if err != nil {
+		return domain.URLPage{}, errors.Wrapf(err, op)
+	}
+
+	return domain.NewURLPageFromSorted(userURLs, query.Limit), nil
+}
+
+// WalkUserURLs обходит все URL, сокращенные указанным пользователем, включая удаленные, в порядке создания.
+// Строки передаются функции обхода по мере чтения результата запроса.
+func (u *PostgresURLStore) WalkUserURLs(ctx context.Context, userID domain.UserID, visit domain.URLVisitor) error {
+	const op = "walk user URLs"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	const sql = "SELECT " + urlPairColumns + ", is_deleted FROM url WHERE user_id = $1 ORDER BY created_at, short_url"
+	rows, err := conn.Query(ctx, sql, uuid.UUID(userID))
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
 	defer rows.Close()
 
-	var userURLs []domain.URLPair
 	for rows.Next() {
-		userURL := domain.URLPair{}
-		err = rows.Scan(&userURL.ShortURL, &userURL.OriginalURL)
+		var isDeleted bool
+		pair, err := scanURLPair(rows, &isDeleted)
 
 		if err != nil {
-			return nil, errors.Wrapf(err, op)
+			return errors.Wrapf(err, op)
 		}
 
-		userURLs = append(userURLs, userURL)
+		if err = visit(pair, isDeleted); err != nil {
+			return err
+		}
 	}
 
-	if rows.Err() != nil {
-		return nil, errors.Wrapf(err, op)
+	if err = rows.Err(); err != nil {
+		return errors.Wrapf(err, op)
 	}
 
-	return userURLs, nil
+	return nil
 }
 
-// DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
-// которые были добавлены указанным пользователем.
-func (u *PostgresURLStore) DeleteUserURLs(ctx context.Context, shortURLs []string, userID domain.UserID) error {
-	const op = "delete user URLs"
+// buildUserURLsPageQuery формирует запрос страницы URL пользователя. Запрос выбирает на одну запись больше
+// лимита, чтобы определить наличие следующей страницы.
+func buildUserURLsPageQuery(userID domain.UserID, query domain.UserURLsQuery) (string, []any) {
+	var sb strings.Builder
+	args := []any{uuid.UUID(userID)}
+
+	sb.WriteString("SELECT " + urlPairColumns + " FROM url WHERE user_id = $1 AND is_deleted = false")
+
+	if query.Filter != "" {
+		args = append(args, query.Filter)
+		fmt.Fprintf(&sb, " AND strpos(original_url, $%d) > 0", len(args))
+	}
+
+	if query.Tag != "" {
+		args = append(args, query.Tag)
+		fmt.Fprintf(&sb, " AND EXISTS (SELECT 1 FROM url_tag WHERE url_tag.short_url = url.short_url AND tag = $%d)",
+			len(args))
+	}
+
+	cmp, order := "<", "DESC"
+	if query.Order == domain.SortAsc {
+		cmp, order = ">", "ASC"
+	}
+
+	if query.After != nil {
+		args = append(args, query.After.CreatedAt, query.After.ShortURL)
+		fmt.Fprintf(&sb, " AND (created_at, short_url) %s ($%d, $%d)", cmp, len(args)-1, len(args))
+	}
+
+	fmt.Fprintf(&sb, " ORDER BY created_at %s, short_url %s", order, order)
+
+	if query.Limit > 0 {
+		args = append(args, query.Limit+1)
+		fmt.Fprintf(&sb, " LIMIT $%d", len(args))
+	}
+
+	return sb.String(), args
+}
+
+func queryURLPairs(ctx context.Context, conn *pgxpool.Conn, sql string, args ...any) ([]domain.URLPair, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("query urls: %w", err)
+	}
+
+	defer rows.Close()
+
+	var pairs []domain.URLPair
+	for rows.Next() {
+		pair, err := scanURLPair(rows)
+
+		if err != nil {
+			return nil, fmt.Errorf("query urls: %w", err)
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("query urls: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// UpdateOriginalURL заменяет исходный URL и его канонический вид для сокращенного URL, добавленного
+// указанным пользователем.
+func (u *PostgresURLStore) UpdateOriginalURL(ctx context.Context, shortURL, originalURL, canonicalURL string,
+	userID domain.UserID) error {
+	pair := domain.URLPair{OriginalURL: originalURL, CanonicalURL: canonicalURL}
+	const op = "update original URL"
 	conn, err := u.pool.Acquire(ctx)
 	defer conn.Release()
 
@@ -270,32 +701,563 @@ func (u *PostgresURLStore) DeleteUserURLs(ctx context.Context, shortURLs []strin
 		return errors.Wrapf(err, op)
 	}
 
-	var txOptions pgx.TxOptions
-	tx, err := conn.BeginTx(ctx, txOptions)
+	if err = checkOwner(ctx, conn, shortURL, userID); err != nil {
+		return err
+	}
+
+	const sql = "UPDATE url SET original_url = $1, canonical_url = $2 WHERE short_url = $3 AND is_deleted = false"
+	tag, err := conn.Exec(ctx, sql, pair.OriginalURL, toNullString(pair.CanonicalURL), shortURL)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		existing, er := getShortURL(ctx, conn, pair.Canonical())
+
+		if er != nil {
+			return errors.Wrapf(er, op)
+		}
+
+		return domain.NewOriginalURLExistsError(existing, nil)
+	}
 
 	if err != nil {
 		return errors.Wrapf(err, op)
 	}
 
-	defer func() { _ = tx.Rollback(ctx) }()
+	if tag.RowsAffected() == 0 {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	return nil
+}
 
-	b := &pgx.Batch{}
+// UpdateTags заменяет метки сокращенного URL, добавленного указанным пользователем.
+func (u *PostgresURLStore) UpdateTags(ctx context.Context, shortURL string, tags []string,
+	userID domain.UserID) error {
+	const op = "update tags"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
 
-	for i := 0; i < len(shortURLs); i++ {
-		b.Queue("UPDATE url SET is_deleted = true WHERE short_url = $1 AND user_id = $2", shortURLs[i], uuid.UUID(userID))
+	if err != nil {
+		return errors.Wrapf(err, op)
 	}
 
-	err = tx.SendBatch(ctx, b).Close()
+	var txOptions pgx.TxOptions
+	tx, err := conn.BeginTx(ctx, txOptions)
 
 	if err != nil {
 		return errors.Wrapf(err, op)
 	}
 
-	err = tx.Commit(ctx)
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var ownerID uuid.UUID
+	var isDeleted bool
+	row := tx.QueryRow(ctx, "SELECT user_id, is_deleted FROM url WHERE short_url = $1 FOR UPDATE", shortURL)
+	err = row.Scan(&ownerID, &isDeleted)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrOriginalURLNotFound
+	}
 
 	if err != nil {
 		return errors.Wrapf(err, op)
 	}
 
-	return nil
+	if ownerID != uuid.UUID(userID) {
+		return domain.ErrOriginalURLNotOwned
+	}
+
+	if isDeleted {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	if _, err = tx.Exec(ctx, "DELETE FROM url_tag WHERE short_url = $1", shortURL); err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	if err = insertTags(ctx, tx, shortURL, tags); err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	return nil
+}
+
+// UpdateRoutingRules заменяет правила выбора исходного URL сокращенного URL, добавленного указанным пользователем.
+func (u *PostgresURLStore) UpdateRoutingRules(ctx context.Context, shortURL string, rules []domain.RoutingRule,
+	userID domain.UserID) error {
+	const op = "update routing rules"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	if err = checkOwner(ctx, conn, shortURL, userID); err != nil {
+		return err
+	}
+
+	const sql = "UPDATE url SET routing_rules = $1 WHERE short_url = $2 AND is_deleted = false"
+	tag, err := conn.Exec(ctx, sql, toRoutingRulesJSON(rules), shortURL)
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return domain.ErrOriginalURLIsDeleted
+	}
+
+	return nil
+}
+
+// DeleteUserURLs удаляет из хранилища коллекцию пар исходного и сокращенного URL,
+// которые были добавлены указанным пользователем. Возвращает результат удаления каждого URL.
+func (u *PostgresURLStore) DeleteUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	const op = "delete user URLs"
+	results, err := u.setDeleted(ctx, shortURLs, userID, true, domain.KeyDeleted)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	return results, nil
+}
+
+// RestoreUserURLs восстанавливает удаленные сокращенные URL, которые были добавлены указанным пользователем.
+// Возвращает результат восстановления каждого URL. Истекшие URL не восстанавливаются и считаются ненайденными.
+func (u *PostgresURLStore) RestoreUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	const op = "restore user URLs"
+	results, err := u.setDeleted(ctx, shortURLs, userID, false, domain.KeyRestored)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	return results, nil
+}
+
+// setDeleted устанавливает признак удаления сокращенным URL, добавленным указанным пользователем.
+func (u *PostgresURLStore) setDeleted(ctx context.Context, shortURLs []string, userID domain.UserID,
+	isDeleted bool, done domain.KeyStatus) ([]domain.KeyResult, error) {
+	const op = "set deleted"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	var txOptions pgx.TxOptions
+	tx, err := conn.BeginTx(ctx, txOptions)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Истекшие URL не восстанавливаются, поэтому при восстановлении они исключаются из обоих запросов.
+	now := time.Now()
+	owners, err := lockOwners(ctx, tx, shortURLs, isDeleted, now)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	const sql = `UPDATE url SET is_deleted = $1 WHERE short_url = ANY($2) AND user_id = $3
+	AND ($1 OR expires_at IS NULL OR expires_at > $4)`
+	_, err = tx.Exec(ctx, sql, isDeleted, shortURLs, uuid.UUID(userID), now)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, op)
+	}
+
+	return domain.NewKeyResults(shortURLs, owners, userID, done), nil
+}
+
+// lockOwners блокирует в транзакции строки сокращенных URL и возвращает их владельцев.
+// Если withExpired равен false, URL, срок действия которых истек к моменту now, пропускаются.
+func lockOwners(ctx context.Context, tx pgx.Tx, shortURLs []string, withExpired bool,
+	now time.Time) (map[string]domain.UserID, error) {
+	const sql = `SELECT short_url, user_id FROM url WHERE short_url = ANY($1)
+	AND ($2 OR expires_at IS NULL OR expires_at > $3) FOR UPDATE`
+	rows, err := tx.Query(ctx, sql, shortURLs, withExpired, now)
+
+	if err != nil {
+		return nil, fmt.Errorf("lock owners: %w", err)
+	}
+
+	defer rows.Close()
+
+	owners := make(map[string]domain.UserID, len(shortURLs))
+	for rows.Next() {
+		var shortURL string
+		var ownerID uuid.UUID
+
+		if err = rows.Scan(&shortURL, &ownerID); err != nil {
+			return nil, fmt.Errorf("lock owners: %w", err)
+		}
+
+		owners[shortURL] = domain.UserID(ownerID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("lock owners: %w", err)
+	}
+
+	return owners, nil
+}
+
+// DeleteExpiredURLs удаляет из хранилища сокращенные URL, срок действия которых истек к указанному моменту.
+func (u *PostgresURLStore) DeleteExpiredURLs(ctx context.Context, now time.Time) error {
+	const op = "delete expired URLs"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	const sql = "UPDATE url SET is_deleted = true WHERE expires_at <= $1 AND is_deleted = false"
+	_, err = conn.Exec(ctx, sql, now)
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	return nil
+}
+
+// ConsumeClick списывает переход по сокращенному URL с ограниченным количеством переходов. Счетчик
+// уменьшается одним запросом UPDATE ... RETURNING, поэтому одновременные переходы не превышают ограничение.
+// Если переходы исчерпаны, возвращается ошибка domain.ErrOriginalURLIsExhausted.
+func (u *PostgresURLStore) ConsumeClick(ctx context.Context, shortURL string) error {
+	const op = "consume click"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	const sql = `WITH consumed AS (
+	UPDATE url SET clicks_left = clicks_left - 1
+	WHERE short_url = $1 AND is_deleted = false AND max_clicks > 0 AND clicks_left > 0
+	RETURNING clicks_left
+)
+SELECT url.is_deleted, url.max_clicks, EXISTS (SELECT 1 FROM consumed) FROM url WHERE short_url = $1`
+	var isDeleted, isConsumed bool
+	var maxClicks int
+	err = conn.QueryRow(ctx, sql, shortURL).Scan(&isDeleted, &maxClicks, &isConsumed)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	switch {
+	case isDeleted:
+		return domain.ErrOriginalURLIsDeleted
+	case maxClicks > 0 && !isConsumed:
+		return domain.ErrOriginalURLIsExhausted
+	}
+
+	return nil
+}
+
+// AddClicks добавляет в хранилище коллекцию переходов по сокращенным URL.
+func (u *PostgresURLStore) AddClicks(ctx context.Context, clicks []domain.Click) error {
+	const op = "add clicks"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	rows := make([][]any, len(clicks))
+	for i := 0; i < len(clicks); i++ {
+		click := clicks[i]
+		rows[i] = []any{click.ShortURL, click.Timestamp, click.Referrer, click.UserAgent, click.IP, click.Variant}
+	}
+
+	columns := []string{"short_url", "clicked_at", "referrer", "user_agent", "ip", "variant"}
+	_, err = conn.CopyFrom(ctx, pgx.Identifier{"click"}, columns, pgx.CopyFromRows(rows))
+
+	if err != nil {
+		return errors.Wrapf(err, op)
+	}
+
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов по сокращенному URL, добавленному указанным пользователем.
+func (u *PostgresURLStore) GetClickStats(ctx context.Context, shortURL string,
+	userID domain.UserID) (domain.ClickStats, error) {
+	const op = "get click stats"
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return domain.ClickStats{}, errors.Wrapf(err, op)
+	}
+
+	if err = checkOwner(ctx, conn, shortURL, userID); err != nil {
+		return domain.ClickStats{}, err
+	}
+
+	const sql = `SELECT date_trunc('day', clicked_at AT TIME ZONE 'UTC') AS day, count(*) FROM click
+	WHERE short_url = $1 GROUP BY day ORDER BY day`
+	rows, err := conn.Query(ctx, sql, shortURL)
+
+	if err != nil {
+		return domain.ClickStats{}, errors.Wrapf(err, op)
+	}
+
+	defer rows.Close()
+
+	stats := domain.ClickStats{Daily: []domain.DailyClicks{}}
+	for rows.Next() {
+		var daily domain.DailyClicks
+		err = rows.Scan(&daily.Date, &daily.Count)
+
+		if err != nil {
+			return domain.ClickStats{}, errors.Wrapf(err, op)
+		}
+
+		stats.Daily = append(stats.Daily, daily)
+		stats.Total += daily.Count
+	}
+
+	if err = rows.Err(); err != nil {
+		return domain.ClickStats{}, errors.Wrapf(err, op)
+	}
+
+	if stats.Variants, err = getVariantClicks(ctx, conn, shortURL); err != nil {
+		return domain.ClickStats{}, errors.Wrapf(err, op)
+	}
+
+	return stats, nil
+}
+
+// getVariantClicks возвращает количество переходов по вариантам исходного URL в порядке имен вариантов.
+func getVariantClicks(ctx context.Context, conn *pgxpool.Conn, shortURL string) ([]domain.VariantClicks, error) {
+	const sql = `SELECT variant, count(*) FROM click WHERE short_url = $1 AND variant <> ''
+	GROUP BY variant ORDER BY variant`
+	rows, err := conn.Query(ctx, sql, shortURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("query variant clicks: %w", err)
+	}
+
+	defer rows.Close()
+
+	var variants []domain.VariantClicks
+	for rows.Next() {
+		var v domain.VariantClicks
+
+		if err = rows.Scan(&v.Variant, &v.Count); err != nil {
+			return nil, fmt.Errorf("scan variant clicks: %w", err)
+		}
+
+		variants = append(variants, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("read variant clicks: %w", err)
+	}
+
+	return variants, nil
+}
+
+// GetURLsCount возвращает количество сокращенных URL в хранилище.
+func (u *PostgresURLStore) GetURLsCount(ctx context.Context) (int, error) {
+	const op = "get URLs count"
+	count, err := u.count(ctx, "SELECT count(*) FROM url WHERE is_deleted = false")
+
+	if err != nil {
+		return 0, errors.Wrapf(err, op)
+	}
+
+	return count, nil
+}
+
+// GetUserURLsCount возвращает количество действующих URL, сокращенных пользователем: неудаленных, не истекших
+// и не исчерпавших переходы.
+func (u *PostgresURLStore) GetUserURLsCount(ctx context.Context, userID domain.UserID) (int, error) {
+	const op = "get user URLs count"
+	const sql = `SELECT count(*) FROM url WHERE user_id = $1 AND is_deleted = false
+	AND (expires_at IS NULL OR expires_at > $2) AND (max_clicks = 0 OR clicks_left > 0)`
+	count, err := u.count(ctx, sql, uuid.UUID(userID), time.Now())
+
+	if err != nil {
+		return 0, errors.Wrapf(err, op)
+	}
+
+	return count, nil
+}
+
+// GetUsersCount возвращает количество пользователей, которые сократили URL.
+func (u *PostgresURLStore) GetUsersCount(ctx context.Context) (int, error) {
+	const op = "get users count"
+	count, err := u.count(ctx, "SELECT count(DISTINCT user_id) FROM url WHERE is_deleted = false")
+
+	if err != nil {
+		return 0, errors.Wrapf(err, op)
+	}
+
+	return count, nil
+}
+
+func (u *PostgresURLStore) count(ctx context.Context, sql string, args ...any) (int, error) {
+	conn, err := u.pool.Acquire(ctx)
+	defer conn.Release()
+
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	var count int
+	if err = conn.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return count, nil
+}
+
+// checkOwner проверяет, что сокращенный URL существует и добавлен указанным пользователем.
+func checkOwner(ctx context.Context, conn *pgxpool.Conn, shortURL string, userID domain.UserID) error {
+	var ownerID uuid.UUID
+	row := conn.QueryRow(ctx, "SELECT user_id FROM url WHERE short_url=$1", shortURL)
+	err := row.Scan(&ownerID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrOriginalURLNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("check owner: %w", err)
+	}
+
+	if ownerID != uuid.UUID(userID) {
+		return domain.ErrOriginalURLNotOwned
+	}
+
+	return nil
+}
+
+func toNullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+func fromNullTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return *t
+}
+
+// toDestinationsJSON возвращает значение столбца destinations; для пустого набора вариантов - NULL.
+func toDestinationsJSON(destinations []domain.Destination) *string {
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	rows := make([]destinationJSON, len(destinations))
+	for i, d := range destinations {
+		rows[i] = destinationJSON(d)
+	}
+
+	data, _ := json.Marshal(rows) // не возвращает ошибку для структуры из строк и чисел
+	s := string(data)
+	return &s
+}
+
+func fromDestinationsJSON(s *string) ([]domain.Destination, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	var rows []destinationJSON
+	if err := json.Unmarshal([]byte(*s), &rows); err != nil {
+		return nil, fmt.Errorf("parse destinations: %w", err)
+	}
+
+	destinations := make([]domain.Destination, len(rows))
+	for i, d := range rows {
+		destinations[i] = domain.Destination(d)
+	}
+
+	return destinations, nil
+}
+
+// toRoutingRulesJSON возвращает значение столбца routing_rules; для пустого набора правил - NULL.
+func toRoutingRulesJSON(rules []domain.RoutingRule) *string {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	rows := make([]routingRuleJSON, len(rules))
+	for i, r := range rules {
+		rows[i] = routingRuleJSON(r)
+	}
+
+	data, _ := json.Marshal(rows) // не возвращает ошибку для структуры из строк
+	s := string(data)
+	return &s
+}
+
+func fromRoutingRulesJSON(s *string) ([]domain.RoutingRule, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	var rows []routingRuleJSON
+	if err := json.Unmarshal([]byte(*s), &rows); err != nil {
+		return nil, fmt.Errorf("parse routing rules: %w", err)
+	}
+
+	rules := make([]domain.RoutingRule, len(rows))
+	for i, r := range rows {
+		rules[i] = domain.RoutingRule(r)
+	}
+
+	return rules, nil
+}
+
+func toNullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func fromNullString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
 }
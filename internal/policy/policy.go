@@ -0,0 +1,344 @@
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+)
+
+// Причины нарушения политики доменов.
+var (
+	ErrDomainIsBlocked    = errors.New("domain is blocked")     // домен входит в черный список
+	ErrDomainIsNotAllowed = errors.New("domain is not allowed") // домен не входит в белый список
+)
+
+// wildcardPrefix начинает шаблон, которому соответствуют все поддомены домена.
+const wildcardPrefix = "*."
+
+// ViolationError определяет ошибку, когда домен исходного URL нарушает политику.
+type ViolationError struct {
+	reason error
+	host   string
+}
+
+// Error возвращает текст ошибки с причиной и доменом.
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("%v: %s", e.reason, e.host)
+}
+
+// Unwrap возвращает причину нарушения: ErrDomainIsBlocked или ErrDomainIsNotAllowed.
+func (e *ViolationError) Unwrap() error {
+	return e.reason
+}
+
+// Host возвращает домен, нарушивший политику.
+func (e *ViolationError) Host() string {
+	return e.host
+}
+
+// DomainPolicy проверяет домены исходных URL по черному и белому спискам. Если белый список не пуст,
+// разрешены только домены из него. Домены из черного списка запрещены, даже если входят в белый список.
+//
+// Список содержит по одному шаблону в строке: домен (example.com) или все его поддомены (*.example.com).
+// Пустые строки и строки, начинающиеся с символа «#», пропускаются.
+type DomainPolicy struct {
+	rules         atomic.Pointer[rules]
+	blocklist     listFile
+	allowlist     listFile
+	reloadMu      sync.Mutex
+	blocklistPath string
+	allowlistPath string
+}
+
+type rules struct {
+	blocklist patterns
+	allowlist patterns
+}
+
+// listFile хранит сведения о прочитанном файле списка, по которым выявляется его изменение.
+type listFile struct {
+	modTime time.Time
+	size    int64
+}
+
+// patterns содержит домены списка и домены, для которых в список включены все поддомены.
+type patterns struct {
+	domains    map[string]struct{}
+	subdomains map[string]struct{}
+}
+
+// New создает политику по черному и белому спискам шаблонов.
+func New(blocklist, allowlist []string) (*DomainPolicy, error) {
+	const op = "new domain policy"
+	blocked, err := newPatterns(blocklist)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	allowed, err := newPatterns(allowlist)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p := &DomainPolicy{}
+	p.rules.Store(&rules{blocklist: blocked, allowlist: allowed})
+	return p, nil
+}
+
+// Load создает политику по файлам черного и белого списков. Пустой путь означает пустой список.
+func Load(blocklistPath, allowlistPath string) (*DomainPolicy, error) {
+	p := &DomainPolicy{
+		blocklistPath: blocklistPath,
+		allowlistPath: allowlistPath,
+	}
+
+	if err := p.load(); err != nil {
+		return nil, fmt.Errorf("load domain policy: %w", err)
+	}
+
+	return p, nil
+}
+
+// Reload перечитывает файлы списков, если хотя бы один из них изменился. Возвращает true, если политика
+// обновлена. При ошибке чтения продолжает действовать прежняя политика.
+func (p *DomainPolicy) Reload() (bool, error) {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	blocklist, err := statListFile(p.blocklistPath)
+
+	if err != nil {
+		return false, fmt.Errorf("reload domain policy: %w", err)
+	}
+
+	allowlist, err := statListFile(p.allowlistPath)
+
+	if err != nil {
+		return false, fmt.Errorf("reload domain policy: %w", err)
+	}
+
+	if blocklist == p.blocklist && allowlist == p.allowlist {
+		return false, nil
+	}
+
+	if err = p.load(); err != nil {
+		return false, fmt.Errorf("reload domain policy: %w", err)
+	}
+
+	return true, nil
+}
+
+// Watch проверяет изменение файлов списков с указанным интервалом до закрытия канала doneCh.
+// Ошибки обновления политики записываются в журнал.
+func (p *DomainPolicy) Watch(doneCh <-chan struct{}, interval time.Duration, log *zap.Logger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-doneCh:
+				return
+			case <-ticker.C:
+				reloaded, err := p.Reload()
+
+				if err != nil {
+					log.Error(err.Error())
+					continue
+				}
+
+				if reloaded {
+					log.Info("domain policy reloaded")
+				}
+			}
+		}
+	}()
+}
+
+// Check проверяет домен по спискам. Если домен нарушает политику, возвращается ошибка *ViolationError.
+func (p *DomainPolicy) Check(host string) error {
+	host = normalizeHost(host)
+	r := p.rules.Load()
+
+	if r.blocklist.match(host) {
+		return &ViolationError{reason: ErrDomainIsBlocked, host: host}
+	}
+
+	if !r.allowlist.isEmpty() && !r.allowlist.match(host) {
+		return &ViolationError{reason: ErrDomainIsNotAllowed, host: host}
+	}
+
+	return nil
+}
+
+// CheckURL проверяет домен исходного URL по спискам. URL, который не удалось разобрать, политику не нарушает:
+// его корректность проверяется при сокращении.
+func (p *DomainPolicy) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return nil
+	}
+
+	return p.Check(u.Hostname())
+}
+
+// load читает файлы списков и заменяет действующую политику. Вызывается при удержании reloadMu
+// или до начала использования политики.
+func (p *DomainPolicy) load() error {
+	blocklist, blocked, err := readListFile(p.blocklistPath)
+
+	if err != nil {
+		return err
+	}
+
+	allowlist, allowed, err := readListFile(p.allowlistPath)
+
+	if err != nil {
+		return err
+	}
+
+	p.blocklist, p.allowlist = blocklist, allowlist
+	p.rules.Store(&rules{blocklist: blocked, allowlist: allowed})
+	return nil
+}
+
+func statListFile(path string) (listFile, error) {
+	if path == "" {
+		return listFile{}, nil
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return listFile{}, fmt.Errorf("stat list file: %w", err)
+	}
+
+	return listFile{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+func readListFile(path string) (listFile, patterns, error) {
+	if path == "" {
+		return listFile{}, patterns{}, nil
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		return listFile{}, patterns{}, fmt.Errorf("open list file: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+
+	if err != nil {
+		return listFile{}, patterns{}, fmt.Errorf("stat list file: %w", err)
+	}
+
+	lines, err := readLines(f)
+
+	if err != nil {
+		return listFile{}, patterns{}, fmt.Errorf("read list file %s: %w", path, err)
+	}
+
+	list, err := newPatterns(lines)
+
+	if err != nil {
+		return listFile{}, patterns{}, fmt.Errorf("parse list file %s: %w", path, err)
+	}
+
+	return listFile{modTime: info.ModTime(), size: info.Size()}, list, nil
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read lines: %w", err)
+	}
+
+	return lines, nil
+}
+
+func newPatterns(lines []string) (patterns, error) {
+	list := patterns{
+		domains:    make(map[string]struct{}),
+		subdomains: make(map[string]struct{}),
+	}
+
+	for i, line := range lines {
+		pattern := strings.TrimSpace(line)
+
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		target := list.domains
+		if strings.HasPrefix(pattern, wildcardPrefix) {
+			target = list.subdomains
+			pattern = strings.TrimPrefix(pattern, wildcardPrefix)
+		}
+
+		domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(pattern, "."))
+
+		if err != nil || domain == "" || strings.Contains(domain, "*") {
+			return patterns{}, fmt.Errorf("line %d: invalid pattern %q", i+1, line)
+		}
+
+		target[domain] = struct{}{}
+	}
+
+	return list, nil
+}
+
+func (l patterns) isEmpty() bool {
+	return len(l.domains) == 0 && len(l.subdomains) == 0
+}
+
+// match возвращает true, если домен входит в список или является поддоменом домена, для которого в список
+// включены все поддомены.
+func (l patterns) match(host string) bool {
+	if _, ok := l.domains[host]; ok {
+		return true
+	}
+
+	for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+
+		if _, ok := l.subdomains[host]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeHost приводит домен к виду, в котором хранятся шаблоны: в нижнем регистре, в punycode
+// и без завершающей точки.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+
+	return host
+}
@@ -0,0 +1,204 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		want      error
+		name      string
+		host      string
+		blocklist []string
+		allowlist []string
+	}{
+		{
+			name: "lists are empty",
+			host: "example.com",
+		},
+		{
+			name:      "domain is blocked",
+			host:      "Example.COM.",
+			blocklist: []string{"# comment", "", " example.com "},
+			want:      ErrDomainIsBlocked,
+		},
+		{
+			name:      "subdomain of blocked domain",
+			host:      "www.example.com",
+			blocklist: []string{"example.com"},
+		},
+		{
+			name:      "subdomains are blocked",
+			host:      "a.b.example.com",
+			blocklist: []string{"*.example.com"},
+			want:      ErrDomainIsBlocked,
+		},
+		{
+			name:      "domain of blocked subdomains",
+			host:      "example.com",
+			blocklist: []string{"*.example.com"},
+		},
+		{
+			name:      "internationalized domain is blocked",
+			host:      "xn--e1afmkfd.xn--p1ai",
+			blocklist: []string{"пример.рф"},
+			want:      ErrDomainIsBlocked,
+		},
+		{
+			name:      "domain is allowed",
+			host:      "docs.example.com",
+			allowlist: []string{"*.example.com"},
+		},
+		{
+			name:      "domain is not allowed",
+			host:      "example.org",
+			allowlist: []string{"example.com", "*.example.com"},
+			want:      ErrDomainIsNotAllowed,
+		},
+		{
+			name:      "domain is allowed and blocked",
+			host:      "bad.example.com",
+			blocklist: []string{"bad.example.com"},
+			allowlist: []string{"*.example.com"},
+			want:      ErrDomainIsBlocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut, err := New(tt.blocklist, tt.allowlist)
+			require.NoError(t, err)
+
+			err = sut.Check(tt.host)
+
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var violationErr *ViolationError
+			require.ErrorAs(t, err, &violationErr)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := New([]string{"example.com", "*.*.example.com"}, nil)
+
+		assert.ErrorContains(t, err, "line 2")
+	})
+}
+
+func TestCheckURL(t *testing.T) {
+	sut, err := New([]string{"*.example.com"}, nil)
+	require.NoError(t, err)
+
+	err = sut.CheckURL("https://www.Example.com:8080/path")
+
+	var violationErr *ViolationError
+	require.ErrorAs(t, err, &violationErr)
+	assert.Equal(t, "www.example.com", violationErr.Host())
+	assert.Equal(t, "domain is blocked: www.example.com", err.Error())
+	assert.NoError(t, sut.CheckURL("https://example.org/path"))
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("load lists from files", func(t *testing.T) {
+		blocklistPath := writeList(t, "blocklist.txt", "*.example.com\n")
+		allowlistPath := writeList(t, "allowlist.txt", "*.example.com\nexample.org\n")
+
+		sut, err := Load(blocklistPath, allowlistPath)
+
+		require.NoError(t, err)
+		assert.ErrorIs(t, sut.Check("www.example.com"), ErrDomainIsBlocked)
+		assert.ErrorIs(t, sut.Check("example.net"), ErrDomainIsNotAllowed)
+		assert.NoError(t, sut.Check("example.org"))
+	})
+
+	t.Run("list path is empty", func(t *testing.T) {
+		sut, err := Load("", "")
+
+		require.NoError(t, err)
+		assert.NoError(t, sut.Check("example.com"))
+	})
+
+	t.Run("list file does not exist", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "blocklist.txt"), "")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("list file is invalid", func(t *testing.T) {
+		_, err := Load(writeList(t, "blocklist.txt", "exa mple.com\n"), "")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestReload(t *testing.T) {
+	t.Run("reload changed list", func(t *testing.T) {
+		path := writeList(t, "blocklist.txt", "example.com\n")
+		sut, err := Load(path, "")
+		require.NoError(t, err)
+
+		reloaded, err := sut.Reload()
+		require.NoError(t, err)
+		assert.False(t, reloaded)
+
+		writeChangedList(t, path, "example.com\nexample.org\n")
+		reloaded, err = sut.Reload()
+
+		require.NoError(t, err)
+		assert.True(t, reloaded)
+		assert.ErrorIs(t, sut.Check("example.org"), ErrDomainIsBlocked)
+	})
+
+	t.Run("keep policy if list is invalid", func(t *testing.T) {
+		path := writeList(t, "blocklist.txt", "example.com\n")
+		sut, err := Load(path, "")
+		require.NoError(t, err)
+
+		writeChangedList(t, path, "*.*\n")
+		_, err = sut.Reload()
+
+		assert.Error(t, err)
+		assert.ErrorIs(t, sut.Check("example.com"), ErrDomainIsBlocked)
+	})
+
+	t.Run("watch list", func(t *testing.T) {
+		path := writeList(t, "blocklist.txt", "example.com\n")
+		sut, err := Load(path, "")
+		require.NoError(t, err)
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+
+		sut.Watch(doneCh, time.Millisecond, zap.NewNop())
+		writeChangedList(t, path, "example.org\n")
+
+		assert.Eventually(t, func() bool {
+			return sut.Check("example.org") != nil && sut.Check("example.com") == nil
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func writeList(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// writeChangedList перезаписывает файл списка и сдвигает время его изменения, чтобы изменение было
+// обнаружено независимо от точности времени файловой системы.
+func writeChangedList(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
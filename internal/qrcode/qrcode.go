@@ -0,0 +1,164 @@
+// Package qrcode формирует изображения QR-кодов в форматах PNG и SVG.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Форматы изображения QR-кода.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Ограничения и значения параметров изображения по умолчанию.
+const (
+	DefaultSize   = 256
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 32
+	DefaultLevel  = "M"
+	DefaultFormat = FormatPNG
+)
+
+// Ошибки проверки параметров изображения QR-кода.
+var (
+	ErrFormatIsInvalid = errors.New("format is invalid")          // неизвестный формат изображения
+	ErrLevelIsInvalid  = errors.New("level is invalid")           // неизвестный уровень коррекции ошибок
+	ErrSizeIsInvalid   = errors.New("size is invalid")            // размер вне допустимого диапазона
+	ErrMarginIsInvalid = errors.New("margin is invalid")          // отступ вне допустимого диапазона
+	ErrSizeIsTooSmall  = errors.New("size is too small for code") // в изображении не помещается код
+)
+
+var levels = map[string]qr.Level{
+	"L": qr.L,
+	"M": qr.M,
+	"Q": qr.Q,
+	"H": qr.H,
+}
+
+// Options задает параметры изображения QR-кода.
+type Options struct {
+	Format string // формат изображения: png или svg
+	Level  string // уровень коррекции ошибок: L, M, Q или H
+	Size   int    // ширина и высота изображения в пикселях
+	Margin int    // ширина свободной зоны вокруг кода в модулях
+}
+
+// DefaultOptions возвращает параметры изображения по умолчанию.
+func DefaultOptions() Options {
+	return Options{
+		Format: DefaultFormat,
+		Level:  DefaultLevel,
+		Size:   DefaultSize,
+		Margin: DefaultMargin,
+	}
+}
+
+// Validate проверяет параметры изображения.
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return ErrFormatIsInvalid
+	}
+
+	if _, ok := levels[strings.ToUpper(o.Level)]; !ok {
+		return ErrLevelIsInvalid
+	}
+
+	if o.Size <= 0 || o.Size > MaxSize {
+		return ErrSizeIsInvalid
+	}
+
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return ErrMarginIsInvalid
+	}
+
+	return nil
+}
+
+// ContentType возвращает MIME тип изображения для формата.
+func ContentType(format string) string {
+	if format == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+// Write кодирует текст в QR-код и записывает изображение с указанными параметрами.
+func Write(w io.Writer, text string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	code, err := qr.Encode(text, levels[strings.ToUpper(opts.Level)])
+
+	if err != nil {
+		return fmt.Errorf("encode qr code: %w", err)
+	}
+
+	modules := code.Size + 2*opts.Margin
+	if opts.Size < modules {
+		return ErrSizeIsTooSmall
+	}
+
+	if opts.Format == FormatSVG {
+		return writeSVG(w, code, opts)
+	}
+
+	return writePNG(w, code, opts)
+}
+
+func writePNG(w io.Writer, code *qr.Code, opts Options) error {
+	modules := code.Size + 2*opts.Margin
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), color.Palette{color.White, color.Black})
+
+	for y := 0; y < opts.Size; y++ {
+		my := y*modules/opts.Size - opts.Margin
+		for x := 0; x < opts.Size; x++ {
+			mx := x*modules/opts.Size - opts.Margin
+			if code.Black(mx, my) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+
+	return nil
+}
+
+func writeSVG(w io.Writer, code *qr.Code, opts Options) error {
+	modules := code.Size + 2*opts.Margin
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`shape-rendering="crispEdges">`, opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+
+	b.WriteString(`"/></svg>`)
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write svg: %w", err)
+	}
+
+	return nil
+}
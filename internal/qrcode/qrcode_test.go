@@ -0,0 +1,102 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testText = "http://localhost:8080/EwHXdJfB"
+
+func TestWrite(t *testing.T) {
+	t.Run("png", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Size = 300
+		var buf bytes.Buffer
+
+		err := Write(&buf, testText, opts)
+
+		require.NoError(t, err)
+		img, err := png.Decode(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, 300, img.Bounds().Dx())
+		assert.Equal(t, 300, img.Bounds().Dy())
+		r, g, b, _ := img.At(0, 0).RGBA()
+		assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b}, "margin is white")
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Format = FormatSVG
+		var buf bytes.Buffer
+
+		err := Write(&buf, testText, opts)
+
+		require.NoError(t, err)
+		got := buf.String()
+		assert.True(t, strings.HasPrefix(got, "<svg "))
+		assert.Contains(t, got, `width="256" height="256"`)
+		assert.True(t, strings.HasSuffix(got, "</svg>"))
+	})
+
+	t.Run("size is too small for code", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Size = 10
+		var buf bytes.Buffer
+
+		err := Write(&buf, testText, opts)
+
+		assert.ErrorIs(t, err, ErrSizeIsTooSmall)
+	})
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		want   error
+		name   string
+		modify func(o *Options)
+	}{
+		{
+			name:   "default options",
+			modify: func(o *Options) {},
+		},
+		{
+			name:   "lower case level",
+			modify: func(o *Options) { o.Level = "h" },
+		},
+		{
+			name:   "format is invalid",
+			modify: func(o *Options) { o.Format = "gif" },
+			want:   ErrFormatIsInvalid,
+		},
+		{
+			name:   "level is invalid",
+			modify: func(o *Options) { o.Level = "X" },
+			want:   ErrLevelIsInvalid,
+		},
+		{
+			name:   "size is too big",
+			modify: func(o *Options) { o.Size = MaxSize + 1 },
+			want:   ErrSizeIsInvalid,
+		},
+		{
+			name:   "margin is negative",
+			modify: func(o *Options) { o.Margin = -1 },
+			want:   ErrMarginIsInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.modify(&opts)
+
+			err := opts.Validate()
+
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package routing определяет клиента, выполняющего переход по сокращенному URL, и выбирает
+// исходный URL по правилам сокращенного URL.
+package routing
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// Операционные системы клиента.
+const (
+	OSiOS     = "ios"
+	OSAndroid = "android"
+	OSWindows = "windows"
+	OSMacOS   = "macos"
+	OSLinux   = "linux"
+)
+
+// Классы устройств клиента.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// OSes перечисляет операционные системы, которые можно указать в правиле.
+var OSes = []string{OSiOS, OSAndroid, OSWindows, OSMacOS, OSLinux}
+
+// Devices перечисляет классы устройств, которые можно указать в правиле.
+var Devices = []string{DeviceMobile, DeviceTablet, DeviceDesktop}
+
+// Client описывает клиента, выполняющего переход по сокращенному URL.
+type Client struct {
+	OS       string // операционная система; пустое значение - не определена
+	Device   string // класс устройства; пустое значение - не определен
+	Language string // предпочитаемый язык в нижнем регистре; пустое значение - не указан
+}
+
+// NewClient определяет клиента по заголовкам User-Agent и Accept-Language.
+func NewClient(userAgent, acceptLanguage string) Client {
+	os, device := ParseUserAgent(userAgent)
+	return Client{OS: os, Device: device, Language: ParseAcceptLanguage(acceptLanguage)}
+}
+
+// ParseUserAgent определяет операционную систему и класс устройства по заголовку User-Agent.
+// Для нераспознанных значений возвращаются пустые строки.
+func ParseUserAgent(userAgent string) (os, device string) {
+	switch {
+	case strings.Contains(userAgent, "iPad"):
+		return OSiOS, DeviceTablet
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPod"):
+		return OSiOS, DeviceMobile
+	case strings.Contains(userAgent, "Android"):
+		if strings.Contains(userAgent, "Mobile") {
+			return OSAndroid, DeviceMobile
+		}
+
+		return OSAndroid, DeviceTablet
+	case strings.Contains(userAgent, "Windows Phone"):
+		return "", DeviceMobile
+	case strings.Contains(userAgent, "Windows"):
+		return OSWindows, DeviceDesktop
+	case strings.Contains(userAgent, "Macintosh") || strings.Contains(userAgent, "Mac OS X"):
+		return OSMacOS, DeviceDesktop
+	case strings.Contains(userAgent, "Linux") || strings.Contains(userAgent, "X11"):
+		return OSLinux, DeviceDesktop
+	case strings.Contains(userAgent, "Mobi"):
+		return "", DeviceMobile
+	}
+
+	return "", ""
+}
+
+// ParseAcceptLanguage возвращает предпочитаемый язык из заголовка Accept-Language в нижнем регистре.
+// Языки с нулевым весом и значение "*" не учитываются. Если язык не указан, возвращается пустая строка.
+func ParseAcceptLanguage(header string) string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+
+			if err != nil {
+				continue
+			}
+
+			quality = v
+		}
+
+		if quality <= 0 {
+			continue
+		}
+
+		languages = append(languages, language{tag: tag, quality: quality})
+	}
+
+	if len(languages) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	return languages[0].tag
+}
+
+// Matches возвращает true, если клиент удовлетворяет всем условиям правила. Язык правила без
+// региона, например en, совпадает с любым региональным вариантом языка клиента, например en-us.
+func (c Client) Matches(rule domain.RoutingRule) bool {
+	if rule.OS != "" && rule.OS != c.OS {
+		return false
+	}
+
+	if rule.Device != "" && rule.Device != c.Device {
+		return false
+	}
+
+	if rule.Language != "" && !matchLanguage(rule.Language, c.Language) {
+		return false
+	}
+
+	return true
+}
+
+func matchLanguage(want, got string) bool {
+	want = strings.ToLower(want)
+	return got == want || strings.HasPrefix(got, want+"-")
+}
+
+// Match возвращает первое правило, условиям которого удовлетворяет клиент. Если ни одно правило
+// не подходит, возвращается false.
+func Match(rules []domain.RoutingRule, client Client) (domain.RoutingRule, bool) {
+	for _, rule := range rules {
+		if client.Matches(rule) {
+			return rule, true
+		}
+	}
+
+	return domain.RoutingRule{}, false
+}
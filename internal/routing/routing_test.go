@@ -0,0 +1,161 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgent  string
+		wantOS     string
+		wantDevice string
+	}{
+		{
+			name:       "iphone",
+			userAgent:  "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+			wantOS:     OSiOS,
+			wantDevice: DeviceMobile,
+		},
+		{
+			name:       "ipad",
+			userAgent:  "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+			wantOS:     OSiOS,
+			wantDevice: DeviceTablet,
+		},
+		{
+			name:       "android phone",
+			userAgent:  "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/124.0 Mobile Safari/537.36",
+			wantOS:     OSAndroid,
+			wantDevice: DeviceMobile,
+		},
+		{
+			name:       "android tablet",
+			userAgent:  "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 Chrome/124.0 Safari/537.36",
+			wantOS:     OSAndroid,
+			wantDevice: DeviceTablet,
+		},
+		{
+			name:       "windows",
+			userAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36",
+			wantOS:     OSWindows,
+			wantDevice: DeviceDesktop,
+		},
+		{
+			name:       "macos",
+			userAgent:  "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 Version/17.4 Safari/605.1.15",
+			wantOS:     OSMacOS,
+			wantDevice: DeviceDesktop,
+		},
+		{
+			name:       "linux",
+			userAgent:  "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			wantOS:     OSLinux,
+			wantDevice: DeviceDesktop,
+		},
+		{
+			name:      "unknown client",
+			userAgent: "curl/8.5.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os, device := ParseUserAgent(tt.userAgent)
+
+			assert.Equal(t, tt.wantOS, os)
+			assert.Equal(t, tt.wantDevice, device)
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{
+			name:   "single language",
+			header: "ru-RU",
+			want:   "ru-ru",
+		},
+		{
+			name:   "first language of equal quality",
+			header: "de-CH, de;q=0.9, en;q=0.8",
+			want:   "de-ch",
+		},
+		{
+			name:   "language of highest quality",
+			header: "en;q=0.5, pt-BR;q=0.9",
+			want:   "pt-br",
+		},
+		{
+			name:   "wildcard and zero quality are skipped",
+			header: "*, fr;q=0, es;q=0.3",
+			want:   "es",
+		},
+		{
+			name:   "header is empty",
+			header: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseAcceptLanguage(tt.header)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules := []domain.RoutingRule{
+		{OS: OSiOS, URL: "https://apps.apple.com/app/id1"},
+		{OS: OSAndroid, Device: DeviceMobile, URL: "https://play.google.com/store/apps"},
+		{Language: "pt-BR", URL: "https://example.com/pt"},
+		{Language: "de", URL: "https://example.com/de"},
+	}
+
+	tests := []struct {
+		name   string
+		client Client
+		want   string
+	}{
+		{
+			name:   "os matches",
+			client: Client{OS: OSiOS, Device: DeviceTablet, Language: "de"},
+			want:   "https://apps.apple.com/app/id1",
+		},
+		{
+			name:   "all conditions match",
+			client: Client{OS: OSAndroid, Device: DeviceMobile},
+			want:   "https://play.google.com/store/apps",
+		},
+		{
+			name:   "exact language matches",
+			client: Client{OS: OSAndroid, Device: DeviceTablet, Language: "pt-br"},
+			want:   "https://example.com/pt",
+		},
+		{
+			name:   "regional language matches primary language",
+			client: Client{OS: OSWindows, Language: "de-at"},
+			want:   "https://example.com/de",
+		},
+		{
+			name:   "no rule matches",
+			client: Client{OS: OSWindows, Device: DeviceDesktop, Language: "pt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Match(rules, tt.client)
+
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got.URL)
+		})
+	}
+}
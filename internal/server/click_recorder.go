@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/middleware"
+)
+
+const (
+	clickBufferSize = 1024
+	clickBatchSize  = 100
+	ipv4MaskBits    = 24
+	ipv6MaskBits    = 48
+	ipv4Bits        = 32
+	ipv6Bits        = 128
+)
+
+// ClickRecorder выполняет асинхронное сохранение переходов по сокращенным URL.
+// Переходы накапливаются в буфере и сохраняются пакетами, поэтому запись не увеличивает
+// время обработки запроса на переход.
+type ClickRecorder struct {
+	clickCh chan domain.Click
+	doneCh  <-chan struct{}
+	log     *zap.Logger
+}
+
+// NewClickRecorder создает ClickRecorder, который сохраняет накопленные переходы с указанным интервалом
+// или при заполнении пакета. При закрытии канала doneCh оставшиеся переходы сохраняются.
+func NewClickRecorder(ctx context.Context, doneCh <-chan struct{}, store domain.URLStore,
+	flushInterval time.Duration, log *zap.Logger) *ClickRecorder {
+	r := &ClickRecorder{
+		clickCh: make(chan domain.Click, clickBufferSize),
+		doneCh:  doneCh,
+		log:     log,
+	}
+
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		clicks := make([]domain.Click, 0, clickBatchSize)
+		flush := func() {
+			if len(clicks) == 0 {
+				return
+			}
+
+			if err := store.AddClicks(ctx, clicks); err != nil {
+				log.Error(err.Error())
+			}
+
+			clicks = make([]domain.Click, 0, clickBatchSize)
+		}
+
+		for {
+			select {
+			case <-r.doneCh:
+				for len(r.clickCh) > 0 {
+					clicks = append(clicks, <-r.clickCh)
+				}
+				flush()
+				return
+			case click := <-r.clickCh:
+				clicks = append(clicks, click)
+
+				if len(clicks) == clickBatchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+
+	return r
+}
+
+// Record добавляет переход в буфер на сохранение. Если буфер заполнен, переход отбрасывается.
+func (r *ClickRecorder) Record(click domain.Click) {
+	select {
+	case <-r.doneCh:
+		return
+	default:
+	}
+
+	select {
+	case r.clickCh <- click:
+	default:
+		r.log.Warn("click buffer is full", zap.String("short_url", click.ShortURL))
+	}
+}
+
+func newClick(r *http.Request, shortURL string, trustedProxy *net.IPNet) domain.Click {
+	return domain.Click{
+		ShortURL:  shortURL,
+		Timestamp: time.Now(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        anonymizeIP(middleware.ClientIP(r, trustedProxy)),
+	}
+}
+
+// anonymizeIP обнуляет младшие биты адреса: последний октет для IPv4 и все, кроме префикса /48, для IPv6.
+func anonymizeIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(ipv4MaskBits, ipv4Bits)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(ipv6MaskBits, ipv6Bits)).String()
+}
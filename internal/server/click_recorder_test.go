@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+)
+
+func TestClickRecorder(t *testing.T) {
+	t.Run("record clicks", func(t *testing.T) {
+		ctx := context.Background()
+		store := inmemory.New()
+		userID := domain.NewUserID()
+		pair := domain.URLPair{
+			OriginalURL: "http://yandex.ru",
+			ShortURL:    "123",
+		}
+		err := store.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+		sut := NewClickRecorder(ctx, doneCh, store, time.Millisecond, zap.NewNop())
+
+		sut.Record(domain.Click{ShortURL: pair.ShortURL, Timestamp: time.Now()})
+		sut.Record(domain.Click{ShortURL: pair.ShortURL, Timestamp: time.Now()})
+
+		assert.Eventually(t, func() bool {
+			stats, err := store.GetClickStats(ctx, pair.ShortURL, userID)
+			return err == nil && stats.Total == 2
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("flush clicks on close", func(t *testing.T) {
+		ctx := context.Background()
+		store := inmemory.New()
+		userID := domain.NewUserID()
+		pair := domain.URLPair{
+			OriginalURL: "http://yandex.ru",
+			ShortURL:    "123",
+		}
+		err := store.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		doneCh := make(chan struct{})
+		sut := NewClickRecorder(ctx, doneCh, store, time.Hour, zap.NewNop())
+
+		sut.Record(domain.Click{ShortURL: pair.ShortURL, Timestamp: time.Now()})
+		close(doneCh)
+
+		assert.Eventually(t, func() bool {
+			stats, err := store.GetClickStats(ctx, pair.ShortURL, userID)
+			return err == nil && stats.Total == 1
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestNewClick(t *testing.T) {
+	_, trustedProxy, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		want       string
+	}{
+		{
+			name:       "ipv4 from remote address",
+			remoteAddr: "192.168.1.42:5555",
+			want:       "192.168.1.0",
+		},
+		{
+			name:       "ipv4 from real ip header of trusted proxy",
+			remoteAddr: "10.0.0.1:5555",
+			realIP:     "203.0.113.77",
+			want:       "203.0.113.0",
+		},
+		{
+			name:       "real ip header of untrusted address is ignored",
+			remoteAddr: "192.168.1.42:5555",
+			realIP:     "203.0.113.77",
+			want:       "192.168.1.0",
+		},
+		{
+			name:       "ipv6",
+			remoteAddr: "[2001:db8:85a3:1:2:8a2e:370:7334]:5555",
+			want:       "2001:db8:85a3::",
+		},
+		{
+			name:       "invalid address",
+			remoteAddr: "unknown",
+			want:       "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			r.Header.Set("Referer", "http://ya.ru")
+			r.Header.Set("User-Agent", "curl/8.0")
+
+			got := newClick(r, "abc", trustedProxy)
+
+			assert.Equal(t, tt.want, got.IP)
+			assert.Equal(t, "abc", got.ShortURL)
+			assert.Equal(t, "http://ya.ru", got.Referrer)
+			assert.Equal(t, "curl/8.0", got.UserAgent)
+		})
+	}
+
+	t.Run("anonymize nil ip", func(t *testing.T) {
+		assert.Equal(t, "", anonymizeIP(net.IP(nil)))
+	})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+const cacheControlHeader = "Cache-Control"
+
+// Состояния сокращенного URL в ответе на запрос набора URL пользователя.
+const (
+	urlStatusActive    = "active"    // переход выполняется
+	urlStatusScheduled = "scheduled" // сокращенный URL еще не начал действовать
+	urlStatusExpired   = "expired"   // срок действия сокращенного URL истек
+	urlStatusExhausted = "exhausted" // переходы по сокращенному URL исчерпаны
+)
+
+// urlStatus возвращает состояние сокращенного URL на указанный момент времени.
+func urlStatus(pair domain.URLPair, now time.Time) string {
+	switch {
+	case pair.IsScheduled(now):
+		return urlStatusScheduled
+	case pair.IsExpired(now):
+		return urlStatusExpired
+	case pair.IsExhausted():
+		return urlStatusExhausted
+	default:
+		return urlStatusActive
+	}
+}
+
+// writeNotActive отвечает на переход по сокращенному URL, который еще не начал действовать. Если задана
+// страница «скоро», она возвращается с запретом кеширования, иначе возвращается ответ 404, чтобы
+// не раскрывать существование сокращенного URL.
+func (s *Server) writeNotActive(w http.ResponseWriter) {
+	if s.comingSoonPage == nil {
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, textHTML)
+	w.Header().Set(cacheControlHeader, "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(s.comingSoonPage)
+}
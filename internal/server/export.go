@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// Форматы выгрузки URL пользователя.
+const (
+	exportFormatCSV    = "csv"
+	exportFormatJSON   = "json"
+	exportFormatNDJSON = "ndjson"
+)
+
+const (
+	textCSV                  = "text/csv"
+	applicationNDJSON        = "application/x-ndjson"
+	contentDispositionHeader = "Content-Disposition"
+	csvTagsSeparator         = ";"
+)
+
+// errExportFormatIsInvalid возвращается, если запрошен неизвестный формат выгрузки.
+var errExportFormatIsInvalid = errors.New("export format is invalid")
+
+// csvExportHeader перечисляет столбцы выгрузки в формате CSV. Первые столбцы совпадают с форматом импорта.
+var csvExportHeader = []string{"short_url", "original_url", "tags", "expires_at", "title", "created_at", "is_deleted"}
+
+// ExportedURL содержит сокращенный URL и его метаданные. Возвращается в выгрузке URL пользователя.
+type ExportedURL struct {
+	CreatedAt   time.Time  `json:"created_at"`           // время создания сокращенного URL
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // время окончания действия сокращенного URL
+	ShortURL    string     `json:"short_url"`            // сокращенный URL
+	OriginalURL string     `json:"original_url"`         // исходный URL
+	Title       string     `json:"title,omitempty"`      // заголовок сокращенного URL
+	Tags        []string   `json:"tags,omitempty"`       // метки сокращенного URL
+	IsDeleted   bool       `json:"is_deleted"`           // признак удаленного URL
+}
+
+// urlExporter записывает выгрузку URL по мере их чтения из хранилища.
+type urlExporter interface {
+	// Write записывает очередной URL.
+	Write(u ExportedURL) error
+	// Close завершает выгрузку и записывает оставшиеся данные.
+	Close() error
+}
+
+// newURLExporter создает компонент записи выгрузки в указанном формате. Возвращает также MIME тип выгрузки.
+func newURLExporter(format string, w io.Writer) (urlExporter, string, error) {
+	switch format {
+	case exportFormatCSV:
+		return &csvExporter{w: csv.NewWriter(w)}, textCSV, nil
+	case exportFormatJSON:
+		bw := bufio.NewWriter(w)
+		return &jsonExporter{w: bw, enc: json.NewEncoder(bw)}, applicationJSON, nil
+	case exportFormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonExporter{w: bw, enc: json.NewEncoder(bw)}, applicationNDJSON, nil
+	default:
+		return nil, "", errExportFormatIsInvalid
+	}
+}
+
+type csvExporter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvExporter) Write(u ExportedURL) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	var expiresAt string
+	if u.ExpiresAt != nil {
+		expiresAt = u.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	err := e.w.Write([]string{
+		u.ShortURL,
+		u.OriginalURL,
+		strings.Join(u.Tags, csvTagsSeparator),
+		expiresAt,
+		u.Title,
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatBool(u.IsDeleted),
+	})
+
+	if err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+
+	return nil
+}
+
+func (e *csvExporter) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+
+	e.headerWritten = true
+	if err := e.w.Write(csvExportHeader); err != nil {
+		return fmt.Errorf("write csv header: %w", err)
+	}
+
+	return nil
+}
+
+func (e *csvExporter) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return fmt.Errorf("flush csv: %w", err)
+	}
+
+	return nil
+}
+
+// jsonExporter записывает выгрузку в виде JSON массива, не накапливая его в памяти.
+type jsonExporter struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func (e *jsonExporter) Write(u ExportedURL) error {
+	delim := ","
+	if e.count == 0 {
+		delim = "["
+	}
+
+	e.count++
+	if _, err := e.w.WriteString(delim); err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+
+	if err := e.enc.Encode(u); err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+
+	return nil
+}
+
+func (e *jsonExporter) Close() error {
+	end := "]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+
+	if _, err := e.w.WriteString(end); err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("flush json: %w", err)
+	}
+
+	return nil
+}
+
+type ndjsonExporter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(u ExportedURL) error {
+	if err := e.enc.Encode(u); err != nil {
+		return fmt.Errorf("write ndjson: %w", err)
+	}
+
+	return nil
+}
+
+func (e *ndjsonExporter) Close() error {
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("flush ndjson: %w", err)
+	}
+
+	return nil
+}
+
+// exportUserURLs выгружает все URL пользователя, включая удаленные, в формате csv, json или ndjson.
+// URL записываются в ответ по мере чтения из хранилища. Если ошибка чтения возникла после начала
+// выгрузки, ответ обрывается, а ошибка записывается в журнал.
+func (s *Server) exportUserURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+
+	exporter, contentType, err := newURLExporter(format, w)
+
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	writeHeaders := func() {
+		w.Header().Set(contentTypeHeader, contentType)
+		w.Header().Set(contentDispositionHeader, fmt.Sprintf("attachment; filename=\"urls.%s\"", format))
+	}
+
+	exported := 0
+	err = s.service.ExportUserURLs(ctx, user.ID, func(pair domain.URLPair, isDeleted bool) error {
+		if exported == 0 {
+			writeHeaders()
+		}
+
+		exported++
+		return exporter.Write(s.newExportedURL(pair, isDeleted))
+	})
+
+	if err != nil && exported == 0 {
+		internalError(w, "failed to export user urls")
+		return
+	}
+
+	if err != nil {
+		s.logger.Error("failed to export user urls", zap.Error(err))
+		return
+	}
+
+	if exported == 0 {
+		writeHeaders()
+	}
+
+	if err = exporter.Close(); err != nil {
+		s.logger.Error("failed to export user urls", zap.Error(err))
+	}
+}
+
+func (s *Server) newExportedURL(pair domain.URLPair, isDeleted bool) ExportedURL {
+	u := ExportedURL{
+		ShortURL:    joinPath(s.baseURL, pair.ShortURL),
+		OriginalURL: pair.OriginalURL,
+		Title:       pair.Title,
+		Tags:        pair.Tags,
+		CreatedAt:   pair.CreatedAt.UTC(),
+		IsDeleted:   isDeleted,
+	}
+
+	if !pair.ExpiresAt.IsZero() {
+		expiresAt := pair.ExpiresAt.UTC()
+		u.ExpiresAt = &expiresAt
+	}
+
+	return u
+}
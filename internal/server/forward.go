@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nestjam/yap-shortener/internal/routing"
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+const acceptLanguageHeader = "Accept-Language"
+
+// qrCodePath - суффикс пути /{key}/qr, зарезервированный за QR-кодом сокращенного URL. Сокращенный URL
+// с передачей суффикса пути не передает этот суффикс исходному URL: запрос с ним отклоняется, в том числе
+// в запросе разблокировки и в экранированном виде.
+const qrCodePath = "qr"
+
+// newExpandRequest формирует запрос перехода по сокращенному URL с данными клиента из заголовков
+// User-Agent и Accept-Language, вариантом, закрепленным за клиентом, суффиксом пути после ключа
+// и строкой запроса перехода.
+func newExpandRequest(r *http.Request, key, password string) service.ExpandRequest {
+	return service.ExpandRequest{
+		Client:   routing.NewClient(r.UserAgent(), r.Header.Get(acceptLanguageHeader)),
+		Password: password,
+		Variant:  stickyVariant(r, key),
+		Path:     forwardedPath(r, key),
+		Query:    r.URL.RawQuery,
+	}
+}
+
+// isReservedPath возвращает true, если суффикс пути зарезервирован за QR-кодом сокращенного URL.
+func isReservedPath(path string) bool {
+	unescaped, err := url.PathUnescape(path)
+	return err == nil && unescaped == qrCodePath
+}
+
+// forwardedPath возвращает суффикс пути запроса /{key}/... в экранированном виде. Для запроса
+// без суффикса возвращается пустая строка.
+func forwardedPath(r *http.Request, key string) string {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/"+key)
+	return strings.TrimPrefix(path, "/")
+}
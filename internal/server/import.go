@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+const (
+	multipartFormData = "multipart/form-data"
+	importFileField   = "file"
+	importMaxBytes    = 64 << 20
+)
+
+// Ошибки разбора файла импорта.
+var (
+	errImportFileIsMissing = errors.New("import file is missing")                       // в форме нет файла
+	errImportRowIsShort    = errors.New("row must contain short_url and original_url")  // в строке меньше двух столбцов
+	errExpiresAtIsInvalid  = errors.New("expires_at must be in RFC 3339 format")        // время окончания не разобрано
+	errImportCSVIsInvalid  = errors.New("import file is not a valid csv")               // файл не разобран
+	errImportFileIsTooBig  = fmt.Errorf("import file exceeds %d bytes", importMaxBytes) // файл превышает лимит
+)
+
+// ImportResponse содержит итог импорта URL. Возвращается в ответе на запрос импорта.
+type ImportResponse struct {
+	Imported int           `json:"imported"`         // количество добавленных URL
+	Failed   int           `json:"failed"`           // количество пропущенных строк
+	Errors   []ImportError `json:"errors,omitempty"` // причины пропуска строк в порядке следования
+}
+
+// ImportQuotaExceeded содержит ошибку превышения квоты и итог импорта строк, обработанных до превышения.
+// Строки, следующие за порцией, которая не уместилась в квоту, не обрабатываются.
+type ImportQuotaExceeded struct {
+	QuotaExceeded
+	ImportResponse
+}
+
+// ImportError описывает строку файла импорта, которая не была добавлена.
+type ImportError struct {
+	Line     int    `json:"line"`                // номер строки файла
+	ShortURL string `json:"short_url,omitempty"` // ключ сокращенного URL
+	Status   string `json:"status"`              // причина: invalid, key_taken или url_exists
+	Message  string `json:"message,omitempty"`   // описание ошибки проверки данных
+}
+
+func (r *ImportResponse) add(line int, result service.ImportResult) {
+	if result.Status == domain.KeyImported {
+		r.Imported++
+		return
+	}
+
+	importErr := ImportError{Line: line, ShortURL: result.ShortURL, Status: string(result.Status)}
+	if result.Err != nil {
+		importErr.Message = result.Err.Error()
+	}
+
+	r.Failed++
+	r.Errors = append(r.Errors, importErr)
+}
+
+// sorted упорядочивает причины пропуска строк по номеру строки и возвращает итог импорта.
+func (r ImportResponse) sorted() ImportResponse {
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		return r.Errors[i].Line < r.Errors[j].Line
+	})
+
+	return r
+}
+
+// importUserURLs добавляет URL из CSV файла, переданного в поле file формы multipart/form-data.
+// Строка файла содержит столбцы short_url,original_url[,tags,expires_at]; метки разделяются символом «;»,
+// а время окончания действия задается в формате RFC 3339. Заголовок файла и лишние столбцы пропускаются,
+// поэтому файл выгрузки в формате CSV может быть импортирован без изменений.
+func (s *Server) importUserURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	file, err := openImportFile(r)
+
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	resp, err := s.importCSV(ctx, file, user.ID)
+
+	var quotaExceeded *service.QuotaExceededError
+	if errors.As(err, &quotaExceeded) {
+		writeJSON(w, http.StatusForbidden, ImportQuotaExceeded{
+			QuotaExceeded: QuotaExceeded{
+				Error:     service.ErrQuotaExceeded.Error(),
+				UserQuota: UserQuota(quotaExceeded.Quota()),
+			},
+			ImportResponse: resp,
+		})
+		return
+	}
+
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeImportError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, errImportFileIsTooBig.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if errors.Is(err, errImportFileIsMissing) || errors.Is(err, errImportCSVIsInvalid) {
+		badRequest(w, err.Error())
+		return
+	}
+
+	internalError(w, "failed to import urls")
+}
+
+// openImportFile возвращает содержимое поля file формы без сохранения всего запроса в память.
+func openImportFile(r *http.Request) (io.Reader, error) {
+	reader, err := r.MultipartReader()
+
+	if err != nil {
+		return nil, errImportFileIsMissing
+	}
+
+	for {
+		part, err := reader.NextPart()
+
+		if errors.Is(err, io.EOF) {
+			return nil, errImportFileIsMissing
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("open import file: %w", err)
+		}
+
+		if part.FormName() == importFileField {
+			return part, nil
+		}
+	}
+}
+
+// importCSV читает строки файла и импортирует их порциями по service.ImportChunkSize.
+// Если порция не умещается в квоту пользователя, возвращается итог импорта предыдущих порций
+// и ошибка service.QuotaExceededError.
+func (s *Server) importCSV(ctx context.Context, r io.Reader, userID domain.UserID) (ImportResponse, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var resp ImportResponse
+	reqs := make([]service.ShortenRequest, 0, service.ImportChunkSize)
+	lines := make([]int, 0, service.ImportChunkSize)
+
+	flush := func() error {
+		results, err := s.service.ImportURLs(ctx, reqs, userID)
+
+		for i, result := range results {
+			resp.add(lines[i], result)
+		}
+
+		if err != nil {
+			return fmt.Errorf("import csv: %w", err)
+		}
+
+		reqs, lines = reqs[:0], lines[:0]
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return ImportResponse{}, fmt.Errorf("%w: %v", errImportCSVIsInvalid, parseErr)
+		}
+
+		if err != nil {
+			return ImportResponse{}, fmt.Errorf("import csv: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if line == 1 && record[0] == csvExportHeader[0] {
+			continue
+		}
+
+		req, err := s.newImportRequest(record)
+
+		if err != nil {
+			resp.add(line, service.ImportResult{ShortURL: req.Alias, Status: domain.KeyInvalid, Err: err})
+			continue
+		}
+
+		reqs = append(reqs, req)
+		lines = append(lines, line)
+
+		if len(reqs) == service.ImportChunkSize {
+			if err = flush(); err != nil {
+				return resp.sorted(), err
+			}
+		}
+	}
+
+	if len(reqs) > 0 {
+		if err := flush(); err != nil {
+			return resp.sorted(), err
+		}
+	}
+
+	return resp.sorted(), nil
+}
+
+// newImportRequest формирует запрос импорта из строки файла. Ключ может быть задан в виде сокращенного URL
+// с базовым адресом сервиса.
+func (s *Server) newImportRequest(record []string) (service.ShortenRequest, error) {
+	req := service.ShortenRequest{Alias: strings.TrimPrefix(record[0], s.baseURL+"/")}
+
+	if len(record) < 2 {
+		return req, errImportRowIsShort
+	}
+
+	req.URL = record[1]
+
+	if len(record) > 2 && record[2] != "" {
+		req.Tags = strings.Split(record[2], csvTagsSeparator)
+	}
+
+	if len(record) > 3 && record[3] != "" {
+		expiresAt, err := time.Parse(time.RFC3339, record[3])
+
+		if err != nil {
+			return req, errExpiresAtIsInvalid
+		}
+
+		req.ExpiresAt = &expiresAt
+	}
+
+	return req, nil
+}
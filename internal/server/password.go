@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+const (
+	textHTML             = "text/html; charset=utf-8"
+	applicationForm      = "application/x-www-form-urlencoded"
+	passwordFormField    = "password"
+	passwordFormMaxBytes = 4096
+)
+
+// passwordForm - HTML форма ввода пароля для перехода по защищенному сокращенному URL.
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Password required</title>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>This link is protected with a password.</p>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="password" name="password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// passwordFormData содержит данные для отображения формы ввода пароля.
+type passwordFormData struct {
+	Action string // адрес отправки формы: путь и строка запроса перехода по сокращенному URL
+	Error  string // сообщение о неудачной попытке ввода пароля
+}
+
+// unlock проверяет пароль, отправленный из формы, и перенаправляет на исходный URL. Перенаправление всегда
+// выполняется с кодом 303, чтобы браузер не отправил форму с паролем повторно на исходный URL.
+func (s *Server) unlock(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	r.Body = http.MaxBytesReader(w, r.Body, passwordFormMaxBytes)
+
+	if err := r.ParseForm(); err != nil {
+		badRequest(w, failedToParseRequestMessage)
+		return
+	}
+
+	req := newExpandRequest(r, key, r.PostForm.Get(passwordFormField))
+	if isReservedPath(req.Path) {
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
+		return
+	}
+
+	ctx := r.Context()
+	target, err := s.service.ExpandTarget(ctx, key, req)
+
+	if errors.Is(err, domain.ErrOriginalURLIsNotActive) {
+		s.writeNotActive(w)
+		return
+	}
+
+	if errors.Is(err, service.ErrPasswordRequired) {
+		writePasswordForm(w, http.StatusOK, r.URL.RequestURI(), "")
+		return
+	}
+
+	if errors.Is(err, service.ErrPasswordIsInvalid) {
+		writePasswordForm(w, http.StatusForbidden, r.URL.RequestURI(), service.ErrPasswordIsInvalid.Error())
+		return
+	}
+
+	if errors.Is(err, service.ErrTooManyAttempts) {
+		http.Error(w, service.ErrTooManyAttempts.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err != nil {
+		writeExpandError(w, err)
+		return
+	}
+
+	s.recordClick(r, key, target.Variant)
+	setStickyVariant(w, key, target)
+	setRedirectCacheControl(w, http.StatusSeeOther, false)
+	http.Redirect(w, r, target.URL, http.StatusSeeOther)
+}
+
+// writePasswordForm записывает ответ с формой ввода пароля, которая отправляется по адресу action.
+func writePasswordForm(w http.ResponseWriter, status int, action, message string) {
+	w.Header().Set(contentTypeHeader, textHTML)
+	w.WriteHeader(status)
+	_ = passwordForm.Execute(w, passwordFormData{Action: action, Error: message})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const previewQueryParam = "preview"
+
+// previewPage - HTML страница предварительного просмотра сокращенного URL.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+<p>This link leads to <code>{{.OriginalURL}}</code></p>
+<p>Created {{.CreatedAt}}</p>
+<p><a href="{{.ShortURL}}">Continue</a></p>
+</body>
+</html>
+`))
+
+// previewPageData содержит данные для отображения страницы предварительного просмотра.
+type previewPageData struct {
+	ShortURL    string // сокращенный URL с базовым адресом сервера
+	OriginalURL string // исходный URL
+	Title       string // заголовок, заданный владельцем
+	CreatedAt   string // время создания сокращенного URL
+}
+
+// preview отображает страницу с исходным URL вместо перенаправления. Для защищенного паролем URL
+// отображается форма ввода пароля, чтобы не раскрывать исходный URL. URL с запрещенным доменом
+// не отображается.
+func (s *Server) preview(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSuffix(chi.URLParam(r, "key"), "+")
+	pair, err := s.service.PreviewURL(r.Context(), key)
+
+	if err != nil {
+		writeExpandError(w, err)
+		return
+	}
+
+	if pair.IsScheduled(time.Now()) {
+		s.writeNotActive(w)
+		return
+	}
+
+	if pair.IsProtected() {
+		writePasswordForm(w, http.StatusOK, joinPath(s.baseURL, key), "")
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, textHTML)
+	w.WriteHeader(http.StatusOK)
+	_ = previewPage.Execute(w, previewPageData{
+		ShortURL:    joinPath(s.baseURL, key),
+		OriginalURL: pair.OriginalURL,
+		Title:       pair.Title,
+		CreatedAt:   pair.CreatedAt.UTC().Format(time.DateTime + " MST"),
+	})
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+// permanentRedirectMaxAge - время, в течение которого клиент может повторно использовать постоянное
+// перенаправление без обращения к серверу.
+const permanentRedirectMaxAge = 24 * time.Hour
+
+// writeRedirect перенаправляет клиента на адрес перехода с кодом, заданным для сокращенного URL, или
+// с кодом сервера по умолчанию. Постоянное перенаправление разрешается кешировать только клиенту и только
+// для URL, переход по которому не зависит от времени, счетчика переходов, пароля и данных клиента.
+// Временное перенаправление не кешируется.
+func (s *Server) writeRedirect(w http.ResponseWriter, r *http.Request, target service.Target) {
+	status := target.RedirectStatus
+	if status == 0 {
+		status = s.redirectStatus
+	}
+
+	setRedirectCacheControl(w, status, target.Cacheable)
+	http.Redirect(w, r, target.URL, status)
+}
+
+func setRedirectCacheControl(w http.ResponseWriter, status int, cacheable bool) {
+	if cacheable && service.IsPermanentRedirect(status) {
+		w.Header().Set(cacheControlHeader, fmt.Sprintf("private, max-age=%d", int(permanentRedirectMaxAge.Seconds())))
+		return
+	}
+
+	w.Header().Set(cacheControlHeader, "no-store")
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	customctx "github.com/nestjam/yap-shortener/internal/context"
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// RoutingRule содержит правило выбора исходного URL в зависимости от клиента. Пустое условие выполняется
+// для любого клиента; правила проверяются по порядку, переход выполняется по первому подходящему правилу.
+type RoutingRule struct {
+	OS       string `json:"os,omitempty"`       // операционная система: ios, android, windows, macos или linux
+	Device   string `json:"device,omitempty"`   // класс устройства: mobile, tablet или desktop
+	Language string `json:"language,omitempty"` // язык клиента из Accept-Language, например en или pt-BR
+	URL      string `json:"url"`                // исходный URL, на который выполняется переход
+}
+
+// URLRules содержит правила выбора исходного URL. Применяется в запросе и ответе на запрос изменения правил.
+type URLRules struct {
+	Rules []RoutingRule `json:"rules"` // правила выбора исходного URL
+}
+
+// newRoutingRules преобразует правила выбора исходного URL из запроса в правила сервиса.
+func newRoutingRules(rules []RoutingRule) []domain.RoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	resp := make([]domain.RoutingRule, len(rules))
+	for i, rule := range rules {
+		resp[i] = domain.RoutingRule(rule)
+	}
+
+	return resp
+}
+
+// toRoutingRules преобразует правила выбора исходного URL сервиса в правила ответа.
+func toRoutingRules(rules []domain.RoutingRule) []RoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	resp := make([]RoutingRule, len(rules))
+	for i, rule := range rules {
+		resp[i] = RoutingRule(rule)
+	}
+
+	return resp
+}
+
+func (s *Server) updateRoutingRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req URLRules
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, failedToParseRequestMessage)
+		return
+	}
+
+	rules, err := s.service.UpdateRoutingRules(ctx, chi.URLParam(r, "key"), newRoutingRules(req.Rules), user.ID)
+
+	if err != nil {
+		writeUserURLError(w, err, "failed to update routing rules")
+		return
+	}
+
+	resp := toRoutingRules(rules)
+	if resp == nil {
+		resp = []RoutingRule{}
+	}
+
+	writeJSON(w, http.StatusOK, URLRules{Rules: resp})
+}
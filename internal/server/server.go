@@ -1,24 +1,29 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	chimiddleware "github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/nestjam/yap-shortener/internal/auth"
+	"github.com/nestjam/yap-shortener/internal/canonical"
 	customctx "github.com/nestjam/yap-shortener/internal/context"
 	"github.com/nestjam/yap-shortener/internal/domain"
 	"github.com/nestjam/yap-shortener/internal/middleware"
-	"github.com/nestjam/yap-shortener/internal/shortener"
+	"github.com/nestjam/yap-shortener/internal/policy"
+	"github.com/nestjam/yap-shortener/internal/qrcode"
+	"github.com/nestjam/yap-shortener/internal/service"
 )
 
 const (
@@ -28,29 +33,94 @@ const (
 	textPlain                      = "text/plain"
 	applicationJSON                = "application/json"
 	applicationGZIP                = "application/x-gzip"
-	urlIsEmptyMessage              = "url is empty"
-	batchIsEmptyMessage            = "batch is empty"
 	failedToWriterResponseMessage  = "failed to prepare response"
 	failedToStoreURLMessage        = "failed to store url"
 	failedToParseRequestMessage    = "failed to parse request"
 	failedToPrepareResponseMessage = "failed to prepare response"
-	secretKey                      = "supersecretkey"
-	tokenExp                       = time.Hour * 3
+	aliasIsTakenMessage            = "alias is already taken"
+	aliasQueryParam                = "alias"
+	nextCursorHeader               = "X-Next-Cursor"
+	apiUserJobsPath                = "/api/user/jobs"
 )
 
 // Server предоставляет возможность сокращать URL, получать исходный и управлять сокращенными URL.
 type Server struct {
 	logger              *zap.Logger
 	urlRemover          *URLRemover
+	clickRecorder       *ClickRecorder
+	authorizer          *auth.UserAuth
+	service             *service.URLService
+	trustedSubnet       *net.IPNet
+	trustedProxy        *net.IPNet
+	canonicalizer       *canonical.Canonicalizer
+	domainPolicy        *policy.DomainPolicy
 	store               domain.URLStore
+	rateLimits          RateLimits
+	quotas              service.Quotas
+	comingSoonPage      []byte
 	router              chi.Router
 	baseURL             string
 	shortenURLsMaxCount int
+	redirectStatus      int
 }
 
 // ShortenRequest представляет тело запроса и содержит исходный URL.
 type ShortenRequest struct {
-	URL string `json:"url"` // исходный URL
+	ExpiresAt      *time.Time    `json:"expires_at,omitempty"`      // время окончания действия сокращенного URL
+	ActiveFrom     *time.Time    `json:"active_from,omitempty"`     // время начала действия сокращенного URL
+	URL            string        `json:"url"`                       // исходный URL
+	Alias          string        `json:"alias,omitempty"`           // ключ сокращенного URL, выбранный пользователем
+	TTL            int64         `json:"ttl,omitempty"`             // время жизни сокращенного URL в секундах
+	Password       string        `json:"password,omitempty"`        // пароль для перехода по сокращенному URL
+	Title          string        `json:"title,omitempty"`           // заголовок сокращенного URL
+	Tags           []string      `json:"tags,omitempty"`            // метки сокращенного URL
+	MaxClicks      int           `json:"max_clicks,omitempty"`      // максимальное количество переходов по сокращенному URL
+	Destinations   []Destination `json:"destinations,omitempty"`    // варианты исходного URL; задаются вместо url
+	Rotation       string        `json:"rotation,omitempty"`        // режим выбора варианта: random или sticky
+	Rules          []RoutingRule `json:"rules,omitempty"`           // правила выбора исходного URL по клиенту
+	ForwardPath    bool          `json:"forward_path,omitempty"`    // признак передачи суффикса пути /{key}/... в исходный URL
+	RedirectStatus int           `json:"redirect_status,omitempty"` // код перенаправления: 301, 302, 307 или 308
+}
+
+// Destination содержит вариант исходного URL, между которыми распределяются переходы по сокращенному URL.
+type Destination struct {
+	Name   string `json:"name,omitempty"` // имя варианта для статистики; по умолчанию - порядковый номер
+	URL    string `json:"url"`            // исходный URL варианта
+	Weight int    `json:"weight"`         // вес варианта; доля переходов пропорциональна весу
+}
+
+// toService преобразует запрос сокращения URL в запрос сервиса.
+func (req ShortenRequest) toService() service.ShortenRequest {
+	return service.ShortenRequest{
+		ExpiresAt:      req.ExpiresAt,
+		ActiveFrom:     req.ActiveFrom,
+		URL:            req.URL,
+		Alias:          req.Alias,
+		TTL:            req.TTL,
+		Password:       req.Password,
+		Title:          req.Title,
+		Tags:           req.Tags,
+		MaxClicks:      req.MaxClicks,
+		Destinations:   newDestinations(req.Destinations),
+		Rotation:       req.Rotation,
+		Rules:          newRoutingRules(req.Rules),
+		ForwardPath:    req.ForwardPath,
+		RedirectStatus: req.RedirectStatus,
+	}
+}
+
+// newDestinations преобразует варианты исходного URL из запроса в варианты сервиса.
+func newDestinations(destinations []Destination) []domain.Destination {
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	resp := make([]domain.Destination, len(destinations))
+	for i, d := range destinations {
+		resp[i] = domain.Destination(d)
+	}
+
+	return resp
 }
 
 // ShortenResponse содержит сокращенный URL.
@@ -60,8 +130,20 @@ type ShortenResponse struct {
 
 // OriginalURL содержит исходный URL. Применяется в запросе сокращения набора URL.
 type OriginalURL struct {
-	CorrelationID string `json:"correlation_id"` // идентификатор для сопоставления исходного и сокращенного URL
-	URL           string `json:"original_url"`   // исходный URL
+	ExpiresAt      *time.Time    `json:"expires_at,omitempty"`      // время окончания действия сокращенного URL
+	ActiveFrom     *time.Time    `json:"active_from,omitempty"`     // время начала действия сокращенного URL
+	CorrelationID  string        `json:"correlation_id"`            // идентификатор для сопоставления исходного и сокращенного URL
+	URL            string        `json:"original_url"`              // исходный URL
+	TTL            int64         `json:"ttl,omitempty"`             // время жизни сокращенного URL в секундах
+	Password       string        `json:"password,omitempty"`        // пароль для перехода по сокращенному URL
+	Title          string        `json:"title,omitempty"`           // заголовок сокращенного URL
+	Tags           []string      `json:"tags,omitempty"`            // метки сокращенного URL
+	MaxClicks      int           `json:"max_clicks,omitempty"`      // максимальное количество переходов по сокращенному URL
+	Destinations   []Destination `json:"destinations,omitempty"`    // варианты исходного URL; задаются вместо original_url
+	Rotation       string        `json:"rotation,omitempty"`        // режим выбора варианта: random или sticky
+	Rules          []RoutingRule `json:"rules,omitempty"`           // правила выбора исходного URL по клиенту
+	ForwardPath    bool          `json:"forward_path,omitempty"`    // признак передачи суффикса пути в исходный URL
+	RedirectStatus int           `json:"redirect_status,omitempty"` // код перенаправления: 301, 302, 307 или 308
 }
 
 // ShortURL содержит сокращенный URL. Возвращается в ответе на запрос сокращения набора URL.
@@ -70,36 +152,131 @@ type ShortURL struct {
 	URL           string `json:"short_url"`      // сокращенный URL
 }
 
+// UpdateURLRequest содержит новый исходный URL сокращенного URL.
+type UpdateURLRequest struct {
+	URL string `json:"url"` // исходный URL
+}
+
+// URLTags содержит метки сокращенного URL. Применяется в запросе и ответе на запрос изменения меток.
+type URLTags struct {
+	Tags []string `json:"tags"` // метки сокращенного URL
+}
+
 // UserURL содержит исходный и сокращенный URL. Возвращается в ответе на запрос набора URL, сокращенного пользователем.
 type UserURL struct {
-	ShortURL    string `json:"short_url"`    // сокращенный URL
-	OriginalURL string `json:"original_url"` // исходный URL
+	ActiveFrom     *time.Time    `json:"active_from,omitempty"`     // время начала действия сокращенного URL
+	ShortURL       string        `json:"short_url"`                 // сокращенный URL
+	OriginalURL    string        `json:"original_url"`              // исходный URL
+	Title          string        `json:"title,omitempty"`           // заголовок сокращенного URL
+	Status         string        `json:"status,omitempty"`          // состояние: active, scheduled, expired или exhausted
+	Tags           []string      `json:"tags,omitempty"`            // метки сокращенного URL
+	Rotation       string        `json:"rotation,omitempty"`        // режим выбора варианта: random или sticky
+	Destinations   []Destination `json:"destinations,omitempty"`    // варианты исходного URL
+	Rules          []RoutingRule `json:"rules,omitempty"`           // правила выбора исходного URL по клиенту
+	ForwardPath    bool          `json:"forward_path,omitempty"`    // признак передачи суффикса пути в исходный URL
+	RedirectStatus int           `json:"redirect_status,omitempty"` // код перенаправления; не задан - код сервера
+}
+
+// URLClickStats содержит статистику переходов по сокращенному URL.
+type URLClickStats struct {
+	ShortURL string          `json:"short_url"`          // сокращенный URL
+	Daily    []DailyClicks   `json:"daily"`              // количество переходов по дням
+	Variants []VariantClicks `json:"variants,omitempty"` // количество переходов по вариантам исходного URL
+	Total    int             `json:"total"`              // общее количество переходов
+}
+
+// VariantClicks содержит количество переходов на вариант исходного URL.
+type VariantClicks struct {
+	Variant string `json:"variant"` // имя варианта
+	Count   int    `json:"count"`   // количество переходов
+}
+
+// DailyClicks содержит количество переходов по сокращенному URL за день.
+type DailyClicks struct {
+	Date  string `json:"date"`  // дата в формате ГГГГ-ММ-ДД (UTC)
+	Count int    `json:"count"` // количество переходов
+}
+
+// DeleteJobResponse содержит идентификатор задачи удаления. Возвращается в ответе на запрос удаления URL.
+type DeleteJobResponse struct {
+	JobID string `json:"job_id"` // идентификатор задачи удаления
+}
+
+// DeleteJob содержит состояние задачи удаления URL пользователя.
+type DeleteJob struct {
+	ID      string      `json:"id"`                // идентификатор задачи
+	Status  string      `json:"status"`            // состояние задачи: pending, done или failed
+	Results []KeyResult `json:"results,omitempty"` // результат удаления каждого URL
+}
+
+// KeyResult содержит результат операции над сокращенным URL: deleted, restored, not_owned или not_found.
+type KeyResult struct {
+	ShortURL string `json:"short_url"` // ключ сокращенного URL
+	Status   string `json:"status"`    // результат операции
+}
+
+// InternalStats содержит количество сокращенных URL и пользователей сервиса.
+type InternalStats struct {
+	URLs  int `json:"urls"`  // количество сокращенных URL
+	Users int `json:"users"` // количество пользователей
+}
+
+// UserQuota содержит квоту пользователя на количество сокращенных URL и ее использование.
+type UserQuota struct {
+	Limit int `json:"limit,omitempty"` // максимальное количество URL; отсутствует, если количество не ограничено
+	Used  int `json:"used"`            // количество URL, сокращенных пользователем
+}
+
+// QuotaExceeded содержит ошибку превышения квоты и использование квоты пользователя.
+type QuotaExceeded struct {
+	Error string `json:"error"` // текст ошибки
+	UserQuota
 }
 
 // Option определяет опцию настройки сервера.
 type Option func(*Server)
 
+// RateLimits задает ограничения частоты запросов для групп маршрутов. Нулевое значение ограничения
+// означает, что частота запросов группы не ограничивается.
+type RateLimits struct {
+	Shorten  middleware.Rate // сокращение URL
+	Batch    middleware.Rate // сокращение коллекции URL и импорт URL
+	Redirect middleware.Rate // переход по сокращенному URL
+	Delete   middleware.Rate // удаление URL пользователя
+}
+
 // New создает сервер. Конструктор принимает на вход хранилище URL, базовый URL и набор опций.
 func New(store domain.URLStore, baseURL string, options ...Option) *Server {
 	r := chi.NewRouter()
 	s := &Server{
-		store:   store,
-		router:  r,
-		baseURL: baseURL,
-		logger:  zap.NewNop(),
+		store:          store,
+		router:         r,
+		baseURL:        baseURL,
+		logger:         zap.NewNop(),
+		authorizer:     auth.New(auth.DefaultSecret, auth.DefaultTokenExp),
+		redirectStatus: http.StatusTemporaryRedirect,
 	}
 
 	for _, opt := range options {
 		opt(s)
 	}
 
-	authorizer := auth.New(secretKey, tokenExp)
+	if s.service == nil {
+		s.service = s.newService(store)
+	}
+
+	authorizer := s.authorizer
 	const apiUserURLsPath = "/api/user/urls"
+	shortenLimit := rateLimit(s.rateLimits.Shorten, s.trustedProxy)
+	batchLimit := rateLimit(s.rateLimits.Batch, s.trustedProxy)
+	redirectLimit := rateLimit(s.rateLimits.Redirect, s.trustedProxy)
+	deleteLimit := rateLimit(s.rateLimits.Delete, s.trustedProxy)
 
 	r.Use(middleware.ResponseLogger(s.logger))
 
 	r.Group(func(r chi.Router) {
 		r.Get("/ping", s.ping)
+		r.Get("/{key}/"+qrCodePath, s.getQRCode)
 	})
 
 	r.Group(func(r chi.Router) {
@@ -107,35 +284,99 @@ func New(store domain.URLStore, baseURL string, options ...Option) *Server {
 		r.Use(middleware.RequestDecoder, middleware.ResponseEncoder)
 		r.Use(middleware.Auth(authorizer))
 
-		r.Post("/api/shorten/batch", s.shortenURLs)
-		r.Post("/api/shorten", s.shortenAPI)
+		r.With(batchLimit).Post("/api/shorten/batch", s.shortenURLs)
+		r.With(shortenLimit).Post("/api/shorten", s.shortenAPI)
 
-		r.Delete(apiUserURLsPath, s.deleteUserURLs)
+		r.With(deleteLimit).Delete(apiUserURLsPath, s.deleteUserURLs)
+		r.Post(apiUserURLsPath+"/restore", s.restoreUserURLs)
+		r.Patch(apiUserURLsPath+"/{key}", s.updateUserURL)
+		r.Put(apiUserURLsPath+"/{key}/tags", s.updateTags)
+		r.Put(apiUserURLsPath+"/{key}/rules", s.updateRoutingRules)
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(chimiddleware.AllowContentType(textPlain, applicationGZIP))
 		r.Use(middleware.RequestDecoder, middleware.ResponseEncoder)
 
-		r.Get("/{key}", s.redirect)
+		r.With(redirectLimit).Get("/{key}", s.redirect)
+		r.With(redirectLimit).Get("/{key}/*", s.redirect)
+		r.Get("/{key}+", s.preview)
 
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.Auth(authorizer))
 
-			r.Post("/", s.shorten)
+			r.With(shortenLimit).Post("/", s.shorten)
 		})
 	})
 
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.AllowContentType(multipartFormData))
+		r.Use(middleware.ResponseEncoder)
+		r.Use(middleware.Auth(authorizer))
+
+		r.With(batchLimit).Post(apiUserURLsPath+"/import", s.importUserURLs)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.AllowContentType(applicationForm))
+
+		r.With(redirectLimit).Post("/{key}", s.unlock)
+		r.With(redirectLimit).Post("/{key}/*", s.unlock)
+	})
+
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.ResponseEncoder)
 		r.Use(middleware.Auth(authorizer))
 
 		r.Get(apiUserURLsPath, s.getUserURLs)
+		r.Get(apiUserURLsPath+"/deleted", s.getDeletedUserURLs)
+		r.Get(apiUserURLsPath+"/export", s.exportUserURLs)
+		r.Get(apiUserURLsPath+"/{key}/stats", s.getClickStats)
+		r.Get(apiUserJobsPath+"/{id}", s.getDeleteJob)
+		r.Get("/api/user/quota", s.getUserQuota)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.TrustedSubnet(s.trustedSubnet))
+		r.Use(middleware.ResponseEncoder)
+
+		r.Get("/api/internal/stats", s.getInternalStats)
 	})
 
 	return s
 }
 
+// rateLimit возвращает посредника, ограничивающего частоту запросов группы маршрутов. Если ограничение
+// не задано, запросы передаются обработчику без проверки. Заголовок X-Real-IP учитывается только
+// для запросов из доверенной подсети прокси-серверов trustedProxy.
+func rateLimit(rate middleware.Rate, trustedProxy *net.IPNet) func(h http.Handler) http.Handler {
+	if rate.IsZero() {
+		return func(h http.Handler) http.Handler { return h }
+	}
+
+	return middleware.RateLimit(middleware.NewRateLimiter(rate), trustedProxy)
+}
+
+// newService создает сервис сокращения ссылок с настройками сервера, если сервис не задан опцией WithService.
+func (s *Server) newService(store domain.URLStore) *service.URLService {
+	options := []service.Option{service.WithShortenURLsMaxCount(s.shortenURLsMaxCount)}
+
+	if s.urlRemover != nil {
+		options = append(options, service.WithURLRemover(s.urlRemover))
+	}
+
+	if s.canonicalizer != nil {
+		options = append(options, service.WithCanonicalizer(s.canonicalizer))
+	}
+
+	if s.domainPolicy != nil {
+		options = append(options, service.WithDomainPolicy(s.domainPolicy))
+	}
+
+	options = append(options, service.WithQuotas(s.quotas))
+	return service.New(store, options...)
+}
+
 // ServeHTTP обрабатывает запрос.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
@@ -143,11 +384,45 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) redirect(w http.ResponseWriter, r *http.Request) {
 	key := chi.URLParam(r, "key")
+
+	if r.URL.Query().Get(previewQueryParam) == "1" {
+		s.preview(w, r)
+		return
+	}
+
+	req := newExpandRequest(r, key, "")
+	if isReservedPath(req.Path) {
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
+		return
+	}
+
 	ctx := r.Context()
-	url, err := s.store.GetOriginalURL(ctx, key)
+	target, err := s.service.ExpandTarget(ctx, key, req)
+
+	if errors.Is(err, domain.ErrOriginalURLIsNotActive) {
+		s.writeNotActive(w)
+		return
+	}
+
+	if errors.Is(err, service.ErrPasswordRequired) {
+		writePasswordForm(w, http.StatusOK, r.URL.RequestURI(), "")
+		return
+	}
+
+	if err != nil {
+		writeExpandError(w, err)
+		return
+	}
+
+	s.recordClick(r, key, target.Variant)
+	setStickyVariant(w, key, target)
+	s.writeRedirect(w, r, target)
+}
 
+// writeExpandError записывает ответ с кодом состояния, соответствующим ошибке получения исходного URL.
+func writeExpandError(w http.ResponseWriter, err error) {
 	if errors.Is(err, domain.ErrOriginalURLNotFound) {
-		notFound(w, err.Error())
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
 		return
 	}
 
@@ -156,43 +431,129 @@ func (s *Server) redirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	if errors.Is(err, domain.ErrOriginalURLIsExpired) {
+		http.Error(w, domain.ErrOriginalURLIsExpired.Error(), http.StatusGone)
+		return
+	}
+
+	if errors.Is(err, domain.ErrOriginalURLIsExhausted) {
+		http.Error(w, domain.ErrOriginalURLIsExhausted.Error(), http.StatusGone)
+		return
+	}
+
+	if errors.Is(err, domain.ErrOriginalURLIsNotActive) {
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
+		return
+	}
+
+	var violationErr *policy.ViolationError
+	if errors.As(err, &violationErr) {
+		http.Error(w, violationErr.Error(), http.StatusUnavailableForLegalReasons)
+		return
+	}
+
+	internalError(w, "failed to get url")
 }
 
-func (s *Server) shorten(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	_ = r.Body.Close()
+func (s *Server) getQRCode(w http.ResponseWriter, r *http.Request) {
+	opts, err := newQRCodeOptions(r.URL.Query())
 
 	if err != nil {
 		badRequest(w, err.Error())
 		return
 	}
 
-	if len(body) == 0 {
-		badRequest(w, urlIsEmptyMessage)
+	key := chi.URLParam(r, "key")
+	if _, err = s.service.GetURL(r.Context(), key); err != nil {
+		writeExpandError(w, err)
 		return
 	}
 
-	ctx := r.Context()
-	user, _ := customctx.GetUser(ctx)
-	shortURL := shortener.Shorten(uuid.New().ID())
-	pair := domain.URLPair{
-		ShortURL:    shortURL,
-		OriginalURL: string(body),
+	var buf bytes.Buffer
+	err = qrcode.Write(&buf, joinPath(s.baseURL, key), opts)
+
+	if errors.Is(err, qrcode.ErrSizeIsTooSmall) {
+		badRequest(w, err.Error())
+		return
 	}
-	err = s.store.AddURL(ctx, pair, user.ID)
 
-	var originalURLAlreadyExists *domain.OriginalURLExistsError
-	if err != nil && !errors.As(err, &originalURLAlreadyExists) {
-		internalError(w, failedToStoreURLMessage)
+	if err != nil {
+		internalError(w, "failed to render qr code")
 		return
 	}
 
-	status := http.StatusCreated
-	if originalURLAlreadyExists != nil {
-		status = http.StatusConflict
-		shortURL = originalURLAlreadyExists.GetShortURL()
+	w.Header().Set(contentTypeHeader, qrcode.ContentType(opts.Format))
+	w.Header().Set(contentLengthHeader, strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// newQRCodeOptions формирует параметры изображения QR-кода из параметров строки запроса.
+func newQRCodeOptions(values url.Values) (qrcode.Options, error) {
+	opts := qrcode.DefaultOptions()
+
+	if format := values.Get("format"); format != "" {
+		opts.Format = format
+	}
+
+	if level := values.Get("level"); level != "" {
+		opts.Level = level
 	}
+
+	if size := values.Get("size"); size != "" {
+		var err error
+		if opts.Size, err = strconv.Atoi(size); err != nil {
+			return qrcode.Options{}, qrcode.ErrSizeIsInvalid
+		}
+	}
+
+	if margin := values.Get("margin"); margin != "" {
+		var err error
+		if opts.Margin, err = strconv.Atoi(margin); err != nil {
+			return qrcode.Options{}, qrcode.ErrMarginIsInvalid
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return qrcode.Options{}, fmt.Errorf("qr code options: %w", err)
+	}
+
+	return opts, nil
+}
+
+func (s *Server) recordClick(r *http.Request, shortURL, variant string) {
+	click := newClick(r, shortURL, s.trustedProxy)
+	click.Variant = variant
+
+	if s.clickRecorder != nil {
+		s.clickRecorder.Record(click)
+		return
+	}
+
+	if err := s.store.AddClicks(r.Context(), []domain.Click{click}); err != nil {
+		s.logger.Error(err.Error())
+	}
+}
+
+func (s *Server) shorten(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	req := service.ShortenRequest{
+		URL:   string(body),
+		Alias: r.URL.Query().Get(aliasQueryParam),
+	}
+	shortURL, status, ok := s.addURL(w, r, req)
+
+	if !ok {
+		return
+	}
+
 	w.Header().Set(contentTypeHeader, textPlain)
 	w.WriteHeader(status)
 	_, err = w.Write([]byte(joinPath(s.baseURL, shortURL)))
@@ -213,32 +574,12 @@ func (s *Server) shortenAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.URL) == 0 {
-		badRequest(w, urlIsEmptyMessage)
-		return
-	}
+	shortURL, status, ok := s.addURL(w, r, req.toService())
 
-	ctx := r.Context()
-	user, _ := customctx.GetUser(ctx)
-	shortURL := shortener.Shorten(uuid.New().ID())
-	pair := domain.URLPair{
-		ShortURL:    shortURL,
-		OriginalURL: req.URL,
-	}
-	err = s.store.AddURL(ctx, pair, user.ID)
-
-	var originalURLAlreadyExists *domain.OriginalURLExistsError
-	if err != nil && !errors.As(err, &originalURLAlreadyExists) {
-		internalError(w, failedToStoreURLMessage)
+	if !ok {
 		return
 	}
 
-	status := http.StatusCreated
-	if originalURLAlreadyExists != nil {
-		status = http.StatusConflict
-		shortURL = originalURLAlreadyExists.GetShortURL()
-	}
-
 	resp := ShortenResponse{Result: joinPath(s.baseURL, shortURL)}
 	content, err := json.Marshal(resp)
 
@@ -258,10 +599,30 @@ func (s *Server) shortenAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// addURL сохраняет исходный URL. Возвращает сокращенный URL и статус ответа.
+// Если URL не удалось сохранить, ответ с ошибкой уже записан и возвращается false.
+func (s *Server) addURL(w http.ResponseWriter, r *http.Request, req service.ShortenRequest) (string, int, bool) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+	shortURL, err := s.service.Shorten(ctx, req, user.ID)
+
+	var originalURLAlreadyExists *domain.OriginalURLExistsError
+	if errors.As(err, &originalURLAlreadyExists) {
+		return shortURL, http.StatusConflict, true
+	}
+
+	if err != nil {
+		writeServiceError(w, err, failedToStoreURLMessage)
+		return "", 0, false
+	}
+
+	return shortURL, http.StatusCreated, true
+}
+
 func (s *Server) ping(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusInternalServerError
 	ctx := r.Context()
-	if s.store.IsAvailable(ctx) {
+	if s.service.IsAvailable(ctx) {
 		status = http.StatusOK
 	}
 	w.WriteHeader(status)
@@ -277,35 +638,31 @@ func (s *Server) shortenURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req) == 0 {
-		badRequest(w, batchIsEmptyMessage)
-		return
-	}
-
-	if isTooManyURLs(req, s.shortenURLsMaxCount) {
-		forbidden(w)
-		return
-	}
-
-	urlPairs := make([]domain.URLPair, len(req))
+	reqs := make([]service.ShortenRequest, len(req))
 	for i := 0; i < len(req); i++ {
-		if len(req[i].URL) == 0 {
-			badRequest(w, urlIsEmptyMessage)
-			return
-		}
-
-		urlPairs[i] = domain.URLPair{
-			ShortURL:    shortener.Shorten(uuid.New().ID()),
-			OriginalURL: req[i].URL,
+		reqs[i] = service.ShortenRequest{
+			URL:            req[i].URL,
+			ExpiresAt:      req[i].ExpiresAt,
+			ActiveFrom:     req[i].ActiveFrom,
+			TTL:            req[i].TTL,
+			Password:       req[i].Password,
+			Title:          req[i].Title,
+			Tags:           req[i].Tags,
+			MaxClicks:      req[i].MaxClicks,
+			Destinations:   newDestinations(req[i].Destinations),
+			Rotation:       req[i].Rotation,
+			Rules:          newRoutingRules(req[i].Rules),
+			ForwardPath:    req[i].ForwardPath,
+			RedirectStatus: req[i].RedirectStatus,
 		}
 	}
 
 	ctx := r.Context()
 	user, _ := customctx.GetUser(ctx)
-	err = s.store.AddURLs(ctx, urlPairs, user.ID)
+	shortURLs, err := s.service.ShortenBatch(ctx, reqs, user.ID)
 
 	if err != nil {
-		internalError(w, failedToStoreURLMessage)
+		writeServiceError(w, err, failedToStoreURLMessage)
 		return
 	}
 
@@ -313,7 +670,7 @@ func (s *Server) shortenURLs(w http.ResponseWriter, r *http.Request) {
 	for i := 0; i < len(req); i++ {
 		resp[i] = ShortURL{
 			CorrelationID: req[i].CorrelationID,
-			URL:           joinPath(s.baseURL, urlPairs[i].ShortURL),
+			URL:           joinPath(s.baseURL, shortURLs[i]),
 		}
 	}
 	content, err := json.Marshal(resp)
@@ -343,27 +700,168 @@ func (s *Server) getUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	urlPairs, _ := s.store.GetUserURLs(ctx, user.ID)
+	req, err := newUserURLsRequest(r.URL.Query())
+
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
 
+	page, err := s.service.GetUserURLsPage(ctx, req, user.ID)
+
+	if err != nil {
+		writeServiceError(w, err, "failed to get user urls")
+		return
+	}
+
+	urlPairs := page.URLs
 	if len(urlPairs) == 0 {
 		http.Error(w, "no urls", http.StatusNoContent)
 		return
 	}
 
+	now := time.Now()
 	resp := make([]UserURL, len(urlPairs))
 	for i := 0; i < len(urlPairs); i++ {
 		resp[i] = UserURL{
-			OriginalURL: urlPairs[i].OriginalURL,
-			ShortURL:    joinPath(s.baseURL, urlPairs[i].ShortURL),
+			OriginalURL:    urlPairs[i].OriginalURL,
+			ShortURL:       joinPath(s.baseURL, urlPairs[i].ShortURL),
+			Title:          urlPairs[i].Title,
+			Tags:           urlPairs[i].Tags,
+			Status:         urlStatus(urlPairs[i], now),
+			Rotation:       urlPairs[i].Rotation,
+			Rules:          toRoutingRules(urlPairs[i].Rules),
+			ForwardPath:    urlPairs[i].ForwardPath,
+			RedirectStatus: urlPairs[i].RedirectStatus,
+		}
+
+		for _, d := range urlPairs[i].Destinations {
+			resp[i].Destinations = append(resp[i].Destinations, Destination(d))
+		}
+
+		if activeFrom := urlPairs[i].ActiveFrom; !activeFrom.IsZero() {
+			activeFrom = activeFrom.UTC()
+			resp[i].ActiveFrom = &activeFrom
 		}
 	}
 	content, _ := json.Marshal(resp)
 
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+	}
+
 	w.Header().Set(contentTypeHeader, applicationJSON)
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(content)
 }
 
+// newUserURLsRequest формирует запрос страницы URL пользователя из параметров строки запроса.
+func newUserURLsRequest(values url.Values) (service.UserURLsRequest, error) {
+	req := service.UserURLsRequest{
+		Cursor: values.Get("cursor"),
+		Filter: values.Get("filter"),
+		Tag:    values.Get("tag"),
+		Order:  values.Get("order"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		req.Limit, err = strconv.Atoi(limit)
+
+		if err != nil || req.Limit <= 0 {
+			return service.UserURLsRequest{}, service.ErrLimitIsInvalid
+		}
+	}
+
+	return req, nil
+}
+
+func (s *Server) updateUserURL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, failedToParseRequestMessage)
+		return
+	}
+
+	key := chi.URLParam(r, "key")
+	err := s.service.UpdateOriginalURL(ctx, key, req.URL, user.ID)
+
+	var originalURLAlreadyExists *domain.OriginalURLExistsError
+	if errors.As(err, &originalURLAlreadyExists) {
+		writeJSON(w, http.StatusConflict, ShortenResponse{
+			Result: joinPath(s.baseURL, originalURLAlreadyExists.GetShortURL()),
+		})
+		return
+	}
+
+	if err != nil {
+		writeUserURLError(w, err, "failed to update url")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UserURL{
+		ShortURL:    joinPath(s.baseURL, key),
+		OriginalURL: req.URL,
+	})
+}
+
+func (s *Server) updateTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req URLTags
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, failedToParseRequestMessage)
+		return
+	}
+
+	tags, err := s.service.UpdateTags(ctx, chi.URLParam(r, "key"), req.Tags, user.ID)
+
+	if err != nil {
+		writeUserURLError(w, err, "failed to update tags")
+		return
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, URLTags{Tags: tags})
+}
+
+// writeUserURLError записывает ответ с ошибкой изменения сокращенного URL, добавленного пользователем.
+func writeUserURLError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, domain.ErrOriginalURLNotFound) {
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
+		return
+	}
+
+	if errors.Is(err, domain.ErrOriginalURLNotOwned) {
+		http.Error(w, domain.ErrOriginalURLNotOwned.Error(), http.StatusForbidden)
+		return
+	}
+
+	if errors.Is(err, domain.ErrOriginalURLIsDeleted) {
+		http.Error(w, domain.ErrOriginalURLIsDeleted.Error(), http.StatusGone)
+		return
+	}
+
+	writeServiceError(w, err, message)
+}
+
 func (s *Server) deleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, _ := customctx.GetUser(ctx)
@@ -377,26 +875,253 @@ func (s *Server) deleteUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.urlRemover != nil {
-		err = s.urlRemover.DeleteURLs(shortURLs, user.ID)
-	} else {
-		err = s.store.DeleteUserURLs(ctx, shortURLs, user.ID)
-	}
+	jobID, err := s.service.DeleteUserURLs(ctx, shortURLs, user.ID)
 
 	if err != nil {
 		internalError(w, "failed to delete user urls")
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	w.Header().Set(locationHeader, apiUserJobsPath+"/"+jobID)
+	writeJSON(w, http.StatusAccepted, DeleteJobResponse{JobID: jobID})
+}
+
+func (s *Server) getDeleteJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := s.service.GetDeleteJob(chi.URLParam(r, "id"), user.ID)
+
+	if errors.Is(err, service.ErrJobNotFound) {
+		notFound(w, service.ErrJobNotFound.Error())
+		return
+	}
+
+	if err != nil {
+		internalError(w, "failed to get job")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DeleteJob{
+		ID:      job.ID,
+		Status:  string(job.Status),
+		Results: newKeyResults(job.Results),
+	})
+}
+
+func (s *Server) restoreUserURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	var shortURLs []string
+	if err := json.NewDecoder(r.Body).Decode(&shortURLs); err != nil {
+		badRequest(w, failedToParseRequestMessage)
+		return
+	}
+
+	results, err := s.service.RestoreUserURLs(ctx, shortURLs, user.ID)
+
+	if err != nil {
+		internalError(w, "failed to restore user urls")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newKeyResults(results))
+}
+
+func (s *Server) getDeletedUserURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pairs, err := s.service.GetDeletedUserURLs(ctx, user.ID)
+
+	if err != nil {
+		internalError(w, "failed to get deleted user urls")
+		return
+	}
+
+	if len(pairs) == 0 {
+		http.Error(w, "no urls", http.StatusNoContent)
+		return
+	}
+
+	resp := make([]UserURL, len(pairs))
+	for i := 0; i < len(pairs); i++ {
+		resp[i] = UserURL{
+			OriginalURL: pairs[i].OriginalURL,
+			ShortURL:    joinPath(s.baseURL, pairs[i].ShortURL),
+			Title:       pairs[i].Title,
+			Tags:        pairs[i].Tags,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// newKeyResults преобразует результаты операции над набором сокращенных URL в ответ сервера.
+func newKeyResults(results []domain.KeyResult) []KeyResult {
+	resp := make([]KeyResult, len(results))
+	for i, result := range results {
+		resp[i] = KeyResult{
+			ShortURL: result.ShortURL,
+			Status:   string(result.Status),
+		}
+	}
+
+	return resp
+}
+
+func (s *Server) getClickStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	key := chi.URLParam(r, "key")
+	stats, err := s.service.GetClickStats(ctx, key, user.ID)
+
+	if errors.Is(err, domain.ErrOriginalURLNotFound) {
+		notFound(w, domain.ErrOriginalURLNotFound.Error())
+		return
+	}
+
+	if errors.Is(err, domain.ErrOriginalURLNotOwned) {
+		http.Error(w, domain.ErrOriginalURLNotOwned.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		internalError(w, "failed to get click stats")
+		return
+	}
+
+	resp := URLClickStats{
+		ShortURL: joinPath(s.baseURL, key),
+		Total:    stats.Total,
+		Daily:    make([]DailyClicks, len(stats.Daily)),
+	}
+	for i := 0; i < len(stats.Daily); i++ {
+		resp.Daily[i] = DailyClicks{
+			Date:  stats.Daily[i].Date.Format(time.DateOnly),
+			Count: stats.Daily[i].Count,
+		}
+	}
+	for _, v := range stats.Variants {
+		resp.Variants = append(resp.Variants, VariantClicks(v))
+	}
+	content, err := json.Marshal(resp)
+
+	if err != nil {
+		internalError(w, failedToPrepareResponseMessage)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+}
+
+func (s *Server) getInternalStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.service.GetStats(r.Context())
+
+	if err != nil {
+		internalError(w, "failed to get stats")
+		return
+	}
+
+	content, err := json.Marshal(InternalStats(stats))
+
+	if err != nil {
+		internalError(w, failedToPrepareResponseMessage)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+}
+
+func (s *Server) getUserQuota(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := customctx.GetUser(ctx)
+
+	if user.IsNew {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	quota, err := s.service.GetQuota(ctx, user.ID)
+
+	if err != nil {
+		internalError(w, "failed to get quota")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UserQuota(quota))
 }
 
-func isTooManyURLs(urls []OriginalURL, maxCount int) bool {
-	return maxCount > 0 && len(urls) > maxCount
+// writeJSON записывает ответ с указанным кодом состояния и телом в формате JSON.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	content, err := json.Marshal(v)
+
+	if err != nil {
+		internalError(w, failedToPrepareResponseMessage)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, applicationJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(content)
 }
 
-func forbidden(w http.ResponseWriter) {
-	http.Error(w, "to many urls", http.StatusForbidden)
+// writeServiceError записывает ответ с кодом состояния, соответствующим ошибке сервиса.
+func writeServiceError(w http.ResponseWriter, err error, message string) {
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		badRequest(w, validationErr.Error())
+		return
+	}
+
+	if errors.Is(err, service.ErrTooManyURLs) {
+		http.Error(w, service.ErrTooManyURLs.Error(), http.StatusForbidden)
+		return
+	}
+
+	var violationErr *policy.ViolationError
+	if errors.As(err, &violationErr) {
+		http.Error(w, violationErr.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var quotaExceeded *service.QuotaExceededError
+	if errors.As(err, &quotaExceeded) {
+		writeJSON(w, http.StatusForbidden, QuotaExceeded{
+			Error:     service.ErrQuotaExceeded.Error(),
+			UserQuota: UserQuota(quotaExceeded.Quota()),
+		})
+		return
+	}
+
+	var shortURLAlreadyExists *domain.ShortURLExistsError
+	if errors.As(err, &shortURLAlreadyExists) {
+		http.Error(w, aliasIsTakenMessage, http.StatusConflict)
+		return
+	}
+
+	internalError(w, message)
 }
 
 func joinPath(base, elem string) string {
@@ -422,6 +1147,15 @@ func WithLogger(logger *zap.Logger) Option {
 	}
 }
 
+// WithService задает сервис сокращения ссылок. Сервис может быть общим с gRPC сервером, тогда
+// ограничения попыток ввода пароля, блокировки квот и задания удаления у серверов общие.
+// Опции, которые настраивают сервис, при этом не применяются.
+func WithService(svc *service.URLService) Option {
+	return func(s *Server) {
+		s.service = svc
+	}
+}
+
 // WithShortenURLsMaxCount определяет максимальное количество URL в запросе на сокращение коллекции URL.
 func WithShortenURLsMaxCount(count int) Option {
 	return func(s *Server) {
@@ -435,3 +1169,78 @@ func WithURLsRemover(remover *URLRemover) Option {
 		s.urlRemover = remover
 	}
 }
+
+// WithClickRecorder задает компонент, который выполняет асинхронное сохранение переходов по сокращенным URL.
+func WithClickRecorder(recorder *ClickRecorder) Option {
+	return func(s *Server) {
+		s.clickRecorder = recorder
+	}
+}
+
+// WithUserAuth задает компонент аутентификации пользователей.
+func WithUserAuth(authorizer *auth.UserAuth) Option {
+	return func(s *Server) {
+		s.authorizer = authorizer
+	}
+}
+
+// WithTrustedSubnet задает доверенную подсеть, из которой доступна внутренняя статистика сервиса.
+func WithTrustedSubnet(subnet *net.IPNet) Option {
+	return func(s *Server) {
+		s.trustedSubnet = subnet
+	}
+}
+
+// WithTrustedProxy задает доверенную подсеть прокси-серверов. IP-адрес клиента берется из заголовка
+// X-Real-IP только для запросов из этой подсети; иначе используется адрес соединения.
+func WithTrustedProxy(subnet *net.IPNet) Option {
+	return func(s *Server) {
+		s.trustedProxy = subnet
+	}
+}
+
+// WithCanonicalizer задает компонент проверки исходных URL и приведения их к каноническому виду.
+func WithCanonicalizer(canonicalizer *canonical.Canonicalizer) Option {
+	return func(s *Server) {
+		s.canonicalizer = canonicalizer
+	}
+}
+
+// WithDomainPolicy задает политику доменов исходных URL.
+func WithDomainPolicy(domainPolicy *policy.DomainPolicy) Option {
+	return func(s *Server) {
+		s.domainPolicy = domainPolicy
+	}
+}
+
+// WithRateLimits задает ограничения частоты запросов для групп маршрутов.
+func WithRateLimits(limits RateLimits) Option {
+	return func(s *Server) {
+		s.rateLimits = limits
+	}
+}
+
+// WithQuotas задает квоты пользователей на количество сокращенных URL.
+func WithQuotas(quotas service.Quotas) Option {
+	return func(s *Server) {
+		s.quotas = quotas
+	}
+}
+
+// WithComingSoonPage задает HTML страницу, которая возвращается при переходе по сокращенному URL, еще
+// не начавшему действовать. Если страница не задана, возвращается ответ 404.
+func WithComingSoonPage(page []byte) Option {
+	return func(s *Server) {
+		s.comingSoonPage = page
+	}
+}
+
+// WithRedirectStatus задает код ответа перенаправления по умолчанию для сокращенных URL, для которых код
+// не задан: 301, 302, 307 или 308. По умолчанию используется 307. Нулевое значение не изменяет код.
+func WithRedirectStatus(status int) Option {
+	return func(s *Server) {
+		if status != 0 {
+			s.redirectStatus = status
+		}
+	}
+}
@@ -7,20 +7,31 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image/png"
 	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/nestjam/yap-shortener/internal/auth"
+	"github.com/nestjam/yap-shortener/internal/canonical"
 	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/middleware"
 	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+	"github.com/nestjam/yap-shortener/internal/policy"
+	"github.com/nestjam/yap-shortener/internal/service"
+	"github.com/nestjam/yap-shortener/internal/shortener"
 )
 
 const (
@@ -32,6 +43,7 @@ const (
 	apiShortenPath        = "/api/shorten"
 	apiBatchShortenPath   = "/api/shorten/batch"
 	userURLsPath          = "/api/user/urls"
+	userQuotaPath         = "/api/user/quota"
 	pingPath              = "ping"
 )
 
@@ -79,6 +91,86 @@ func (u URLShortenerTest) Test(t *testing.T) {
 			assertBody(t, body, response)
 		})
 
+		t.Run("one-time url is exhausted", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+				MaxClicks:   1,
+				ClicksLeft:  1,
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+
+			response := httptest.NewRecorder()
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			assertLocation(t, testURL, response)
+
+			response = httptest.NewRecorder()
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+			assert.Equal(t, http.StatusGone, response.Code)
+			assertBody(t, domain.ErrOriginalURLIsExhausted.Error(), response)
+		})
+
+		t.Run("scheduled url is not found", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, ActiveFrom: time.Now().Add(time.Hour)}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+			assertLocation(t, "", response)
+			assertBody(t, "not found", response)
+		})
+
+		t.Run("scheduled url shows coming soon page", func(t *testing.T) {
+			const (
+				shortURL = "EwHXdJfB"
+				page     = "<h1>Coming soon</h1>"
+			)
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, ActiveFrom: time.Now().Add(time.Hour)}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithComingSoonPage([]byte(page)))
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertLocation(t, "", response)
+			assertContentType(t, textHTML, response)
+			assert.Equal(t, "no-store", response.Header().Get(cacheControlHeader))
+			assert.Equal(t, page, response.Body.String())
+		})
+
+		t.Run("url is redirected after activation", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, ActiveFrom: time.Now().Add(-time.Minute)}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			assertLocation(t, testURL, response)
+		})
+
 		t.Run("path is empty", func(t *testing.T) {
 			request := newGetRequest("")
 			response := httptest.NewRecorder()
@@ -123,7 +215,7 @@ func (u URLShortenerTest) Test(t *testing.T) {
 			err := urlStore.AddURL(ctx, pair, userID)
 			require.NoError(t, err)
 
-			err = urlStore.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
+			_, err = urlStore.DeleteUserURLs(ctx, []string{pair.ShortURL}, userID)
 			require.NoError(t, err)
 
 			sut := New(urlStore, baseURL)
@@ -136,328 +228,656 @@ func (u URLShortenerTest) Test(t *testing.T) {
 			assertLocation(t, "", response)
 			assertBody(t, "url is deleted", response)
 		})
-	})
 
-	t.Run("shortening url", func(t *testing.T) {
-		t.Run("shorten url", func(t *testing.T) {
+		t.Run("url is expired", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+				ExpiresAt:   time.Now().Add(-time.Minute),
+			}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
 			sut := New(urlStore, baseURL)
-			request := newShortenRequest(testURL)
+			request := newGetRequest(shortURL)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertRedirectURL(t, response.Body.String(), urlStore)
+			assert.Equal(t, http.StatusGone, response.Code)
+			assertLocation(t, "", response)
+			assertBody(t, "url is expired", response)
 		})
 
-		t.Run("content type is not text-plain", func(t *testing.T) {
+		t.Run("domain is blocked after shortening", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+			}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newShortenRequest(testURL)
-			request.Header.Set(contentTypeHeader, "application/xml")
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			domainPolicy, err := policy.New([]string{"*.yandex.ru"}, nil)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithDomainPolicy(domainPolicy))
+			request := newGetRequest(shortURL)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusUnsupportedMediaType, response.Code)
-			assertBody(t, "", response)
+			assert.Equal(t, http.StatusUnavailableForLegalReasons, response.Code)
+			assertLocation(t, "", response)
+			assertBody(t, "domain is blocked: practicum.yandex.ru", response)
 		})
+	})
 
-		t.Run("shorten same url twice", func(t *testing.T) {
+	t.Run("getting password protected url", func(t *testing.T) {
+		const (
+			shortURL = "EwHXdJfB"
+			password = "secret"
+		)
+		addProtectedURL := func(t *testing.T) *Server {
+			t.Helper()
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+			require.NoError(t, err)
+			pair := domain.URLPair{
+				ShortURL:     shortURL,
+				OriginalURL:  testURL,
+				PasswordHash: string(hash),
+			}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
+			return New(urlStore, baseURL)
+		}
 
-			//1
-			request := newShortenRequest(testURL)
+		t.Run("password form is shown instead of redirect", func(t *testing.T) {
+			sut := addProtectedURL(t)
+			request := newGetRequest(shortURL)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertRedirectURL(t, response.Body.String(), urlStore)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertLocation(t, "", response)
+			assertContentType(t, textHTML, response)
+			assert.Contains(t, response.Body.String(), `action="/`+shortURL+`"`)
+		})
 
-			//2
-			request = newShortenRequest(testURL)
-			response = httptest.NewRecorder()
+		t.Run("correct password is posted", func(t *testing.T) {
+			sut := addProtectedURL(t)
+			request := newUnlockRequest(shortURL, password)
+			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusConflict, response.Code)
-			assertRedirectURL(t, response.Body.String(), urlStore)
+			assert.Equal(t, http.StatusSeeOther, response.Code)
+			assertLocation(t, testURL, response)
 		})
 
-		t.Run("url is empty", func(t *testing.T) {
-			urlStore, cleanup := u.CreateDependencies()
-			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newShortenRequest("")
+		t.Run("wrong password is posted", func(t *testing.T) {
+			sut := addProtectedURL(t)
+			request := newUnlockRequest(shortURL, "wrong")
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusBadRequest, response.Code)
-			assertBody(t, urlIsEmptyMessage, response)
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			assertLocation(t, "", response)
+			assert.Contains(t, response.Body.String(), service.ErrPasswordIsInvalid.Error())
 		})
 
-		t.Run("client accepts br and gzip encodings", func(t *testing.T) {
-			urlStore, cleanup := u.CreateDependencies()
-			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newShortenRequest(testURL)
-			request.Header.Set(acceptEncodingHeader, "br, "+gzipEncoding)
+		t.Run("too many failed attempts", func(t *testing.T) {
+			sut := addProtectedURL(t)
+			for i := 0; i < 5; i++ {
+				response := httptest.NewRecorder()
+				sut.ServeHTTP(response, newUnlockRequest(shortURL, "wrong"))
+				require.Equal(t, http.StatusForbidden, response.Code)
+			}
+			request := newUnlockRequest(shortURL, password)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertContentEncoding(t, gzipEncoding, response)
-			got := getDecoded(t, response.Body)
-			assertRedirectURL(t, got, urlStore)
+			assert.Equal(t, http.StatusTooManyRequests, response.Code)
+			assertLocation(t, "", response)
 		})
 
-		t.Run("client sends content type x-gzip", func(t *testing.T) {
+		t.Run("url not found", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			request := newEncodedShortenRequest(t, testURL)
+			request := newUnlockRequest(shortURL, password)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertContentEncoding(t, "", response)
-			assertRedirectURL(t, response.Body.String(), urlStore)
+			assert.Equal(t, http.StatusNotFound, response.Code)
 		})
 
-		t.Run("failed to store url", func(t *testing.T) {
+		t.Run("shorten url with password", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			failingURLStore := domain.NewURLStoreDelegate(urlStore)
-			failingURLStore.AddURLFunc = func(ctx context.Context, pair domain.URLPair, userID domain.UserID) error {
-				return errors.New("failed to add url")
-			}
-			sut := New(failingURLStore, baseURL)
-			request := newShortenRequest(testURL)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, Password: password})
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusInternalServerError, response.Code)
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.True(t, pair.IsProtected())
+			assert.NotEqual(t, password, pair.PasswordHash)
 		})
 	})
 
-	t.Run("shortening url (api)", func(t *testing.T) {
-		t.Run("shorten url", func(t *testing.T) {
+	t.Run("previewing url", func(t *testing.T) {
+		const (
+			shortURL = "EwHXdJfB"
+			title    = "Practicum"
+		)
+		addURL := func(t *testing.T) (domain.URLStore, domain.UserID) {
+			t.Helper()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+				Title:       title,
+				CreatedAt:   time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newShortenAPIRequest(t, testURL)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, userID))
+			return urlStore, userID
+		}
+
+		for _, target := range []string{"/" + shortURL + "+", "/" + shortURL + "?preview=1"} {
+			t.Run("preview page "+target, func(t *testing.T) {
+				urlStore, _ := addURL(t)
+				sut := New(urlStore, baseURL)
+				request := httptest.NewRequest(http.MethodGet, target, nil)
+				response := httptest.NewRecorder()
+
+				sut.ServeHTTP(response, request)
+
+				assert.Equal(t, http.StatusOK, response.Code)
+				assertLocation(t, "", response)
+				assertContentType(t, textHTML, response)
+				body := response.Body.String()
+				assert.Contains(t, body, testURL)
+				assert.Contains(t, body, title)
+				assert.Contains(t, body, "2024-03-01 12:00:00 UTC")
+				assert.Contains(t, body, `href="`+baseURL+`/`+shortURL+`"`)
+			})
+		}
+
+		t.Run("continue link uses base url", func(t *testing.T) {
+			const base = "https://sho.rt/s"
+			urlStore, _ := addURL(t)
+			sut := New(urlStore, base)
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			body := response.Body.String()
-			got := getShortURL(t, response.Body)
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertRedirectURL(t, got, urlStore)
-			assertContentType(t, applicationJSON, response)
-			assertContentLenght(t, len(body), response)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Contains(t, response.Body.String(), `href="`+base+`/`+shortURL+`"`)
 		})
 
-		t.Run("content type is not application-json", func(t *testing.T) {
+		t.Run("url not found", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			request := newShortenAPIRequest(t, testURL)
-			request.Header.Set(contentTypeHeader, textPlain)
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusUnsupportedMediaType, response.Code)
+			assert.Equal(t, http.StatusNotFound, response.Code)
 		})
 
-		t.Run("shorten same url twice", func(t *testing.T) {
-			urlStore, cleanup := u.CreateDependencies()
-			t.Cleanup(cleanup)
+		t.Run("url is deleted", func(t *testing.T) {
+			urlStore, userID := addURL(t)
+			_, err := urlStore.DeleteUserURLs(context.Background(), []string{shortURL}, userID)
+			require.NoError(t, err)
 			sut := New(urlStore, baseURL)
-
-			//1
-			request := newShortenAPIRequest(t, testURL)
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			got := getShortURL(t, response.Body)
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertRedirectURL(t, got, urlStore)
-
-			//2
-			request = newShortenAPIRequest(t, testURL)
-			response = httptest.NewRecorder()
-
-			sut.ServeHTTP(response, request)
-
-			assert.Equal(t, http.StatusConflict, response.Code)
-			got = getShortURL(t, response.Body)
-			assertRedirectURL(t, got, urlStore)
+			assert.Equal(t, http.StatusGone, response.Code)
 		})
 
-		t.Run("url is empty", func(t *testing.T) {
+		t.Run("scheduled url is not previewed", func(t *testing.T) {
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, ActiveFrom: time.Now().Add(time.Hour)}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
 			sut := New(urlStore, baseURL)
-			request := newShortenAPIRequest(t, "")
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusBadRequest, response.Code)
-			assertBody(t, urlIsEmptyMessage, response)
+			assert.Equal(t, http.StatusNotFound, response.Code)
+			assert.NotContains(t, response.Body.String(), testURL)
 		})
 
-		t.Run("request json is invalid", func(t *testing.T) {
+		t.Run("protected url shows password form", func(t *testing.T) {
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, PasswordHash: "hash"}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
 			sut := New(urlStore, baseURL)
-			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader("{{]}"))
-			request.Header.Set(contentTypeHeader, applicationJSON)
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusBadRequest, response.Code)
-			assertBody(t, "failed to parse request", response)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.NotContains(t, response.Body.String(), testURL)
 		})
 
-		t.Run("client accepts br and gzip encodings", func(t *testing.T) {
+		t.Run("password form action uses base url", func(t *testing.T) {
+			const base = "https://sho.rt/s"
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, PasswordHash: "hash"}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newShortenAPIRequest(t, testURL)
-			request.Header.Set(acceptEncodingHeader, "br, "+gzipEncoding)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
+			sut := New(urlStore, base)
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertContentEncoding(t, gzipEncoding, response)
-			got := getShortURL(t, decodeResponse(t, response))
-			assertRedirectURL(t, got, urlStore)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Contains(t, response.Body.String(), `action="`+base+`/`+shortURL+`"`)
 		})
 
-		t.Run("client does not accept encoding", func(t *testing.T) {
-			urlStore, cleanup := u.CreateDependencies()
-			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newShortenAPIRequest(t, testURL)
+		t.Run("url with blocked domain is not previewed", func(t *testing.T) {
+			urlStore, _ := addURL(t)
+			domainPolicy, err := policy.New([]string{"practicum.yandex.ru"}, nil)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithDomainPolicy(domainPolicy))
+			request := httptest.NewRequest(http.MethodGet, "/"+shortURL+"+", nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assertContentEncoding(t, "", response)
+			assert.Equal(t, http.StatusUnavailableForLegalReasons, response.Code)
+			assert.NotContains(t, response.Body.String(), testURL)
 		})
 
-		t.Run("client sends encoded content", func(t *testing.T) {
+		t.Run("shorten url with title", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			request := newEncodedShortenAPIRequest(t, testURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, Title: title})
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			got := getShortURL(t, response.Body)
-			assertRedirectURL(t, got, urlStore)
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.Equal(t, title, pair.Title)
 		})
+	})
 
-		t.Run("failed to store url", func(t *testing.T) {
+	t.Run("getting qr code", func(t *testing.T) {
+		const shortURL = "EwHXdJfB"
+		addURL := func(t *testing.T) (domain.URLStore, domain.UserID) {
+			t.Helper()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL}
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			failingURLStore := domain.NewURLStoreDelegate(urlStore)
-			failingURLStore.AddURLFunc = func(ctx context.Context, pair domain.URLPair, userID domain.UserID) error {
-				return errors.New("failed to add url")
-			}
-			sut := New(failingURLStore, baseURL)
-			request := newEncodedShortenAPIRequest(t, testURL)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, userID))
+			return urlStore, userID
+		}
+
+		t.Run("png qr code", func(t *testing.T) {
+			urlStore, _ := addURL(t)
+			sut := New(urlStore, baseURL)
+			request := newGetQRCodeRequest(shortURL, url.Values{"size": {"200"}, "level": {"H"}, "margin": {"2"}})
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusInternalServerError, response.Code)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, "image/png", response)
+			img, err := png.Decode(response.Body)
+			require.NoError(t, err)
+			assert.Equal(t, 200, img.Bounds().Dx())
 		})
-	})
 
-	t.Run("put method not allowed", func(t *testing.T) {
-		want := http.StatusMethodNotAllowed
-		urlStore, cleanup := u.CreateDependencies()
-		t.Cleanup(cleanup)
-		sut := New(urlStore, baseURL)
-		request := newPutRequest(testURL)
-		response := httptest.NewRecorder()
+		t.Run("svg qr code", func(t *testing.T) {
+			urlStore, _ := addURL(t)
+			sut := New(urlStore, baseURL)
+			request := newGetQRCodeRequest(shortURL, url.Values{"format": {"svg"}})
+			response := httptest.NewRecorder()
 
-		sut.ServeHTTP(response, request)
+			sut.ServeHTTP(response, request)
 
-		assert.Equal(t, want, response.Code)
-	})
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, "image/svg+xml", response)
+			assert.True(t, strings.HasPrefix(response.Body.String(), "<svg "))
+		})
 
-	t.Run("pinging service", func(t *testing.T) {
-		t.Run("service is avaiable", func(t *testing.T) {
-			urlStore, cleanup := u.CreateDependencies()
-			t.Cleanup(cleanup)
+		t.Run("options are invalid", func(t *testing.T) {
+			urlStore, _ := addURL(t)
 			sut := New(urlStore, baseURL)
-			request := newPingRequest()
+			request := newGetQRCodeRequest(shortURL, url.Values{"level": {"X"}})
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Equal(t, http.StatusBadRequest, response.Code)
 		})
 
-		t.Run("service is not avaiable", func(t *testing.T) {
+		t.Run("url not found", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			unavailableURLStore := domain.NewURLStoreDelegate(urlStore)
-			unavailableURLStore.IsAvailableFunc = func(ctx context.Context) bool {
-				return false
+			sut := New(urlStore, baseURL)
+			request := newGetQRCodeRequest(shortURL, nil)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("url is deleted", func(t *testing.T) {
+			urlStore, userID := addURL(t)
+			_, err := urlStore.DeleteUserURLs(context.Background(), []string{shortURL}, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetQRCodeRequest(shortURL, nil)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusGone, response.Code)
+		})
+	})
+
+	t.Run("shortening url", func(t *testing.T) {
+		t.Run("shorten url", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest(testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertRedirectURL(t, response.Body.String(), urlStore)
+		})
+
+		t.Run("content type is not text-plain", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest(testURL)
+			request.Header.Set(contentTypeHeader, "application/xml")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnsupportedMediaType, response.Code)
+			assertBody(t, "", response)
+		})
+
+		t.Run("shorten same url twice", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+
+			//1
+			request := newShortenRequest(testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertRedirectURL(t, response.Body.String(), urlStore)
+
+			//2
+			request = newShortenRequest(testURL)
+			response = httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assertRedirectURL(t, response.Body.String(), urlStore)
+		})
+
+		t.Run("url is empty", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest("")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrURLIsEmpty.Error(), response)
+		})
+
+		t.Run("url is not valid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest("javascript:alert(1)")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, canonical.ErrURLIsInvalid.Error(), response)
+		})
+
+		t.Run("domain is not allowed", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			domainPolicy, err := policy.New(nil, []string{"example.com"})
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithDomainPolicy(domainPolicy))
+			request := newShortenRequest(testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+			assertBody(t, "domain is not allowed: practicum.yandex.ru", response)
+		})
+
+		t.Run("url scheme is allowed by option", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			canonicalizer := canonical.New(canonical.WithSchemes("ftp"))
+			sut := New(urlStore, baseURL, WithCanonicalizer(canonicalizer))
+			request := newShortenRequest("ftp://example.com/file")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+		})
+
+		t.Run("client accepts br and gzip encodings", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest(testURL)
+			request.Header.Set(acceptEncodingHeader, "br, "+gzipEncoding)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertContentEncoding(t, gzipEncoding, response)
+			got := getDecoded(t, response.Body)
+			assertRedirectURL(t, got, urlStore)
+		})
+
+		t.Run("client sends content type x-gzip", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newEncodedShortenRequest(t, testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertContentEncoding(t, "", response)
+			assertRedirectURL(t, response.Body.String(), urlStore)
+		})
+
+		t.Run("failed to store url", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.AddURLFunc = func(ctx context.Context, pair domain.URLPair, userID domain.UserID) error {
+				return errors.New("failed to add url")
 			}
-			sut := New(unavailableURLStore, baseURL)
-			request := newPingRequest()
+			sut := New(failingURLStore, baseURL)
+			request := newShortenRequest(testURL)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
 			assert.Equal(t, http.StatusInternalServerError, response.Code)
 		})
+
+		t.Run("shorten url with alias", func(t *testing.T) {
+			const alias = "Promo25"
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest(testURL)
+			request.URL.RawQuery = url.Values{"alias": {alias}}.Encode()
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertBody(t, baseURL+"/"+alias, response)
+			assertRedirectURL(t, response.Body.String(), urlStore)
+		})
+
+		t.Run("alias is reserved", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenRequest(testURL)
+			request.URL.RawQuery = url.Values{"alias": {"api"}}.Encode()
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, shortener.ErrKeyIsReserved.Error(), response)
+		})
 	})
 
-	t.Run("batch shortening urls (api)", func(t *testing.T) {
-		t.Run("shorten urls", func(t *testing.T) {
+	t.Run("shortening url (api)", func(t *testing.T) {
+		t.Run("shorten url", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{"https://practicum.yandex.ru/", "https://google.com/"})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			request := newShortenAPIRequest(t, testURL)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
+			body := response.Body.String()
+			got := getShortURL(t, response.Body)
 			assert.Equal(t, http.StatusCreated, response.Code)
+			assertRedirectURL(t, got, urlStore)
 			assertContentType(t, applicationJSON, response)
-			assertShortURLs(t, originalURLs, response.Body, urlStore)
+			assertContentLenght(t, len(body), response)
+		})
+
+		t.Run("shorten url with max clicks", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, MaxClicks: 3})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.Equal(t, 3, pair.MaxClicks)
+			assert.Equal(t, 3, pair.ClicksLeft)
+		})
+
+		t.Run("shorten url with activation time", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			activeFrom := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, ActiveFrom: &activeFrom})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.True(t, activeFrom.Equal(pair.ActiveFrom))
+		})
+
+		t.Run("activation time is after expiration time", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			activeFrom := time.Now().Add(time.Hour)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, ActiveFrom: &activeFrom, TTL: 60})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		})
+
+		t.Run("max clicks is negative", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, MaxClicks: -1})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
 		})
 
 		t.Run("content type is not application-json", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{testURL})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			request := newShortenAPIRequest(t, testURL)
 			request.Header.Set(contentTypeHeader, textPlain)
 			response := httptest.NewRecorder()
 
@@ -470,206 +890,2288 @@ func (u URLShortenerTest) Test(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{testURL})
 
 			//1
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			request := newShortenAPIRequest(t, testURL)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
+			got := getShortURL(t, response.Body)
 			assert.Equal(t, http.StatusCreated, response.Code)
-			assertShortURLs(t, originalURLs, response.Body, urlStore)
+			assertRedirectURL(t, got, urlStore)
 
 			//2
-			request = newShortenURLsAPIRequest(t, originalURLs)
+			request = newShortenAPIRequest(t, testURL)
 			response = httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertShortURLs(t, originalURLs, response.Body, urlStore)
+			assert.Equal(t, http.StatusConflict, response.Code)
+			got = getShortURL(t, response.Body)
+			assertRedirectURL(t, got, urlStore)
 		})
 
-		t.Run("batch is empty", func(t *testing.T) {
+		t.Run("url is empty", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			request := newShortenAPIRequest(t, "")
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
 			assert.Equal(t, http.StatusBadRequest, response.Code)
-			assertBody(t, batchIsEmptyMessage, response)
+			assertBody(t, service.ErrURLIsEmpty.Error(), response)
 		})
 
-		t.Run("url is empty", func(t *testing.T) {
+		t.Run("request json is invalid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader("{{]}"))
+			request.Header.Set(contentTypeHeader, applicationJSON)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, "failed to parse request", response)
+		})
+
+		t.Run("client accepts br and gzip encodings", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequest(t, testURL)
+			request.Header.Set(acceptEncodingHeader, "br, "+gzipEncoding)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertContentEncoding(t, gzipEncoding, response)
+			got := getShortURL(t, decodeResponse(t, response))
+			assertRedirectURL(t, got, urlStore)
+		})
+
+		t.Run("client does not accept encoding", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequest(t, testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assertContentEncoding(t, "", response)
+		})
+
+		t.Run("client sends encoded content", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newEncodedShortenAPIRequest(t, testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			got := getShortURL(t, response.Body)
+			assertRedirectURL(t, got, urlStore)
+		})
+
+		t.Run("failed to store url", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.AddURLFunc = func(ctx context.Context, pair domain.URLPair, userID domain.UserID) error {
+				return errors.New("failed to add url")
+			}
+			sut := New(failingURLStore, baseURL)
+			request := newEncodedShortenAPIRequest(t, testURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+		})
+
+		t.Run("shorten url with alias", func(t *testing.T) {
+			const alias = "Promo25"
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIAliasRequest(t, testURL, alias)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			got := getShortURL(t, response.Body)
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.Equal(t, baseURL+"/"+alias, got)
+			assertRedirectURL(t, got, urlStore)
+		})
+
+		t.Run("alias contains invalid characters", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIAliasRequest(t, testURL, "promo-0")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, shortener.ErrKeyIsInvalid.Error(), response)
+		})
+
+		t.Run("alias is taken by other url", func(t *testing.T) {
+			const alias = "Promo25"
+			ctx := context.Background()
+			pair := domain.URLPair{
+				ShortURL:    alias,
+				OriginalURL: "http://example.com",
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(ctx, pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIAliasRequest(t, testURL, alias)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assertBody(t, aliasIsTakenMessage, response)
+		})
+
+		t.Run("shorten url with ttl", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, TTL: 60})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			got := getShortURL(t, response.Body)
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertRedirectURL(t, got, urlStore)
+		})
+
+		t.Run("expiration time is in the past", func(t *testing.T) {
+			expiresAt := time.Now().Add(-time.Minute)
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, ExpiresAt: &expiresAt})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrExpirationIsInPast.Error(), response)
+		})
+
+		t.Run("both expiration time and ttl are set", func(t *testing.T) {
+			expiresAt := time.Now().Add(time.Hour)
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, ExpiresAt: &expiresAt, TTL: 60})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrExpirationIsAmbiguous.Error(), response)
+		})
+
+		t.Run("ttl is negative", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newShortenAPIRequestFrom(t, ShortenRequest{URL: testURL, TTL: -1})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrTTLIsNegative.Error(), response)
+		})
+	})
+
+	t.Run("put method not allowed", func(t *testing.T) {
+		want := http.StatusMethodNotAllowed
+		urlStore, cleanup := u.CreateDependencies()
+		t.Cleanup(cleanup)
+		sut := New(urlStore, baseURL)
+		request := newPutRequest(testURL)
+		response := httptest.NewRecorder()
+
+		sut.ServeHTTP(response, request)
+
+		assert.Equal(t, want, response.Code)
+	})
+
+	t.Run("pinging service", func(t *testing.T) {
+		t.Run("service is avaiable", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newPingRequest()
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+		})
+
+		t.Run("service is not avaiable", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			unavailableURLStore := domain.NewURLStoreDelegate(urlStore)
+			unavailableURLStore.IsAvailableFunc = func(ctx context.Context) bool {
+				return false
+			}
+			sut := New(unavailableURLStore, baseURL)
+			request := newPingRequest()
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+		})
+	})
+
+	t.Run("batch shortening urls (api)", func(t *testing.T) {
+		t.Run("shorten urls", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{"https://practicum.yandex.ru/", "https://google.com/"})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertContentType(t, applicationJSON, response)
+			assertShortURLs(t, originalURLs, response.Body, urlStore)
+		})
+
+		t.Run("content type is not application-json", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			request.Header.Set(contentTypeHeader, textPlain)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnsupportedMediaType, response.Code)
+		})
+
+		t.Run("shorten same url twice", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+
+			//1
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertShortURLs(t, originalURLs, response.Body, urlStore)
+
+			//2
+			request = newShortenURLsAPIRequest(t, originalURLs)
+			response = httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertShortURLs(t, originalURLs, response.Body, urlStore)
+		})
+
+		t.Run("batch is empty", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrBatchIsEmpty.Error(), response)
+		})
+
+		t.Run("url is empty", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{""})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrURLIsEmpty.Error(), response)
+		})
+
+		t.Run("request json is invalid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader("{{]}"))
+			request.Header.Set(contentTypeHeader, applicationJSON)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, "failed to parse request", response)
+		})
+
+		t.Run("client accepts br and gzip encodings", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			request.Header.Set(acceptEncodingHeader, "br, "+gzipEncoding)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertContentEncoding(t, gzipEncoding, response)
+			assertShortURLs(t, originalURLs, decodeResponse(t, response), urlStore)
+		})
+
+		t.Run("client does not accept encoding", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assertContentEncoding(t, "", response)
+		})
+
+		t.Run("client sends encoded content", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertShortURLs(t, originalURLs, response.Body, urlStore)
+		})
+
+		t.Run("failed to store url", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.AddURLsFunc = func(ctx context.Context, pairs []domain.URLPair, userID domain.UserID) error {
+				return errors.New("failed to add url")
+			}
+			sut := New(failingURLStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+		})
+
+		t.Run("request posts for too many urls", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL, WithShortenURLsMaxCount(2))
+			urls := []string{
+				"foo.net",
+				"bar.net",
+				"buz.net",
+			}
+			originalURLs := newBatch(urls)
+
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			assertBody(t, "to many urls", response)
+		})
+
+		t.Run("shorten urls with expiration", func(t *testing.T) {
+			expiresAt := time.Now().Add(time.Hour)
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{"https://practicum.yandex.ru/", "https://google.com/"})
+			originalURLs[0].TTL = 60
+			originalURLs[1].ExpiresAt = &expiresAt
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assertShortURLs(t, originalURLs, response.Body, urlStore)
+		})
+
+		t.Run("shorten urls with tags", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{"https://practicum.yandex.ru/", "https://google.com/"})
+			originalURLs[0].Tags = []string{"team", "promo"}
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			var got []ShortURL
+			err := json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			require.Len(t, got, 2)
+			pair, err := urlStore.GetURL(context.Background(), strings.TrimPrefix(got[0].URL, baseURL+"/"))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"promo", "team"}, pair.Tags)
+		})
+
+		t.Run("batch contains url with negative ttl", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			originalURLs := newBatch([]string{testURL})
+			originalURLs[0].TTL = -1
+			request := newShortenURLsAPIRequest(t, originalURLs)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrTTLIsNegative.Error(), response)
+		})
+	})
+
+	t.Run("get user urls", func(t *testing.T) {
+		t.Run("get urls shortened by user", func(t *testing.T) {
+			userID := domain.NewUserID()
+			userURLs := []domain.URLPair{
+				{
+					OriginalURL: "http://yandex.ru",
+					ShortURL:    "123",
+				},
+				{
+					OriginalURL: "http://mail.ru",
+					ShortURL:    "456",
+				},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetUserURLsRequest(t, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			assertUserURLs(t, userURLs, response.Body)
+		})
+
+		t.Run("get urls page by page", func(t *testing.T) {
+			userID := domain.NewUserID()
+			createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			userURLs := []domain.URLPair{
+				{OriginalURL: "http://yandex.ru", ShortURL: "123", CreatedAt: createdAt},
+				{OriginalURL: "http://mail.ru", ShortURL: "456", CreatedAt: createdAt.Add(time.Hour)},
+				{OriginalURL: "http://ya.ru", ShortURL: "789", CreatedAt: createdAt.Add(2 * time.Hour)},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetUserURLsPageRequest(t, userID, url.Values{"limit": {"2"}, "order": {"asc"}})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertUserURLs(t, userURLs[:2], response.Body)
+			cursor := response.Header().Get(nextCursorHeader)
+			require.NotEmpty(t, cursor)
+
+			query := url.Values{"limit": {"2"}, "order": {"asc"}, "cursor": {cursor}}
+			request = newGetUserURLsPageRequest(t, userID, query)
+			response = httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertUserURLs(t, userURLs[2:], response.Body)
+			assert.Empty(t, response.Header().Get(nextCursorHeader))
+		})
+
+		t.Run("get urls sorted by creation time", func(t *testing.T) {
+			userID := domain.NewUserID()
+			createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			userURLs := []domain.URLPair{
+				{OriginalURL: "http://yandex.ru", ShortURL: "123", CreatedAt: createdAt},
+				{OriginalURL: "http://mail.ru", ShortURL: "456", CreatedAt: createdAt.Add(time.Hour)},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetUserURLsPageRequest(t, userID, url.Values{})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []UserURL
+			err = json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			require.Len(t, got, 2)
+			assert.Equal(t, baseURL+"/456", got[0].ShortURL)
+			assert.Equal(t, baseURL+"/123", got[1].ShortURL)
+		})
+
+		t.Run("get urls filtered by original url", func(t *testing.T) {
+			userID := domain.NewUserID()
+			userURLs := []domain.URLPair{
+				{OriginalURL: "http://yandex.ru/maps", ShortURL: "123"},
+				{OriginalURL: "http://mail.ru", ShortURL: "456"},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetUserURLsPageRequest(t, userID, url.Values{"filter": {"yandex"}})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertUserURLs(t, userURLs[:1], response.Body)
+		})
+
+		t.Run("get urls filtered by tag", func(t *testing.T) {
+			userID := domain.NewUserID()
+			userURLs := []domain.URLPair{
+				{OriginalURL: "http://yandex.ru", ShortURL: "123", Tags: []string{"promo", "team"}},
+				{OriginalURL: "http://mail.ru", ShortURL: "456", Tags: []string{"team"}},
+				{OriginalURL: "http://ya.ru", ShortURL: "789"},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetUserURLsPageRequest(t, userID, url.Values{"tag": {"promo"}})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertUserURLs(t, userURLs[:1], response.Body)
+		})
+
+		t.Run("get urls with status", func(t *testing.T) {
+			userID := domain.NewUserID()
+			activeFrom := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			userURLs := []domain.URLPair{
+				{OriginalURL: "http://yandex.ru", ShortURL: "123", ActiveFrom: activeFrom},
+				{OriginalURL: "http://mail.ru", ShortURL: "456", MaxClicks: 1},
+				{OriginalURL: "http://ya.ru", ShortURL: "789"},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetUserURLsPageRequest(t, userID, url.Values{})
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []UserURL
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			statuses := make(map[string]string, len(got))
+			for _, userURL := range got {
+				statuses[userURL.ShortURL] = userURL.Status
+				if userURL.ShortURL == baseURL+"/123" {
+					require.NotNil(t, userURL.ActiveFrom)
+					assert.True(t, activeFrom.Equal(*userURL.ActiveFrom))
+				}
+			}
+			want := map[string]string{
+				baseURL + "/123": urlStatusScheduled,
+				baseURL + "/456": urlStatusExhausted,
+				baseURL + "/789": urlStatusActive,
+			}
+			assert.Equal(t, want, statuses)
+		})
+
+		t.Run("invalid page request", func(t *testing.T) {
+			tests := []struct {
+				query url.Values
+				name  string
+				want  string
+			}{
+				{
+					name:  "limit is not a number",
+					query: url.Values{"limit": {"ten"}},
+					want:  service.ErrLimitIsInvalid.Error(),
+				},
+				{
+					name:  "limit is too big",
+					query: url.Values{"limit": {"100000"}},
+					want:  service.ErrLimitIsInvalid.Error(),
+				},
+				{
+					name:  "order is unknown",
+					query: url.Values{"order": {"random"}},
+					want:  service.ErrOrderIsInvalid.Error(),
+				},
+				{
+					name:  "cursor is invalid",
+					query: url.Values{"cursor": {"!!!"}},
+					want:  domain.ErrInvalidCursor.Error(),
+				},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					urlStore, cleanup := u.CreateDependencies()
+					t.Cleanup(cleanup)
+					sut := New(urlStore, baseURL)
+					request := newGetUserURLsPageRequest(t, domain.NewUserID(), tt.query)
+					response := httptest.NewRecorder()
+
+					sut.ServeHTTP(response, request)
+
+					assert.Equal(t, http.StatusBadRequest, response.Code)
+					assertBody(t, tt.want, response)
+				})
+			}
+		})
+
+		t.Run("no urls shortened by user", func(t *testing.T) {
+			otherUserID := domain.NewUserID()
+			userURLs := []domain.URLPair{
+				{
+					OriginalURL: "http://yandex.ru",
+					ShortURL:    "123",
+				},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(context.Background(), userURLs, otherUserID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			userID := domain.NewUserID()
+			request := newGetUserURLsRequest(t, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNoContent, response.Code)
+			assertBody(t, "no urls", response)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := httptest.NewRequest(http.MethodGet, userURLsPath, nil)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	})
+
+	t.Run("rotating destinations", func(t *testing.T) {
+		const (
+			shortURL = "EwHXdJfB"
+			urlA     = "https://example.com/a"
+			urlB     = "https://example.com/b"
+		)
+		addURL := func(t *testing.T, rotation string, weightA int) (domain.URLStore, domain.UserID) {
+			t.Helper()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: urlA,
+				Destinations: []domain.Destination{
+					{Name: "a", URL: urlA, Weight: weightA},
+					{Name: "b", URL: urlB, Weight: 1},
+				},
+				Rotation: rotation,
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, userID))
+			return urlStore, userID
+		}
+
+		t.Run("shorten url with destinations", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{
+				Destinations: []Destination{{Name: "a", URL: urlA, Weight: 1}, {Name: "b", URL: urlB, Weight: 1}},
+				Rotation:     domain.RotationSticky,
+			}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.Equal(t, urlA, pair.OriginalURL)
+			assert.Equal(t, domain.RotationSticky, pair.Rotation)
+			assert.Len(t, pair.Destinations, 2)
+		})
+
+		t.Run("destinations are invalid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{Destinations: []Destination{{URL: urlA}, {URL: urlB}}}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		})
+
+		t.Run("redirect to weighted variant", func(t *testing.T) {
+			urlStore, userID := addURL(t, domain.RotationRandom, 0)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			assertLocation(t, urlB, response)
+			assert.Empty(t, response.Result().Cookies())
+			response = httptest.NewRecorder()
+			sut.ServeHTTP(response, newGetClickStatsRequest(t, shortURL, userID))
+			var got URLClickStats
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			assert.Equal(t, []VariantClicks{{Variant: "b", Count: 1}}, got.Variants)
+		})
+
+		t.Run("sticky variant is saved in cookie", func(t *testing.T) {
+			urlStore, _ := addURL(t, domain.RotationSticky, 0)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			assertLocation(t, urlB, response)
+			cookies := response.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, variantCookiePrefix+shortURL, cookies[0].Name)
+			assert.Equal(t, "b", cookies[0].Value)
+			assert.Equal(t, "/"+shortURL, cookies[0].Path)
+		})
+
+		t.Run("sticky variant is kept", func(t *testing.T) {
+			urlStore, _ := addURL(t, domain.RotationSticky, 1)
+			sut := New(urlStore, baseURL)
+
+			for i := 0; i < 10; i++ {
+				request := newGetRequest(shortURL)
+				request.AddCookie(&http.Cookie{Name: variantCookiePrefix + shortURL, Value: "a"})
+				response := httptest.NewRecorder()
+
+				sut.ServeHTTP(response, request)
+
+				assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+				assertLocation(t, urlA, response)
+			}
+		})
+
+		t.Run("user urls contain destinations", func(t *testing.T) {
+			urlStore, userID := addURL(t, domain.RotationSticky, 1)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetUserURLsPageRequest(t, userID, url.Values{}))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []UserURL
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			require.Len(t, got, 1)
+			assert.Equal(t, domain.RotationSticky, got[0].Rotation)
+			assert.Equal(t, []Destination{
+				{Name: "a", URL: urlA, Weight: 1},
+				{Name: "b", URL: urlB, Weight: 1},
+			}, got[0].Destinations)
+		})
+	})
+
+	t.Run("routing rules", func(t *testing.T) {
+		const (
+			shortURL    = "EwHXdJfB"
+			webURL      = "https://example.com/app"
+			appStoreURL = "https://apps.apple.com/app/id1"
+			playURL     = "https://play.google.com/store/apps/details?id=com.example"
+			germanURL   = "https://example.com/de/app"
+		)
+		addURL := func(t *testing.T) (domain.URLStore, domain.UserID) {
+			t.Helper()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: webURL,
+				Rules: []domain.RoutingRule{
+					{OS: "ios", URL: appStoreURL},
+					{OS: "android", Device: "mobile", URL: playURL},
+					{Language: "de", URL: germanURL},
+				},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, userID))
+			return urlStore, userID
+		}
+
+		t.Run("redirect by client", func(t *testing.T) {
+			tests := []struct {
+				name           string
+				userAgent      string
+				acceptLanguage string
+				want           string
+			}{
+				{
+					name:      "ios",
+					userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Mobile/15E148",
+					want:      appStoreURL,
+				},
+				{
+					name:      "android phone",
+					userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) Chrome/124.0 Mobile Safari/537.36",
+					want:      playURL,
+				},
+				{
+					name:           "android tablet with german language",
+					userAgent:      "Mozilla/5.0 (Linux; Android 13; SM-X700) Chrome/124.0 Safari/537.36",
+					acceptLanguage: "de-DE,de;q=0.9,en;q=0.8",
+					want:           germanURL,
+				},
+				{
+					name:           "desktop falls back to original url",
+					userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/124.0 Safari/537.36",
+					acceptLanguage: "en-US",
+					want:           webURL,
+				},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					urlStore, _ := addURL(t)
+					sut := New(urlStore, baseURL)
+					request := newGetRequest(shortURL)
+					request.Header.Set("User-Agent", tt.userAgent)
+					request.Header.Set(acceptLanguageHeader, tt.acceptLanguage)
+					response := httptest.NewRecorder()
+
+					sut.ServeHTTP(response, request)
+
+					assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+					assertLocation(t, tt.want, response)
+				})
+			}
+		})
+
+		t.Run("shorten url with routing rules", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{URL: webURL, Rules: []RoutingRule{{OS: "iOS", URL: appStoreURL}}}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.Equal(t, []domain.RoutingRule{{OS: "ios", URL: appStoreURL}}, pair.Rules)
+		})
+
+		t.Run("routing rules are invalid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{URL: webURL, Rules: []RoutingRule{{OS: "symbian", URL: appStoreURL}}}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrOSIsInvalid.Error(), response)
+		})
+
+		t.Run("update routing rules", func(t *testing.T) {
+			urlStore, userID := addURL(t)
+			sut := New(urlStore, baseURL)
+			rules := []RoutingRule{{Device: "Tablet", URL: playURL}}
+			request := newUpdateRoutingRulesRequest(t, shortURL, rules, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got URLRules
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			assert.Equal(t, []RoutingRule{{Device: "tablet", URL: playURL}}, got.Rules)
+			stored, err := urlStore.GetURL(context.Background(), shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, []domain.RoutingRule{{Device: "tablet", URL: playURL}}, stored.Rules)
+		})
+
+		t.Run("remove all routing rules", func(t *testing.T) {
+			urlStore, userID := addURL(t)
+			sut := New(urlStore, baseURL)
+			request := newUpdateRoutingRulesRequest(t, shortURL, nil, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"rules":[]}`, response.Body.String())
+		})
+
+		t.Run("update routing rules of url added by other user", func(t *testing.T) {
+			urlStore, _ := addURL(t)
+			sut := New(urlStore, baseURL)
+			rules := []RoutingRule{{OS: "ios", URL: appStoreURL}}
+			request := newUpdateRoutingRulesRequest(t, shortURL, rules, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, _ := addURL(t)
+			sut := New(urlStore, baseURL)
+			body := strings.NewReader(`{"rules":[]}`)
+			request := httptest.NewRequest(http.MethodPut, userURLsPath+"/"+shortURL+"/rules", body)
+			request.Header.Set(contentTypeHeader, applicationJSON)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+
+		t.Run("user urls contain routing rules", func(t *testing.T) {
+			urlStore, userID := addURL(t)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetUserURLsPageRequest(t, userID, url.Values{}))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []UserURL
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			require.Len(t, got, 1)
+			assert.Equal(t, []RoutingRule{
+				{OS: "ios", URL: appStoreURL},
+				{OS: "android", Device: "mobile", URL: playURL},
+				{Language: "de", URL: germanURL},
+			}, got[0].Rules)
+		})
+	})
+
+	t.Run("forwarding query and path", func(t *testing.T) {
+		const (
+			shortURL = "EwHXdJfB"
+			docsURL  = "https://example.com/docs?lang=en"
+		)
+		addURL := func(t *testing.T, pair domain.URLPair) domain.URLStore {
+			t.Helper()
+			pair.ShortURL = shortURL
+			pair.OriginalURL = docsURL
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
+			return urlStore
+		}
+
+		t.Run("query is merged into original url", func(t *testing.T) {
+			sut := New(addURL(t, domain.URLPair{}), baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL+"?utm_source=x&lang=de&lang=fr"))
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			assertLocation(t, "https://example.com/docs?lang=en&utm_source=x", response)
+		})
+
+		t.Run("path is forwarded", func(t *testing.T) {
+			sut := New(addURL(t, domain.URLPair{ForwardPath: true}), baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL+"/guide/install?utm_source=x"))
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			assertLocation(t, "https://example.com/docs/guide/install?lang=en&utm_source=x", response)
+		})
+
+		t.Run("path is not forwarded", func(t *testing.T) {
+			sut := New(addURL(t, domain.URLPair{}), baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL+"/guide"))
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("qr code route takes precedence", func(t *testing.T) {
+			sut := New(addURL(t, domain.URLPair{ForwardPath: true}), baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetQRCodeRequest(shortURL, url.Values{}))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, "image/png", response)
+		})
+
+		t.Run("reserved qr path is not forwarded", func(t *testing.T) {
+			const password = "secret"
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+			require.NoError(t, err)
+			sut := New(addURL(t, domain.URLPair{ForwardPath: true, PasswordHash: string(hash)}), baseURL)
+
+			response := httptest.NewRecorder()
+			sut.ServeHTTP(response, newGetRequest(shortURL+"/%71r"))
+			assert.Equal(t, http.StatusNotFound, response.Code)
+
+			response = httptest.NewRecorder()
+			sut.ServeHTTP(response, newUnlockRequest(shortURL+"/qr", password))
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("protected url keeps path and query", func(t *testing.T) {
+			const password = "secret"
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+			require.NoError(t, err)
+			sut := New(addURL(t, domain.URLPair{ForwardPath: true, PasswordHash: string(hash)}), baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetRequest(shortURL+"/faq?utm_source=x"))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Contains(t, response.Body.String(), `action="/`+shortURL+`/faq?utm_source=x"`)
+			response = httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newUnlockRequest(shortURL+"/faq?utm_source=x", password))
+
+			assert.Equal(t, http.StatusSeeOther, response.Code)
+			assertLocation(t, "https://example.com/docs/faq?lang=en&utm_source=x", response)
+		})
+
+		t.Run("shorten url with forwarded path", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{URL: docsURL, ForwardPath: true}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.True(t, pair.ForwardPath)
+		})
+	})
+
+	t.Run("redirect status", func(t *testing.T) {
+		const shortURL = "EwHXdJfB"
+		permanentCacheControl := "private, max-age=86400"
+		addURL := func(t *testing.T, redirectStatus int) (domain.URLStore, domain.UserID) {
+			t.Helper()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL, RedirectStatus: redirectStatus}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, userID))
+			return urlStore, userID
+		}
+
+		tests := []struct {
+			name             string
+			serverStatus     int
+			urlStatus        int
+			wantStatus       int
+			wantCacheControl string
+		}{
+			{
+				name:             "default server status",
+				wantStatus:       http.StatusTemporaryRedirect,
+				wantCacheControl: "no-store",
+			},
+			{
+				name:             "server status",
+				serverStatus:     http.StatusFound,
+				wantStatus:       http.StatusFound,
+				wantCacheControl: "no-store",
+			},
+			{
+				name:             "url status overrides server status",
+				serverStatus:     http.StatusFound,
+				urlStatus:        http.StatusMovedPermanently,
+				wantStatus:       http.StatusMovedPermanently,
+				wantCacheControl: permanentCacheControl,
+			},
+			{
+				name:             "permanent server status",
+				serverStatus:     http.StatusPermanentRedirect,
+				wantStatus:       http.StatusPermanentRedirect,
+				wantCacheControl: permanentCacheControl,
+			},
+			{
+				name:             "temporary url status",
+				serverStatus:     http.StatusPermanentRedirect,
+				urlStatus:        http.StatusTemporaryRedirect,
+				wantStatus:       http.StatusTemporaryRedirect,
+				wantCacheControl: "no-store",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				urlStore, _ := addURL(t, tt.urlStatus)
+				sut := New(urlStore, baseURL, WithRedirectStatus(tt.serverStatus))
+				response := httptest.NewRecorder()
+
+				sut.ServeHTTP(response, newGetRequest(shortURL))
+
+				assert.Equal(t, tt.wantStatus, response.Code)
+				assertLocation(t, testURL, response)
+				assert.Equal(t, tt.wantCacheControl, response.Header().Get(cacheControlHeader))
+			})
+		}
+
+		t.Run("dynamic url with permanent status is not cached", func(t *testing.T) {
+			pairs := map[string]domain.URLPair{
+				"max clicks":   {MaxClicks: 10, ClicksLeft: 10},
+				"expires at":   {ExpiresAt: time.Now().Add(time.Hour)},
+				"active from":  {ActiveFrom: time.Now().Add(-time.Hour)},
+				"destinations": {Destinations: []domain.Destination{{Name: "a", URL: testURL, Weight: 1}}},
+				"rules":        {Rules: []domain.RoutingRule{{URL: testURL}}},
+			}
+			for name, pair := range pairs {
+				t.Run(name, func(t *testing.T) {
+					pair.ShortURL = shortURL
+					pair.OriginalURL = testURL
+					pair.RedirectStatus = http.StatusMovedPermanently
+					urlStore, cleanup := u.CreateDependencies()
+					t.Cleanup(cleanup)
+					require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
+					sut := New(urlStore, baseURL)
+					response := httptest.NewRecorder()
+
+					sut.ServeHTTP(response, newGetRequest(shortURL))
+
+					assert.Equal(t, http.StatusMovedPermanently, response.Code)
+					assertLocation(t, testURL, response)
+					assert.Equal(t, "no-store", response.Header().Get(cacheControlHeader))
+				})
+			}
+		})
+
+		t.Run("unlocked url is redirected with see other", func(t *testing.T) {
+			const password = "secret"
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+			require.NoError(t, err)
+			pair := domain.URLPair{
+				ShortURL:       shortURL,
+				OriginalURL:    testURL,
+				PasswordHash:   string(hash),
+				RedirectStatus: http.StatusMovedPermanently,
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(context.Background(), pair, domain.NewUserID()))
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newUnlockRequest(shortURL, password))
+
+			assert.Equal(t, http.StatusSeeOther, response.Code)
+			assert.Equal(t, "no-store", response.Header().Get(cacheControlHeader))
+		})
+
+		t.Run("shorten url with redirect status", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{URL: testURL, RedirectStatus: http.StatusPermanentRedirect}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			require.Equal(t, http.StatusCreated, response.Code)
+			urlPath, err := getURLPath(getShortURL(t, response.Body))
+			require.NoError(t, err)
+			pair, err := urlStore.GetURL(context.Background(), strings.Trim(urlPath, "/"))
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusPermanentRedirect, pair.RedirectStatus)
+		})
+
+		t.Run("redirect status is invalid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			req := ShortenRequest{URL: testURL, RedirectStatus: http.StatusSeeOther}
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newShortenAPIRequestFrom(t, req))
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrRedirectStatusIsInvalid.Error(), response)
+		})
+
+		t.Run("user urls contain redirect status", func(t *testing.T) {
+			urlStore, userID := addURL(t, http.StatusFound)
+			sut := New(urlStore, baseURL)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, newGetUserURLsPageRequest(t, userID, url.Values{}))
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []UserURL
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			require.Len(t, got, 1)
+			assert.Equal(t, http.StatusFound, got[0].RedirectStatus)
+		})
+	})
+
+	t.Run("get click stats", func(t *testing.T) {
+		t.Run("get stats of redirects by short url", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(ctx, pair, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+
+			for i := 0; i < 2; i++ {
+				sut.ServeHTTP(httptest.NewRecorder(), newGetRequest(shortURL))
+			}
+
+			request := newGetClickStatsRequest(t, shortURL, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got URLClickStats
+			err = json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, baseURL+"/"+shortURL, got.ShortURL)
+			assert.Equal(t, 2, got.Total)
+			require.Len(t, got.Daily, 1)
+			assert.Equal(t, time.Now().UTC().Format(time.DateOnly), got.Daily[0].Date)
+			assert.Equal(t, 2, got.Daily[0].Count)
+		})
+
+		t.Run("record clicks asynchronously", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(ctx, pair, userID)
+			require.NoError(t, err)
+			doneCh := make(chan struct{})
+			t.Cleanup(func() { close(doneCh) })
+			recorder := NewClickRecorder(ctx, doneCh, urlStore, time.Millisecond, zap.NewNop())
+			sut := New(urlStore, baseURL, WithClickRecorder(recorder))
+
+			sut.ServeHTTP(httptest.NewRecorder(), newGetRequest(shortURL))
+
+			assert.Eventually(t, func() bool {
+				stats, err := urlStore.GetClickStats(ctx, shortURL, userID)
+				return err == nil && stats.Total == 1
+			}, time.Second, time.Millisecond)
+		})
+
+		t.Run("url is added by other user", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
+			pair := domain.URLPair{
+				ShortURL:    shortURL,
+				OriginalURL: testURL,
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newGetClickStatsRequest(t, shortURL, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+
+		t.Run("url not found", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newGetClickStatsRequest(t, "EwHXdJfB", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := httptest.NewRequest(http.MethodGet, userURLsPath+"/EwHXdJfB/stats", nil)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	})
+
+	t.Run("update user url", func(t *testing.T) {
+		t.Run("update original url", func(t *testing.T) {
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: "123", OriginalURL: "http://yandex.ry"}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(ctx, pair, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newUpdateUserURLRequest(t, pair.ShortURL, "http://yandex.ru", userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got UserURL
+			err = json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, UserURL{ShortURL: baseURL + "/123", OriginalURL: "http://yandex.ru"}, got)
+			originalURL, err := urlStore.GetOriginalURL(ctx, pair.ShortURL)
+			require.NoError(t, err)
+			assert.Equal(t, "http://yandex.ru", originalURL)
+		})
+
+		t.Run("original url is shortened before", func(t *testing.T) {
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			pairs := []domain.URLPair{
+				{ShortURL: "123", OriginalURL: "http://yandex.ry"},
+				{ShortURL: "456", OriginalURL: "http://yandex.ru"},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(ctx, pairs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newUpdateUserURLRequest(t, "123", "http://yandex.ru", userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusConflict, response.Code)
+			var got ShortenResponse
+			err = json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, baseURL+"/456", got.Result)
+		})
+
+		t.Run("url is added by other user", func(t *testing.T) {
+			pair := domain.URLPair{ShortURL: "123", OriginalURL: "http://yandex.ry"}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newUpdateUserURLRequest(t, pair.ShortURL, "http://yandex.ru", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+
+		t.Run("url not found", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newUpdateUserURLRequest(t, "123", "http://yandex.ru", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("url is empty", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newUpdateUserURLRequest(t, "123", "", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrURLIsEmpty.Error(), response)
+		})
+
+		t.Run("url scheme is not allowed", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newUpdateUserURLRequest(t, "123", "ftp://example.com/file", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, canonical.ErrSchemeIsNotAllowed.Error(), response)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			body := strings.NewReader(`{"url":"http://yandex.ru"}`)
+			request := httptest.NewRequest(http.MethodPatch, userURLsPath+"/123", body)
+			request.Header.Set(contentTypeHeader, applicationJSON)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	})
+
+	t.Run("update url tags", func(t *testing.T) {
+		t.Run("update tags", func(t *testing.T) {
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: "123", OriginalURL: "http://yandex.ru", Tags: []string{"promo"}}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(ctx, pair, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newUpdateTagsRequest(t, pair.ShortURL, []string{"team", " spring "}, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got URLTags
+			err = json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"spring", "team"}, got.Tags)
+			stored, err := urlStore.GetURL(ctx, pair.ShortURL)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"spring", "team"}, stored.Tags)
+		})
+
+		t.Run("remove all tags", func(t *testing.T) {
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: "123", OriginalURL: "http://yandex.ru", Tags: []string{"promo"}}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newUpdateTagsRequest(t, pair.ShortURL, nil, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"tags":[]}`, response.Body.String())
+		})
+
+		t.Run("url is added by other user", func(t *testing.T) {
+			pair := domain.URLPair{ShortURL: "123", OriginalURL: "http://yandex.ru"}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newUpdateTagsRequest(t, pair.ShortURL, []string{"promo"}, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+
+		t.Run("url not found", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newUpdateTagsRequest(t, "123", []string{"promo"}, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("tag is too long", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			tags := []string{strings.Repeat("a", service.MaxTagLength+1)}
+			request := newUpdateTagsRequest(t, "123", tags, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, service.ErrTagIsTooLong.Error(), response)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			body := strings.NewReader(`{"tags":["promo"]}`)
+			request := httptest.NewRequest(http.MethodPut, userURLsPath+"/123/tags", body)
+			request.Header.Set(contentTypeHeader, applicationJSON)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	})
+
+	t.Run("get internal stats", func(t *testing.T) {
+		t.Run("get urls and users count", func(t *testing.T) {
+			ctx := context.Background()
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			pairs := []domain.URLPair{
+				{ShortURL: "123", OriginalURL: "http://yandex.ru"},
+				{ShortURL: "456", OriginalURL: "http://mail.ru"},
+			}
+			err := urlStore.AddURLs(ctx, pairs, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithTrustedSubnet(newTrustedSubnet(t)))
+			request := newGetInternalStatsRequest("192.168.1.10")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got InternalStats
+			err = json.NewDecoder(response.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, InternalStats{URLs: 2, Users: 1}, got)
+		})
+
+		t.Run("ip is not in trusted subnet", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL, WithTrustedSubnet(newTrustedSubnet(t)))
+			request := newGetInternalStatsRequest("10.0.0.1")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+
+		t.Run("trusted subnet is not set", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newGetInternalStatsRequest("192.168.1.10")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+
+		t.Run("failed to get stats", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.GetURLsCountFunc = func(ctx context.Context) (int, error) {
+				return 0, errors.New("failed to count urls")
+			}
+			sut := New(failingURLStore, baseURL, WithTrustedSubnet(newTrustedSubnet(t)))
+			request := newGetInternalStatsRequest("192.168.1.10")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+		})
+	})
+
+	t.Run("delete user urls", func(t *testing.T) {
+		t.Run("delete urls shortened by user", func(t *testing.T) {
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			userURLs := []domain.URLPair{
+				{
+					OriginalURL: "http://yandex.ru",
+					ShortURL:    "123",
+				},
+				{
+					OriginalURL: "http://mail.ru",
+					ShortURL:    "456",
+				},
+			}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURLs(ctx, userURLs, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			request := newDeleteUserURLsRequest(t, userURLs, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusAccepted, response.Code)
+			pairs, err := urlStore.GetUserURLs(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, len(pairs))
+		})
+
+		t.Run("get delete job", func(t *testing.T) {
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			otherUserID := domain.NewUserID()
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(ctx, domain.URLPair{ShortURL: "123", OriginalURL: testURL}, userID))
+			require.NoError(t, urlStore.AddURL(ctx, domain.URLPair{ShortURL: "456", OriginalURL: "http://mail.ru"},
+				otherUserID))
+			sut := New(urlStore, baseURL)
+			userURLs := []domain.URLPair{{ShortURL: "123"}, {ShortURL: "456"}, {ShortURL: "789"}}
+			deleteResponse := httptest.NewRecorder()
+			sut.ServeHTTP(deleteResponse, newDeleteUserURLsRequest(t, userURLs, userID))
+			require.Equal(t, http.StatusAccepted, deleteResponse.Code)
+			var accepted DeleteJobResponse
+			require.NoError(t, json.NewDecoder(deleteResponse.Body).Decode(&accepted))
+			assert.Equal(t, apiUserJobsPath+"/"+accepted.JobID, deleteResponse.Header().Get(locationHeader))
+			request := newGetDeleteJobRequest(t, accepted.JobID, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got DeleteJob
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			want := DeleteJob{
+				ID:     accepted.JobID,
+				Status: "done",
+				Results: []KeyResult{
+					{ShortURL: "123", Status: "deleted"},
+					{ShortURL: "456", Status: "not_owned"},
+					{ShortURL: "789", Status: "not_found"},
+				},
+			}
+			assert.Equal(t, want, got)
+		})
+
+		t.Run("delete job of other user", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			deleteResponse := httptest.NewRecorder()
+			sut.ServeHTTP(deleteResponse, newDeleteUserURLsRequest(t, nil, domain.NewUserID()))
+			var accepted DeleteJobResponse
+			require.NoError(t, json.NewDecoder(deleteResponse.Body).Decode(&accepted))
+			request := newGetDeleteJobRequest(t, accepted.JobID, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		})
+
+		t.Run("request content is invalid", func(t *testing.T) {
+			userID := domain.NewUserID()
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newDeleteUserURLsInvalidRequest(t, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		})
+
+		t.Run("failed to delete user urls", func(t *testing.T) {
+			userID := domain.NewUserID()
+			userURLs := []domain.URLPair{}
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.DeleteUserURLsFunc = func(ctx context.Context, urls []string,
+				userID domain.UserID) ([]domain.KeyResult, error) {
+				return nil, errors.New("failed to delete urls")
+			}
+			sut := New(failingURLStore, baseURL)
+			request := newDeleteUserURLsRequest(t, userURLs, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+			assertBody(t, "failed to delete user urls", response)
+		})
+	})
+
+	t.Run("restore user urls", func(t *testing.T) {
+		addDeletedURL := func(t *testing.T, userID domain.UserID) domain.URLStore {
+			t.Helper()
+			ctx := context.Background()
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			require.NoError(t, urlStore.AddURL(ctx, domain.URLPair{ShortURL: "123", OriginalURL: testURL}, userID))
+			_, err := urlStore.DeleteUserURLs(ctx, []string{"123"}, userID)
+			require.NoError(t, err)
+			return urlStore
+		}
+
+		t.Run("get deleted user urls", func(t *testing.T) {
+			userID := domain.NewUserID()
+			sut := New(addDeletedURL(t, userID), baseURL)
+			request := newUserRequest(t, http.MethodGet, userURLsPath+"/deleted", nil, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []UserURL
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			assert.Equal(t, []UserURL{{ShortURL: baseURL + "/123", OriginalURL: testURL}}, got)
+		})
+
+		t.Run("user has no deleted urls", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newUserRequest(t, http.MethodGet, userURLsPath+"/deleted", nil, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusNoContent, response.Code)
+		})
+
+		t.Run("restore deleted url", func(t *testing.T) {
+			userID := domain.NewUserID()
+			urlStore := addDeletedURL(t, userID)
+			sut := New(urlStore, baseURL)
+			request := newUserRequest(t, http.MethodPost, userURLsPath+"/restore", []string{"123", "456"}, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []KeyResult
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			want := []KeyResult{{ShortURL: "123", Status: "restored"}, {ShortURL: "456", Status: "not_found"}}
+			assert.Equal(t, want, got)
+			originalURL, err := urlStore.GetOriginalURL(context.Background(), "123")
+			require.NoError(t, err)
+			assert.Equal(t, testURL, originalURL)
+		})
+
+		t.Run("other user attempts restore deleted url", func(t *testing.T) {
+			urlStore := addDeletedURL(t, domain.NewUserID())
+			sut := New(urlStore, baseURL)
+			request := newUserRequest(t, http.MethodPost, userURLsPath+"/restore", []string{"123"}, domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got []KeyResult
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			assert.Equal(t, []KeyResult{{ShortURL: "123", Status: "not_owned"}}, got)
+			_, err := urlStore.GetOriginalURL(context.Background(), "123")
+			assert.ErrorIs(t, err, domain.ErrOriginalURLIsDeleted)
+		})
+	})
+
+	t.Run("export user urls", func(t *testing.T) {
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		addUserURLs := func(t *testing.T, userID domain.UserID) domain.URLStore {
+			t.Helper()
+			ctx := context.Background()
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			pairs := []domain.URLPair{
+				{ShortURL: "123", OriginalURL: "http://yandex.ru", CreatedAt: createdAt, Tags: []string{"promo", "team"}},
+				{ShortURL: "456", OriginalURL: "http://mail.ru", CreatedAt: createdAt.Add(time.Hour),
+					ExpiresAt: expiresAt, Title: "Mail"},
+			}
+			require.NoError(t, urlStore.AddURLs(ctx, pairs, userID))
+			_, err := urlStore.DeleteUserURLs(ctx, []string{"456"}, userID)
+			require.NoError(t, err)
+			return urlStore
+		}
+		want := []ExportedURL{
+			{ShortURL: baseURL + "/123", OriginalURL: "http://yandex.ru", CreatedAt: createdAt,
+				Tags: []string{"promo", "team"}},
+			{ShortURL: baseURL + "/456", OriginalURL: "http://mail.ru", CreatedAt: createdAt.Add(time.Hour),
+				ExpiresAt: &expiresAt, Title: "Mail", IsDeleted: true},
+		}
+
+		t.Run("export as json", func(t *testing.T) {
+			userID := domain.NewUserID()
+			sut := New(addUserURLs(t, userID), baseURL)
+			request := newExportUserURLsRequest(t, "json", userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got []ExportedURL
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			assert.Equal(t, want, got)
+		})
+
+		t.Run("export as ndjson", func(t *testing.T) {
+			userID := domain.NewUserID()
+			sut := New(addUserURLs(t, userID), baseURL)
+			request := newExportUserURLsRequest(t, "ndjson", userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, applicationNDJSON, response)
+			var got []ExportedURL
+			decoder := json.NewDecoder(response.Body)
+			for decoder.More() {
+				var u ExportedURL
+				require.NoError(t, decoder.Decode(&u))
+				got = append(got, u)
+			}
+			assert.Equal(t, want, got)
+		})
+
+		t.Run("export as csv", func(t *testing.T) {
+			userID := domain.NewUserID()
+			sut := New(addUserURLs(t, userID), baseURL)
+			request := newExportUserURLsRequest(t, "csv", userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assertContentType(t, textCSV, response)
+			assert.Equal(t, `attachment; filename="urls.csv"`, response.Header().Get(contentDispositionHeader))
+			wantCSV := "short_url,original_url,tags,expires_at,title,created_at,is_deleted\n" +
+				baseURL + "/123,http://yandex.ru,promo;team,,,2024-03-01T12:00:00Z,false\n" +
+				baseURL + "/456,http://mail.ru,,2030-01-01T00:00:00Z,Mail,2024-03-01T13:00:00Z,true\n"
+			assert.Equal(t, wantCSV, response.Body.String())
+		})
+
+		t.Run("user has no urls", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newExportUserURLsRequest(t, "json", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `[]`, response.Body.String())
+		})
+
+		t.Run("format is invalid", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newExportUserURLsRequest(t, "xml", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		})
+
+		t.Run("failed to read urls", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.WalkUserURLsFunc = func(ctx context.Context, userID domain.UserID,
+				visit domain.URLVisitor) error {
+				return errors.New("failed to read urls")
+			}
+			sut := New(failingURLStore, baseURL)
+			request := newExportUserURLsRequest(t, "csv", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := httptest.NewRequest(http.MethodGet, userURLsPath+"/export", nil)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	})
+
+	t.Run("import user urls", func(t *testing.T) {
+		t.Run("import urls from csv", func(t *testing.T) {
+			ctx := context.Background()
+			userID := domain.NewUserID()
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			err := urlStore.AddURL(ctx, domain.URLPair{ShortURL: "taken", OriginalURL: "http://ya.ru"}, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL)
+			content := "short_url,original_url,tags,expires_at\n" +
+				"abc,http://yandex.ru,promo;team,2030-01-01T00:00:00Z\n" +
+				baseURL + "/def,http://mail.ru\n" +
+				"taken,http://google.com\n" +
+				"ghi\n" +
+				"jkm,http://ok.ru,,tomorrow\n"
+			request := newImportUserURLsRequest(t, content, userID)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got ImportResponse
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			want := ImportResponse{
+				Imported: 2,
+				Failed:   3,
+				Errors: []ImportError{
+					{Line: 4, ShortURL: "taken", Status: "key_taken"},
+					{Line: 5, ShortURL: "ghi", Status: "invalid", Message: errImportRowIsShort.Error()},
+					{Line: 6, ShortURL: "jkm", Status: "invalid", Message: errExpiresAtIsInvalid.Error()},
+				},
+			}
+			assert.Equal(t, want, got)
+			pair, err := urlStore.GetURL(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"promo", "team"}, pair.Tags)
+			assert.True(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Equal(pair.ExpiresAt))
+			originalURL, err := urlStore.GetOriginalURL(ctx, "def")
+			require.NoError(t, err)
+			assert.Equal(t, "http://mail.ru", originalURL)
+		})
+
+		t.Run("file is missing", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newMultipartRequest(t, "other", "abc,http://yandex.ru\n", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assertBody(t, errImportFileIsMissing.Error(), response)
+		})
+
+		t.Run("file is not a valid csv", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newImportUserURLsRequest(t, "abc,\"http://yandex.ru\n", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		})
+
+		t.Run("failed to store urls", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.FindConflictsFunc = func(ctx context.Context,
+				pairs []domain.URLPair) (map[string]domain.KeyStatus, error) {
+				return nil, errors.New("failed to find conflicts")
+			}
+			sut := New(failingURLStore, baseURL)
+			request := newImportUserURLsRequest(t, "abc,http://yandex.ru\n", domain.NewUserID())
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
+		})
+
+		t.Run("user is not authorized", func(t *testing.T) {
+			urlStore, cleanup := u.CreateDependencies()
+			t.Cleanup(cleanup)
+			sut := New(urlStore, baseURL)
+			request := newImportUserURLsRequest(t, "abc,http://yandex.ru\n", domain.NewUserID())
+			request.Header.Del("Cookie")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	})
+
+	t.Run("user quota", func(t *testing.T) {
+		t.Run("quota is exceeded", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{""})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: "EwHXdJfB", OriginalURL: testURL}
+			err := urlStore.AddURL(context.Background(), pair, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithQuotas(service.Quotas{Default: 1}))
+			request := newUserRequest(t, http.MethodPost, apiShortenPath, ShortenRequest{URL: "http://ya.ru"}, userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusBadRequest, response.Code)
-			assertBody(t, urlIsEmptyMessage, response)
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			assertContentType(t, applicationJSON, response)
+			assert.JSONEq(t, `{"error":"url quota exceeded","limit":1,"used":1}`, response.Body.String())
 		})
 
-		t.Run("request json is invalid", func(t *testing.T) {
+		t.Run("batch exceeds quota", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader("{{]}"))
-			request.Header.Set(contentTypeHeader, applicationJSON)
+			userID := domain.NewUserID()
+			sut := New(urlStore, baseURL, WithQuotas(service.Quotas{Default: 1}))
+			batch := newBatch([]string{"http://ya.ru", "http://yandex.ru"})
+			request := newUserRequest(t, http.MethodPost, apiBatchShortenPath, batch, userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusBadRequest, response.Code)
-			assertBody(t, "failed to parse request", response)
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			assert.JSONEq(t, `{"error":"url quota exceeded","limit":1,"used":0}`, response.Body.String())
 		})
 
-		t.Run("client accepts br and gzip encodings", func(t *testing.T) {
+		t.Run("quota of shared service", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{testURL})
-			request := newShortenURLsAPIRequest(t, originalURLs)
-			request.Header.Set(acceptEncodingHeader, "br, "+gzipEncoding)
+			userID := domain.NewUserID()
+			svc := service.New(urlStore, service.WithQuotas(service.Quotas{Default: 1}))
+			sut := New(urlStore, baseURL, WithService(svc), WithQuotas(service.Quotas{Default: 10}))
+			batch := newBatch([]string{"http://ya.ru", "http://yandex.ru"})
+			request := newUserRequest(t, http.MethodPost, apiBatchShortenPath, batch, userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertContentEncoding(t, gzipEncoding, response)
-			assertShortURLs(t, originalURLs, decodeResponse(t, response), urlStore)
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			assert.JSONEq(t, `{"error":"url quota exceeded","limit":1,"used":0}`, response.Body.String())
 		})
 
-		t.Run("client does not accept encoding", func(t *testing.T) {
+		t.Run("skipped import rows are not counted", func(t *testing.T) {
+			ctx := context.Background()
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{testURL})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			userID := domain.NewUserID()
+			err := urlStore.AddURL(ctx, domain.URLPair{ShortURL: "taken", OriginalURL: "http://ya.ru"}, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithQuotas(service.Quotas{Default: 2}))
+			content := "taken,http://google.com\n" +
+				"abc,http://ya.ru\n" +
+				"def\n" +
+				"ghi,http://yandex.ru\n"
+			request := newImportUserURLsRequest(t, content, userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assertContentEncoding(t, "", response)
+			assert.Equal(t, http.StatusOK, response.Code)
+			var got ImportResponse
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			assert.Equal(t, 1, got.Imported)
+			assert.Equal(t, 3, got.Failed)
 		})
 
-		t.Run("client sends encoded content", func(t *testing.T) {
+		t.Run("import exceeds quota", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			originalURLs := newBatch([]string{testURL})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			userID := domain.NewUserID()
+			sut := New(urlStore, baseURL, WithQuotas(service.Quotas{Default: service.ImportChunkSize}))
+			var content strings.Builder
+			for i := 0; i <= service.ImportChunkSize; i++ {
+				fmt.Fprintf(&content, "%s,http://example.com/%d\n", shortener.Shorten(uint32(i)), i)
+			}
+			request := newImportUserURLsRequest(t, content.String(), userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusCreated, response.Code)
-			assertShortURLs(t, originalURLs, response.Body, urlStore)
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			assertContentType(t, applicationJSON, response)
+			var got ImportQuotaExceeded
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&got))
+			want := ImportQuotaExceeded{
+				QuotaExceeded: QuotaExceeded{
+					Error:     service.ErrQuotaExceeded.Error(),
+					UserQuota: UserQuota{Limit: service.ImportChunkSize, Used: service.ImportChunkSize},
+				},
+				ImportResponse: ImportResponse{Imported: service.ImportChunkSize},
+			}
+			assert.Equal(t, want, got)
 		})
 
-		t.Run("failed to store url", func(t *testing.T) {
+		t.Run("user quota overrides default quota", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			failingURLStore := domain.NewURLStoreDelegate(urlStore)
-			failingURLStore.AddURLsFunc = func(ctx context.Context, pairs []domain.URLPair, userID domain.UserID) error {
-				return errors.New("failed to add url")
-			}
-			sut := New(failingURLStore, baseURL)
-			originalURLs := newBatch([]string{testURL})
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			userID := domain.NewUserID()
+			sut := New(urlStore, baseURL, WithQuotas(service.Quotas{
+				Default: 1,
+				Users:   map[domain.UserID]int{userID: 2},
+			}))
+			batch := newBatch([]string{"http://ya.ru", "http://yandex.ru"})
+			request := newUserRequest(t, http.MethodPost, apiBatchShortenPath, batch, userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusInternalServerError, response.Code)
+			assert.Equal(t, http.StatusCreated, response.Code)
 		})
 
-		t.Run("request posts for too many urls", func(t *testing.T) {
+		t.Run("get user quota", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL, WithShortenURLsMaxCount(2))
-			urls := []string{
-				"foo.net",
-				"bar.net",
-				"buz.net",
-			}
-			originalURLs := newBatch(urls)
-
-			request := newShortenURLsAPIRequest(t, originalURLs)
+			userID := domain.NewUserID()
+			pair := domain.URLPair{ShortURL: "EwHXdJfB", OriginalURL: testURL}
+			err := urlStore.AddURL(context.Background(), pair, userID)
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithQuotas(service.Quotas{Default: 10}))
+			request := newUserRequest(t, http.MethodGet, userQuotaPath, nil, userID)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusForbidden, response.Code)
-			assertBody(t, "to many urls", response)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"limit":10,"used":1}`, response.Body.String())
 		})
-	})
 
-	t.Run("get user urls", func(t *testing.T) {
-		t.Run("get urls shortened by user", func(t *testing.T) {
-			userID := domain.NewUserID()
-			userURLs := []domain.URLPair{
-				{
-					OriginalURL: "http://yandex.ru",
-					ShortURL:    "123",
-				},
-				{
-					OriginalURL: "http://mail.ru",
-					ShortURL:    "456",
-				},
-			}
+		t.Run("get unlimited user quota", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			err := urlStore.AddURLs(context.Background(), userURLs, userID)
-			require.NoError(t, err)
 			sut := New(urlStore, baseURL)
-			request := newGetUserURLsRequest(t, userID)
+			request := newUserRequest(t, http.MethodGet, userQuotaPath, nil, domain.NewUserID())
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
 			assert.Equal(t, http.StatusOK, response.Code)
-			assertContentType(t, applicationJSON, response)
-			assertUserURLs(t, userURLs, response.Body)
+			assert.JSONEq(t, `{"used":0}`, response.Body.String())
 		})
 
-		t.Run("no urls shortened by user", func(t *testing.T) {
-			otherUserID := domain.NewUserID()
-			userURLs := []domain.URLPair{
-				{
-					OriginalURL: "http://yandex.ru",
-					ShortURL:    "123",
-				},
-			}
+		t.Run("failed to get user quota", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			err := urlStore.AddURLs(context.Background(), userURLs, otherUserID)
-			require.NoError(t, err)
-			sut := New(urlStore, baseURL)
-			userID := domain.NewUserID()
-			request := newGetUserURLsRequest(t, userID)
+			failingURLStore := domain.NewURLStoreDelegate(urlStore)
+			failingURLStore.GetUserURLsCountFunc = func(ctx context.Context, userID domain.UserID) (int, error) {
+				return 0, errors.New("failed to count")
+			}
+			sut := New(failingURLStore, baseURL)
+			request := newUserRequest(t, http.MethodGet, userQuotaPath, nil, domain.NewUserID())
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
 
-			assert.Equal(t, http.StatusNoContent, response.Code)
-			assertBody(t, "no urls", response)
+			assert.Equal(t, http.StatusInternalServerError, response.Code)
 		})
 
 		t.Run("user is not authorized", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
 			sut := New(urlStore, baseURL)
-			request := httptest.NewRequest(http.MethodGet, userURLsPath, nil)
+			request := httptest.NewRequest(http.MethodGet, userQuotaPath, nil)
 			response := httptest.NewRecorder()
 
 			sut.ServeHTTP(response, request)
@@ -678,70 +3180,102 @@ func (u URLShortenerTest) Test(t *testing.T) {
 		})
 	})
 
-	t.Run("delete user urls", func(t *testing.T) {
-		t.Run("delete urls shortened by user", func(t *testing.T) {
-			ctx := context.Background()
-			userID := domain.NewUserID()
-			userURLs := []domain.URLPair{
-				{
-					OriginalURL: "http://yandex.ru",
-					ShortURL:    "123",
-				},
-				{
-					OriginalURL: "http://mail.ru",
-					ShortURL:    "456",
-				},
-			}
+	t.Run("rate limiting", func(t *testing.T) {
+		t.Run("too many shorten requests", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			err := urlStore.AddURLs(ctx, userURLs, userID)
-			require.NoError(t, err)
-			sut := New(urlStore, baseURL)
-			request := newDeleteUserURLsRequest(t, userURLs, userID)
+			sut := New(urlStore, baseURL, WithRateLimits(RateLimits{
+				Shorten: middleware.Rate{Requests: 1, Period: time.Minute},
+			}))
+
 			response := httptest.NewRecorder()
+			sut.ServeHTTP(response, newShortenAPIRequest(t, "http://yandex.ru"))
 
-			sut.ServeHTTP(response, request)
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.Equal(t, "1", response.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, "0", response.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, "60", response.Header().Get("RateLimit-Reset"))
 
-			assert.Equal(t, http.StatusAccepted, response.Code)
-			pairs, err := urlStore.GetUserURLs(ctx, userID)
-			require.NoError(t, err)
-			assert.Equal(t, 0, len(pairs))
+			response = httptest.NewRecorder()
+			sut.ServeHTTP(response, newShortenRequest("http://ya.ru"))
+
+			assert.Equal(t, http.StatusTooManyRequests, response.Code)
+			assert.Equal(t, "60", response.Header().Get("Retry-After"))
 		})
 
-		t.Run("request content is invalid", func(t *testing.T) {
-			userID := domain.NewUserID()
+		t.Run("too many redirect requests", func(t *testing.T) {
+			const shortURL = "EwHXdJfB"
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			sut := New(urlStore, baseURL)
-			request := newDeleteUserURLsInvalidRequest(t, userID)
+			pair := domain.URLPair{ShortURL: shortURL, OriginalURL: testURL}
+			err := urlStore.AddURL(context.Background(), pair, domain.NewUserID())
+			require.NoError(t, err)
+			sut := New(urlStore, baseURL, WithRateLimits(RateLimits{
+				Redirect: middleware.Rate{Requests: 1, Period: time.Minute},
+			}))
+
 			response := httptest.NewRecorder()
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+			assert.Equal(t, http.StatusTemporaryRedirect, response.Code)
 
-			sut.ServeHTTP(response, request)
+			response = httptest.NewRecorder()
+			sut.ServeHTTP(response, newGetRequest(shortURL))
+			assert.Equal(t, http.StatusTooManyRequests, response.Code)
 
-			assert.Equal(t, http.StatusBadRequest, response.Code)
+			response = httptest.NewRecorder()
+			sut.ServeHTTP(response, newShortenAPIRequest(t, "http://yandex.ru"))
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.Empty(t, response.Header().Get("RateLimit-Limit"))
 		})
 
-		t.Run("failed to delete user urls", func(t *testing.T) {
-			userID := domain.NewUserID()
-			userURLs := []domain.URLPair{}
+		t.Run("too many delete requests", func(t *testing.T) {
 			urlStore, cleanup := u.CreateDependencies()
 			t.Cleanup(cleanup)
-			failingURLStore := domain.NewURLStoreDelegate(urlStore)
-			failingURLStore.DeleteUserURLsFunc = func(ctx context.Context, urls []string, userID domain.UserID) error {
-				return errors.New("failed to delete urls")
-			}
-			sut := New(failingURLStore, baseURL)
-			request := newDeleteUserURLsRequest(t, userURLs, userID)
+			userID := domain.NewUserID()
+			sut := New(urlStore, baseURL, WithRateLimits(RateLimits{
+				Delete: middleware.Rate{Requests: 1, Period: time.Minute},
+			}))
+
 			response := httptest.NewRecorder()
+			sut.ServeHTTP(response, newDeleteUserURLsRequest(t, nil, userID))
+			assert.NotEqual(t, http.StatusTooManyRequests, response.Code)
 
+			response = httptest.NewRecorder()
+			request := newDeleteUserURLsRequest(t, nil, userID)
+			request.Header.Set("X-Real-IP", "10.0.0.1")
 			sut.ServeHTTP(response, request)
-
-			assert.Equal(t, http.StatusInternalServerError, response.Code)
-			assertBody(t, "failed to delete user urls", response)
+			assert.Equal(t, http.StatusTooManyRequests, response.Code)
 		})
 	})
 }
 
+func newImportUserURLsRequest(t *testing.T, content string, userID domain.UserID) *http.Request {
+	t.Helper()
+	return newMultipartRequest(t, importFileField, content, userID)
+}
+
+func newMultipartRequest(t *testing.T, field, content string, userID domain.UserID) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "urls.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	r := newUserRequest(t, http.MethodPost, userURLsPath+"/import", nil, userID)
+	r.Body = io.NopCloser(&body)
+	r.ContentLength = int64(body.Len())
+	r.Header.Set(contentTypeHeader, writer.FormDataContentType())
+	return r
+}
+
+func newExportUserURLsRequest(t *testing.T, format string, userID domain.UserID) *http.Request {
+	t.Helper()
+	return newUserRequest(t, http.MethodGet, userURLsPath+"/export?format="+format, nil, userID)
+}
+
 func assertUserURLs(t *testing.T, want []domain.URLPair, r io.Reader) {
 	t.Helper()
 	var got []UserURL
@@ -753,6 +3287,9 @@ func assertUserURLs(t *testing.T, want []domain.URLPair, r io.Reader) {
 	for i := 0; i < len(got); i++ {
 		urls[i].OriginalURL = want[i].OriginalURL
 		urls[i].ShortURL = baseURL + "/" + want[i].ShortURL
+		urls[i].Title = want[i].Title
+		urls[i].Tags = want[i].Tags
+		urls[i].Status = urlStatusActive
 	}
 	assert.ElementsMatch(t, urls, got)
 }
@@ -808,6 +3345,17 @@ func newGetRequest(shortURL string) *http.Request {
 	return r
 }
 
+func newUnlockRequest(shortURL, password string) *http.Request {
+	form := url.Values{passwordFormField: {password}}
+	r := httptest.NewRequest(http.MethodPost, "/"+shortURL, strings.NewReader(form.Encode()))
+	r.Header.Set(contentTypeHeader, applicationForm)
+	return r
+}
+
+func newGetQRCodeRequest(shortURL string, query url.Values) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/"+shortURL+"/qr?"+query.Encode(), nil)
+}
+
 func newShortenRequest(url string) *http.Request {
 	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url))
 	r.Header.Set(contentTypeHeader, textPlain+"; charset=utf-8")
@@ -844,6 +3392,22 @@ func newShortenAPIRequest(t *testing.T, url string) *http.Request {
 	return request
 }
 
+func newShortenAPIAliasRequest(t *testing.T, url, alias string) *http.Request {
+	t.Helper()
+	return newShortenAPIRequestFrom(t, ShortenRequest{URL: url, Alias: alias})
+}
+
+func newShortenAPIRequestFrom(t *testing.T, r ShortenRequest) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(&r)
+
+	require.NoError(t, err, "unable to marshal %q, %v", r, err)
+
+	request := httptest.NewRequest(http.MethodPost, apiShortenPath, strings.NewReader(string(body)))
+	request.Header.Set(contentTypeHeader, applicationJSON)
+	return request
+}
+
 func newEncodedShortenAPIRequest(t *testing.T, url string) *http.Request {
 	t.Helper()
 	r := ShortenRequest{URL: url}
@@ -875,7 +3439,42 @@ func newPutRequest(url string) *http.Request {
 func newGetUserURLsRequest(t *testing.T, userID domain.UserID) *http.Request {
 	t.Helper()
 	r := httptest.NewRequest(http.MethodGet, userURLsPath, nil)
-	a := auth.New(secretKey, tokenExp)
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
+
+	cookie, err := a.CreateCookie(userID)
+	require.NoError(t, err)
+
+	r.AddCookie(cookie)
+	return r
+}
+
+func newGetUserURLsPageRequest(t *testing.T, userID domain.UserID, query url.Values) *http.Request {
+	t.Helper()
+	r := newGetUserURLsRequest(t, userID)
+	r.URL.RawQuery = query.Encode()
+	return r
+}
+
+func newGetClickStatsRequest(t *testing.T, shortURL string, userID domain.UserID) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, userURLsPath+"/"+shortURL+"/stats", nil)
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
+
+	cookie, err := a.CreateCookie(userID)
+	require.NoError(t, err)
+
+	r.AddCookie(cookie)
+	return r
+}
+
+func newUpdateUserURLRequest(t *testing.T, shortURL, originalURL string, userID domain.UserID) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(UpdateURLRequest{URL: originalURL})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPatch, userURLsPath+"/"+shortURL, bytes.NewReader(body))
+	r.Header.Set(contentTypeHeader, applicationJSON)
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
 
 	cookie, err := a.CreateCookie(userID)
 	require.NoError(t, err)
@@ -884,6 +3483,30 @@ func newGetUserURLsRequest(t *testing.T, userID domain.UserID) *http.Request {
 	return r
 }
 
+func newUpdateTagsRequest(t *testing.T, shortURL string, tags []string, userID domain.UserID) *http.Request {
+	t.Helper()
+	return newUserRequest(t, http.MethodPut, userURLsPath+"/"+shortURL+"/tags", URLTags{Tags: tags}, userID)
+}
+
+func newUpdateRoutingRulesRequest(t *testing.T, shortURL string, rules []RoutingRule,
+	userID domain.UserID) *http.Request {
+	t.Helper()
+	return newUserRequest(t, http.MethodPut, userURLsPath+"/"+shortURL+"/rules", URLRules{Rules: rules}, userID)
+}
+
+func newTrustedSubnet(t *testing.T) *net.IPNet {
+	t.Helper()
+	_, subnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+	return subnet
+}
+
+func newGetInternalStatsRequest(realIP string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	r.Header.Set("X-Real-IP", realIP)
+	return r
+}
+
 func newDeleteUserURLsRequest(t *testing.T, userURLs []domain.URLPair, userID domain.UserID) *http.Request {
 	t.Helper()
 
@@ -897,8 +3520,42 @@ func newDeleteUserURLsRequest(t *testing.T, userURLs []domain.URLPair, userID do
 	buf := bytes.NewBuffer(body)
 	r := httptest.NewRequest(http.MethodDelete, userURLsPath, buf)
 	r.Header.Set(contentTypeHeader, applicationJSON)
-	a := auth.New(secretKey, tokenExp)
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
+
+	cookie, err := a.CreateCookie(userID)
+	require.NoError(t, err)
+
+	r.AddCookie(cookie)
+	return r
+}
+
+func newGetDeleteJobRequest(t *testing.T, jobID string, userID domain.UserID) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, apiUserJobsPath+"/"+jobID, nil)
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
+
+	cookie, err := a.CreateCookie(userID)
+	require.NoError(t, err)
+
+	r.AddCookie(cookie)
+	return r
+}
+
+// newUserRequest создает запрос пользователя с телом в формате JSON, если оно задано.
+func newUserRequest(t *testing.T, method, target string, body any, userID domain.UserID) *http.Request {
+	t.Helper()
+	var r *http.Request
+
+	if body == nil {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		content, err := json.Marshal(body)
+		require.NoError(t, err)
+		r = httptest.NewRequest(method, target, bytes.NewReader(content))
+		r.Header.Set(contentTypeHeader, applicationJSON)
+	}
 
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
 	cookie, err := a.CreateCookie(userID)
 	require.NoError(t, err)
 
@@ -912,7 +3569,7 @@ func newDeleteUserURLsInvalidRequest(t *testing.T, userID domain.UserID) *http.R
 	buf := bytes.NewBufferString("[{ Invalid: true ]}")
 	r := httptest.NewRequest(http.MethodDelete, userURLsPath, buf)
 	r.Header.Set(contentTypeHeader, applicationJSON)
-	a := auth.New(secretKey, tokenExp)
+	a := auth.New(auth.DefaultSecret, auth.DefaultTokenExp)
 
 	cookie, err := a.CreateCookie(userID)
 	require.NoError(t, err)
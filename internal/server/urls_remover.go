@@ -7,9 +7,11 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/service"
 )
 
 type deletingURLs struct {
+	done      service.DeleteCallback
 	shortURLs []string
 	userID    domain.UserID
 }
@@ -33,10 +35,13 @@ func NewURLRemover(ctx context.Context, doneCh <-chan struct{}, store domain.URL
 			case <-r.doneCh:
 				return
 			case val := <-r.deleteCh:
-				err := store.DeleteUserURLs(ctx, val.shortURLs, val.userID)
+				results, err := store.DeleteUserURLs(ctx, val.shortURLs, val.userID)
 				if err != nil {
 					log.Error(err.Error())
 				}
+				if val.done != nil {
+					val.done(results, err)
+				}
 			}
 		}
 	}()
@@ -44,8 +49,9 @@ func NewURLRemover(ctx context.Context, doneCh <-chan struct{}, store domain.URL
 	return r
 }
 
-// DeleteURLs добавляет переданные сокращенные URL на удаление.
-func (r *URLRemover) DeleteURLs(shortURLs []string, userID domain.UserID) error {
+// DeleteURLs добавляет переданные сокращенные URL на удаление. После удаления вызывается done,
+// если он задан.
+func (r *URLRemover) DeleteURLs(shortURLs []string, userID domain.UserID, done service.DeleteCallback) error {
 	select {
 	case <-r.doneCh:
 		return errors.New("channel is closed")
@@ -56,6 +62,7 @@ func (r *URLRemover) DeleteURLs(shortURLs []string, userID domain.UserID) error
 		urls := deletingURLs{
 			shortURLs: shortURLs,
 			userID:    userID,
+			done:      done,
 		}
 		r.deleteCh <- urls
 	}()
@@ -38,7 +38,7 @@ func TestDeleteURLs(t *testing.T) {
 			urls[0].ShortURL,
 			urls[1].ShortURL,
 		}
-		err = sut.DeleteURLs(shortURLs, userID)
+		err = sut.DeleteURLs(shortURLs, userID, nil)
 		require.NoError(t, err)
 
 		time.Sleep(10 * time.Millisecond)
@@ -48,6 +48,34 @@ func TestDeleteURLs(t *testing.T) {
 		assert.Empty(t, userURLs)
 	})
 
+	t.Run("report delete results", func(t *testing.T) {
+		ctx := context.Background()
+		store := inmemory.New()
+		userID := domain.NewUserID()
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+		sut := NewURLRemover(ctx, doneCh, store, zap.NewNop())
+		pair := domain.URLPair{OriginalURL: "http://yandex.ru", ShortURL: "123"}
+		require.NoError(t, store.AddURL(ctx, pair, userID))
+		resultsCh := make(chan []domain.KeyResult, 1)
+
+		err := sut.DeleteURLs([]string{"123", "abc"}, userID, func(results []domain.KeyResult, err error) {
+			assert.NoError(t, err)
+			resultsCh <- results
+		})
+		require.NoError(t, err)
+
+		select {
+		case results := <-resultsCh:
+			assert.Equal(t, []domain.KeyResult{
+				{ShortURL: "123", Status: domain.KeyDeleted},
+				{ShortURL: "abc", Status: domain.KeyNotFound},
+			}, results)
+		case <-time.After(time.Second):
+			t.Fatal("delete results are not reported")
+		}
+	})
+
 	t.Run("error on delete urls after closing", func(t *testing.T) {
 		ctx := context.Background()
 		store := inmemory.New()
@@ -56,7 +84,7 @@ func TestDeleteURLs(t *testing.T) {
 		sut := NewURLRemover(ctx, doneCh, store, zap.NewNop())
 		close(doneCh)
 
-		err := sut.DeleteURLs([]string{"abc"}, userID)
+		err := sut.DeleteURLs([]string{"abc"}, userID, nil)
 		assert.NotNil(t, err)
 	})
 }
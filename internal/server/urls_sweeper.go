@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// URLSweeper периодически удаляет сокращенные URL, срок действия которых истек.
+type URLSweeper struct {
+	store  domain.URLStore
+	log    *zap.Logger
+	doneCh <-chan struct{}
+}
+
+// NewURLSweeper создает URLSweeper, который выполняет удаление с указанным интервалом
+// до закрытия канала doneCh.
+func NewURLSweeper(ctx context.Context, doneCh <-chan struct{}, store domain.URLStore,
+	interval time.Duration, log *zap.Logger) *URLSweeper {
+	s := &URLSweeper{
+		store:  store,
+		log:    log,
+		doneCh: doneCh,
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.doneCh:
+				return
+			case now := <-ticker.C:
+				s.Sweep(ctx, now)
+			}
+		}
+	}()
+
+	return s
+}
+
+// Sweep удаляет сокращенные URL, срок действия которых истек к указанному моменту.
+func (s *URLSweeper) Sweep(ctx context.Context, now time.Time) {
+	err := s.store.DeleteExpiredURLs(ctx, now)
+	if err != nil {
+		s.log.Error(err.Error())
+	}
+}
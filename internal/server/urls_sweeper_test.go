@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+)
+
+func TestURLSweeper(t *testing.T) {
+	t.Run("delete expired urls periodically", func(t *testing.T) {
+		ctx := context.Background()
+		store := inmemory.New()
+		pair := domain.URLPair{
+			OriginalURL: "http://yandex.ru",
+			ShortURL:    "123",
+			ExpiresAt:   time.Now().Add(-time.Minute),
+		}
+		userID := domain.NewUserID()
+		err := store.AddURL(ctx, pair, userID)
+		require.NoError(t, err)
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+
+		_ = NewURLSweeper(ctx, doneCh, store, time.Millisecond, zap.NewNop())
+
+		assert.Eventually(t, func() bool {
+			var isSwept bool
+			err := store.WalkUserURLs(ctx, userID, func(_ domain.URLPair, isDeleted bool) error {
+				isSwept = isDeleted
+				return nil
+			})
+			return err == nil && isSwept
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("log store error", func(t *testing.T) {
+		ctx := context.Background()
+		store := domain.NewURLStoreDelegate(inmemory.New())
+		store.DeleteExpiredURLsFunc = func(ctx context.Context, now time.Time) error {
+			return errors.New("failed to delete expired urls")
+		}
+		core, logs := observer.New(zap.ErrorLevel)
+		doneCh := make(chan struct{})
+		close(doneCh)
+		sut := NewURLSweeper(ctx, doneCh, store, time.Hour, zap.New(core))
+
+		sut.Sweep(ctx, time.Now())
+
+		assert.Equal(t, 1, logs.Len())
+	})
+}
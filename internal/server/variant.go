@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nestjam/yap-shortener/internal/service"
+)
+
+const (
+	variantCookiePrefix = "variant_"          // префикс имени cookie с вариантом, закрепленным за клиентом
+	variantCookieMaxAge = 30 * 24 * time.Hour // время хранения закрепленного варианта
+)
+
+// stickyVariant возвращает имя варианта исходного URL, закрепленного за клиентом при предыдущем переходе
+// по сокращенному URL. Если вариант не закреплен, возвращается пустая строка.
+func stickyVariant(r *http.Request, key string) string {
+	cookie, err := r.Cookie(variantCookiePrefix + key)
+
+	if err != nil {
+		return ""
+	}
+
+	variant, err := url.QueryUnescape(cookie.Value)
+
+	if err != nil {
+		return ""
+	}
+
+	return variant
+}
+
+// setStickyVariant закрепляет за клиентом выбранный вариант исходного URL, если сокращенный URL
+// использует режим domain.RotationSticky.
+func setStickyVariant(w http.ResponseWriter, key string, target service.Target) {
+	if !target.Sticky {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookiePrefix + key,
+		Value:    url.QueryEscape(target.Variant),
+		Path:     "/" + key,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
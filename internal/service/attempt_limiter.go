@@ -0,0 +1,84 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter ограничивает количество неудачных попыток ввода пароля для сокращенного URL.
+// Попытки считаются в фиксированном окне, которое начинается с первой попытки. Попытка резервируется
+// до проверки пароля и снимается только при успешной проверке, поэтому параллельные запросы
+// не могут превысить лимит.
+type AttemptLimiter struct {
+	attempts    map[string]attempts
+	lastSweep   time.Time
+	window      time.Duration
+	maxAttempts int
+	mu          sync.Mutex
+}
+
+type attempts struct {
+	windowStart time.Time
+	count       int
+}
+
+// NewAttemptLimiter создает ограничитель, который допускает не более maxAttempts неудачных попыток
+// за интервал window.
+func NewAttemptLimiter(maxAttempts int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{
+		attempts:    make(map[string]attempts),
+		window:      window,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Reserve учитывает попытку для ключа и возвращает true, если лимит попыток еще не исчерпан.
+// Если лимит исчерпан, попытка не учитывается. Зарезервированная попытка считается неудачной,
+// пока счетчик не сброшен методом Reset.
+func (l *AttemptLimiter) Reserve(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	a, ok := l.attempts[key]
+
+	if !ok || l.isExpired(a, now) {
+		a = attempts{windowStart: now}
+	}
+
+	if a.count >= l.maxAttempts {
+		return false
+	}
+
+	a.count++
+	l.attempts[key] = a
+	return true
+}
+
+// Reset сбрасывает счетчик попыток для ключа.
+func (l *AttemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+func (l *AttemptLimiter) isExpired(a attempts, now time.Time) bool {
+	return now.Sub(a.windowStart) >= l.window
+}
+
+// sweep удаляет счетчики с истекшим окном не чаще одного раза за окно.
+func (l *AttemptLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, a := range l.attempts {
+		if l.isExpired(a, now) {
+			delete(l.attempts, key)
+		}
+	}
+}
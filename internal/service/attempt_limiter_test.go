@@ -0,0 +1,72 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	const key = "EwHXdJfB"
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("reserve until limit is reached", func(t *testing.T) {
+		sut := NewAttemptLimiter(2, time.Minute)
+
+		assert.True(t, sut.Reserve(key, now))
+		assert.True(t, sut.Reserve(key, now))
+		assert.False(t, sut.Reserve(key, now))
+		assert.True(t, sut.Reserve("other", now))
+	})
+
+	t.Run("reserve after window elapsed", func(t *testing.T) {
+		sut := NewAttemptLimiter(1, time.Minute)
+		assert.True(t, sut.Reserve(key, now))
+
+		assert.False(t, sut.Reserve(key, now.Add(time.Second)))
+		assert.True(t, sut.Reserve(key, now.Add(time.Minute)))
+	})
+
+	t.Run("reserve after reset", func(t *testing.T) {
+		sut := NewAttemptLimiter(1, time.Minute)
+		assert.True(t, sut.Reserve(key, now))
+
+		sut.Reset(key)
+
+		assert.True(t, sut.Reserve(key, now))
+	})
+
+	t.Run("concurrent reservations do not exceed limit", func(t *testing.T) {
+		const maxAttempts = 5
+		sut := NewAttemptLimiter(maxAttempts, time.Minute)
+		var reserved atomic.Int32
+		var wg sync.WaitGroup
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if sut.Reserve(key, now) {
+					reserved.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(maxAttempts), reserved.Load())
+	})
+
+	t.Run("sweep expired attempts", func(t *testing.T) {
+		sut := NewAttemptLimiter(1, time.Minute)
+		sut.Reserve("a", now)
+		sut.Reserve("b", now.Add(30*time.Second))
+
+		sut.Reserve("c", now.Add(time.Minute))
+
+		assert.Len(t, sut.attempts, 2)
+		assert.NotContains(t, sut.attempts, "a")
+	})
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// JobStatus определяет состояние задачи удаления.
+type JobStatus string
+
+// Состояния задачи удаления.
+const (
+	JobPending JobStatus = "pending" // удаление еще не выполнено
+	JobDone    JobStatus = "done"    // удаление выполнено
+	JobFailed  JobStatus = "failed"  // удаление завершилось ошибкой
+)
+
+// ErrJobNotFound возвращается, если задача не найдена или создана другим пользователем.
+var ErrJobNotFound = errors.New("job not found")
+
+// defaultJobRetention определяет время хранения завершенной задачи удаления по умолчанию.
+const defaultJobRetention = time.Hour
+
+// DeleteJob описывает задачу удаления сокращенных URL пользователя.
+type DeleteJob struct {
+	CreatedAt  time.Time          // время создания задачи
+	FinishedAt time.Time          // время завершения задачи; нулевое значение - задача не завершена
+	ID         string             // идентификатор задачи
+	Status     JobStatus          // состояние задачи
+	Results    []domain.KeyResult // результаты удаления каждого URL; заполняются после выполнения
+	UserID     domain.UserID      // пользователь, создавший задачу
+}
+
+// DeleteJobs хранит задачи удаления в памяти процесса. Завершенные задачи удаляются по истечении времени
+// хранения с момента завершения, а незавершенные - с момента создания, так как их выполнение было прервано.
+type DeleteJobs struct {
+	jobs      map[string]DeleteJob
+	lastSweep time.Time
+	retention time.Duration
+	mu        sync.Mutex
+}
+
+// NewDeleteJobs создает хранилище задач удаления с указанным временем хранения завершенных задач.
+func NewDeleteJobs(retention time.Duration) *DeleteJobs {
+	return &DeleteJobs{
+		jobs:      make(map[string]DeleteJob),
+		retention: retention,
+	}
+}
+
+// Start создает задачу удаления в состоянии pending.
+func (j *DeleteJobs) Start(userID domain.UserID, now time.Time) DeleteJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.sweep(now)
+
+	job := DeleteJob{
+		ID:        uuid.NewString(),
+		Status:    JobPending,
+		UserID:    userID,
+		CreatedAt: now,
+	}
+	j.jobs[job.ID] = job
+	return job
+}
+
+// Finish завершает задачу удаления с результатами или ошибкой.
+func (j *DeleteJobs) Finish(id string, results []domain.KeyResult, err error, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.sweep(now)
+	job, ok := j.jobs[id]
+
+	if !ok {
+		return
+	}
+
+	job.Status = JobDone
+	job.Results = results
+	if err != nil {
+		job.Status = JobFailed
+		job.Results = nil
+	}
+
+	job.FinishedAt = now
+	j.jobs[id] = job
+}
+
+// Get возвращает задачу удаления, созданную указанным пользователем. Задача, время хранения которой истекло
+// к моменту now, не возвращается.
+func (j *DeleteJobs) Get(id string, userID domain.UserID, now time.Time) (DeleteJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.sweep(now)
+	job, ok := j.jobs[id]
+
+	if !ok || job.UserID != userID || j.isExpired(job, now) {
+		return DeleteJob{}, ErrJobNotFound
+	}
+
+	return job, nil
+}
+
+// sweep удаляет задачи с истекшим временем хранения не чаще одного раза за время хранения.
+func (j *DeleteJobs) sweep(now time.Time) {
+	if now.Sub(j.lastSweep) < j.retention {
+		return
+	}
+
+	j.lastSweep = now
+
+	for id, job := range j.jobs {
+		if j.isExpired(job, now) {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+func (j *DeleteJobs) isExpired(job DeleteJob, now time.Time) bool {
+	if job.FinishedAt.IsZero() {
+		return now.Sub(job.CreatedAt) >= j.retention
+	}
+
+	return now.Sub(job.FinishedAt) >= j.retention
+}
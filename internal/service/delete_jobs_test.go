@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+func TestDeleteJobs(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("job is failed", func(t *testing.T) {
+		sut := NewDeleteJobs(time.Hour)
+		userID := domain.NewUserID()
+		job := sut.Start(userID, now)
+
+		sut.Finish(job.ID, nil, errors.New("failed"), now)
+
+		got, err := sut.Get(job.ID, userID, now)
+		require.NoError(t, err)
+		assert.Equal(t, JobFailed, got.Status)
+		assert.Equal(t, now, got.FinishedAt)
+	})
+
+	t.Run("finished job is removed after retention", func(t *testing.T) {
+		sut := NewDeleteJobs(time.Hour)
+		userID := domain.NewUserID()
+		finished := sut.Start(userID, now)
+		pending := sut.Start(userID, now.Add(30*time.Minute))
+		sut.Finish(finished.ID, nil, nil, now)
+
+		sut.Start(userID, now.Add(time.Hour))
+
+		assert.Len(t, sut.jobs, 2)
+		assert.NotContains(t, sut.jobs, finished.ID)
+		_, err := sut.Get(pending.ID, userID, now.Add(time.Hour))
+		assert.NoError(t, err)
+	})
+
+	t.Run("unfinished job is removed after retention", func(t *testing.T) {
+		sut := NewDeleteJobs(time.Hour)
+		userID := domain.NewUserID()
+		pending := sut.Start(userID, now)
+
+		sut.Start(userID, now.Add(time.Hour))
+
+		assert.NotContains(t, sut.jobs, pending.ID)
+	})
+
+	t.Run("expired job is not returned between sweeps", func(t *testing.T) {
+		sut := NewDeleteJobs(time.Hour)
+		userID := domain.NewUserID()
+		job := sut.Start(userID, now)
+		sut.Finish(job.ID, nil, nil, now.Add(30*time.Minute))
+		sut.Start(userID, now.Add(time.Hour))
+
+		_, err := sut.Get(job.ID, userID, now.Add(90*time.Minute))
+
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// forwardURL добавляет к адресу перехода суффикс пути path и параметры строки запроса query перехода по
+// сокращенному URL. Суффикс пути передается в экранированном виде и не может содержать сегменты "." и "..";
+// такой суффикс считается ненайденным URL. Параметры объединяются по правилам mergeQuery.
+func forwardURL(target, path, query string) (string, error) {
+	if path == "" && query == "" {
+		return target, nil
+	}
+
+	u, err := url.Parse(target)
+
+	if err != nil {
+		return "", fmt.Errorf("forward url: %w", err)
+	}
+
+	if path != "" {
+		for _, segment := range strings.Split(path, "/") {
+			if s, err := url.PathUnescape(segment); err != nil || s == "." || s == ".." {
+				return "", domain.ErrOriginalURLNotFound
+			}
+		}
+
+		u = u.JoinPath(path)
+	}
+
+	u.RawQuery = mergeQuery(u.RawQuery, query)
+	return u.String(), nil
+}
+
+// mergeQuery добавляет к строке запроса исходного URL base параметры строки запроса перехода extra.
+// Параметры исходного URL имеют приоритет: параметр extra, имя которого есть в base, отбрасывается
+// со всеми значениями. Остальные параметры extra добавляются после параметров base в порядке следования
+// и исходной кодировке; повторяющиеся значения одного параметра сохраняются. Параметры, которые
+// не удается разобрать, отбрасываются.
+func mergeQuery(base, extra string) string {
+	names := make(map[string]struct{})
+	parts := make([]string, 0)
+
+	for _, part := range splitQuery(base) {
+		if name, ok := queryParamName(part); ok {
+			names[name] = struct{}{}
+		}
+
+		parts = append(parts, part)
+	}
+
+	for _, part := range splitQuery(extra) {
+		name, ok := queryParamName(part)
+
+		if !ok {
+			continue
+		}
+
+		if _, exists := names[name]; exists {
+			continue
+		}
+
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func splitQuery(query string) []string {
+	var parts []string
+	for _, part := range strings.Split(query, "&") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
+func queryParamName(part string) (string, bool) {
+	if strings.Contains(part, ";") {
+		return "", false
+	}
+
+	rawName, _, _ := strings.Cut(part, "=")
+	name, err := url.QueryUnescape(rawName)
+
+	if err != nil || name == "" {
+		return "", false
+	}
+
+	return name, true
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+)
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		extra string
+		want  string
+	}{
+		{
+			name:  "no incoming params",
+			base:  "a=1",
+			extra: "",
+			want:  "a=1",
+		},
+		{
+			name:  "params are appended",
+			base:  "a=1",
+			extra: "utm_source=x&utm_medium=email",
+			want:  "a=1&utm_source=x&utm_medium=email",
+		},
+		{
+			name:  "original url params take precedence",
+			base:  "utm_source=site&a=1",
+			extra: "utm_source=x&utm_source=y&b=2",
+			want:  "utm_source=site&a=1&b=2",
+		},
+		{
+			name:  "repeated incoming params are kept",
+			base:  "",
+			extra: "tag=a&tag=b",
+			want:  "tag=a&tag=b",
+		},
+		{
+			name:  "names are compared unescaped",
+			base:  "a%20b=1",
+			extra: "a+b=2&c%5B%5D=3",
+			want:  "a%20b=1&c%5B%5D=3",
+		},
+		{
+			name:  "malformed params are dropped",
+			base:  "",
+			extra: "a=1;b=2&%zz=3&=4&&c",
+			want:  "c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeQuery(tt.base, tt.extra)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestForwardURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		path    string
+		query   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "nothing to forward",
+			target: "https://example.com/docs?v=1#top",
+			want:   "https://example.com/docs?v=1#top",
+		},
+		{
+			name:   "path is appended",
+			target: "https://example.com/docs/",
+			path:   "guide/install",
+			want:   "https://example.com/docs/guide/install",
+		},
+		{
+			name:   "path is appended to root",
+			target: "https://example.com",
+			path:   "a%20b/",
+			want:   "https://example.com/a%20b/",
+		},
+		{
+			name:   "path and query are forwarded",
+			target: "https://example.com/docs?v=1#top",
+			path:   "faq",
+			query:  "v=2&lang=en",
+			want:   "https://example.com/docs/faq?v=1&lang=en#top",
+		},
+		{
+			name:    "dot segments are rejected",
+			target:  "https://example.com/docs",
+			path:    "a/%2E%2E/admin",
+			wantErr: domain.ErrOriginalURLNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := forwardURL(tt.target, tt.path, tt.query)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandForwarded(t *testing.T) {
+	t.Run("path is forwarded", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{URL: "https://example.com/docs", ForwardPath: true}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{Path: "faq", Query: "utm_source=x"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/docs/faq?utm_source=x", got.URL)
+	})
+
+	t.Run("path is not forwarded", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		req := ShortenRequest{URL: "https://example.com/docs", MaxClicks: 1}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.ExpandTarget(ctx, shortURL, ExpandRequest{Path: "faq"})
+
+		assert.ErrorIs(t, err, domain.ErrOriginalURLNotFound)
+		pair, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, 1, pair.ClicksLeft)
+	})
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// ErrQuotaExceeded возвращается, если сокращение URL превысит квоту пользователя.
+var ErrQuotaExceeded = errors.New("url quota exceeded")
+
+// ErrQuotasAreInvalid возвращается, если квоты пользователей заданы в неверном формате.
+var ErrQuotasAreInvalid = errors.New("user quotas must be in format <user id>=<limit>[,<user id>=<limit>...]")
+
+// Quota содержит сведения об использовании квоты пользователя на количество действующих сокращенных URL:
+// неудаленных, не истекших и не исчерпавших переходы.
+type Quota struct {
+	Limit int // максимальное количество URL; 0 - без ограничения
+	Used  int // количество URL, сокращенных пользователем
+}
+
+// IsUnlimited возвращает true, если количество URL пользователя не ограничено.
+func (q Quota) IsUnlimited() bool {
+	return q.Limit <= 0
+}
+
+// QuotaExceededError определяет ошибку, когда сокращение URL превысит квоту пользователя.
+type QuotaExceededError struct {
+	quota Quota
+}
+
+// Error возвращает текст ошибки с использованием квоты.
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%v: %d of %d urls used", ErrQuotaExceeded, e.quota.Used, e.quota.Limit)
+}
+
+// Unwrap возвращает ErrQuotaExceeded.
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Quota возвращает использование квоты пользователя.
+func (e *QuotaExceededError) Quota() Quota {
+	return e.quota
+}
+
+// Quotas задает квоты пользователей на количество действующих сокращенных URL.
+type Quotas struct {
+	Users   map[domain.UserID]int // квоты отдельных пользователей; 0 - без ограничения
+	Default int                   // квота остальных пользователей; 0 - без ограничения
+}
+
+// Limit возвращает квоту пользователя; 0 - без ограничения.
+func (q Quotas) Limit(userID domain.UserID) int {
+	if limit, ok := q.Users[userID]; ok {
+		return limit
+	}
+
+	return q.Default
+}
+
+// userLocks упорядочивает проверку квоты и добавление URL одного пользователя, чтобы одновременные запросы
+// не превысили квоту. Мьютекс пользователя удаляется, когда его не ожидает ни один запрос.
+type userLocks struct {
+	locks map[domain.UserID]*userLock
+	mu    sync.Mutex
+}
+
+type userLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newUserLocks() *userLocks {
+	return &userLocks{locks: make(map[domain.UserID]*userLock)}
+}
+
+// lock захватывает мьютекс пользователя и возвращает функцию его освобождения.
+func (l *userLocks) lock(userID domain.UserID) func() {
+	l.mu.Lock()
+	ul, ok := l.locks[userID]
+
+	if !ok {
+		ul = &userLock{}
+		l.locks[userID] = ul
+	}
+
+	ul.refs++
+	l.mu.Unlock()
+
+	ul.mu.Lock()
+
+	return func() {
+		ul.mu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		ul.refs--
+		if ul.refs == 0 {
+			delete(l.locks, userID)
+		}
+	}
+}
+
+// ParseUserQuotas разбирает квоты отдельных пользователей в формате <user id>=<limit>, перечисленные через
+// запятую. Для пустой строки возвращается nil.
+func ParseUserQuotas(s string) (map[domain.UserID]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	quotas := make(map[domain.UserID]int)
+
+	for _, item := range strings.Split(s, ",") {
+		id, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+
+		if !ok {
+			return nil, ErrQuotasAreInvalid
+		}
+
+		userID, err := uuid.Parse(strings.TrimSpace(id))
+
+		if err != nil {
+			return nil, ErrQuotasAreInvalid
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+
+		if err != nil || limit < 0 {
+			return nil, ErrQuotasAreInvalid
+		}
+
+		quotas[domain.UserID(userID)] = limit
+	}
+
+	return quotas, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+func TestParseUserQuotas(t *testing.T) {
+	const (
+		firstID  = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+		secondID = "6ba7b811-9dad-11d1-80b4-00c04fd430c8"
+	)
+
+	t.Run("parse user quotas", func(t *testing.T) {
+		got, err := ParseUserQuotas(firstID + "=100, " + secondID + " = 0")
+
+		require.NoError(t, err)
+		assert.Equal(t, map[domain.UserID]int{
+			domain.UserID(uuid.MustParse(firstID)):  100,
+			domain.UserID(uuid.MustParse(secondID)): 0,
+		}, got)
+	})
+
+	t.Run("empty string", func(t *testing.T) {
+		got, err := ParseUserQuotas("")
+
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("invalid quotas", func(t *testing.T) {
+		for _, s := range []string{firstID, "user=100", firstID + "=x", firstID + "=-1", firstID + "=1,"} {
+			_, err := ParseUserQuotas(s)
+
+			assert.ErrorIs(t, err, ErrQuotasAreInvalid, s)
+		}
+	})
+}
+
+func TestQuotasLimit(t *testing.T) {
+	userID := domain.NewUserID()
+	quotas := Quotas{Default: 10, Users: map[domain.UserID]int{userID: 100}}
+
+	assert.Equal(t, 100, quotas.Limit(userID))
+	assert.Equal(t, 10, quotas.Limit(domain.NewUserID()))
+	assert.Equal(t, 0, Quotas{}.Limit(userID))
+}
+
+func TestUserLocks(t *testing.T) {
+	t.Run("lock is removed after release", func(t *testing.T) {
+		sut := newUserLocks()
+		userID := domain.NewUserID()
+
+		release := sut.lock(userID)
+		assert.Len(t, sut.locks, 1)
+
+		release()
+		assert.Empty(t, sut.locks)
+	})
+
+	t.Run("lock is held until release", func(t *testing.T) {
+		sut := newUserLocks()
+		userID := domain.NewUserID()
+		release := sut.lock(userID)
+		locked := make(chan struct{})
+
+		go func() {
+			defer close(locked)
+			sut.lock(userID)()
+		}()
+
+		select {
+		case <-locked:
+			t.Fatal("lock acquired before release")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		release()
+		<-locked
+		assert.Empty(t, sut.locks)
+	})
+
+	t.Run("locks of different users are independent", func(t *testing.T) {
+		sut := newUserLocks()
+		release := sut.lock(domain.NewUserID())
+		defer release()
+
+		sut.lock(domain.NewUserID())()
+	})
+}
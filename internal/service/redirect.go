@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// ErrRedirectStatusIsInvalid возвращается, если код ответа перенаправления не поддерживается.
+var ErrRedirectStatusIsInvalid = errors.New("redirect status must be 301, 302, 307 or 308")
+
+// CheckRedirectStatus проверяет код ответа перенаправления. Допустимы коды 301, 302, 307 и 308.
+func CheckRedirectStatus(status int) error {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+
+	return ErrRedirectStatusIsInvalid
+}
+
+// IsPermanentRedirect возвращает true для кодов постоянного перенаправления 301 и 308.
+func IsPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// isCacheable возвращает true, если переход по сокращенному URL можно кешировать: у URL нет ограничения
+// количества переходов, времени начала и окончания действия, пароля, вариантов и правил выбора исходного URL.
+func isCacheable(pair domain.URLPair) bool {
+	return pair.MaxClicks == 0 && pair.ExpiresAt.IsZero() && pair.ActiveFrom.IsZero() && !pair.IsProtected() &&
+		!pair.HasDestinations() && len(pair.Rules) == 0
+}
+
+// getRedirectStatus проверяет код ответа перенаправления сокращенного URL. Нулевое значение означает
+// код по умолчанию сервера.
+func getRedirectStatus(status int) (int, error) {
+	if status == 0 {
+		return 0, nil
+	}
+
+	if err := CheckRedirectStatus(status); err != nil {
+		return 0, newValidationError(err)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+)
+
+func TestRedirectStatus(t *testing.T) {
+	t.Run("expand url with redirect status", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{URL: testURL, RedirectStatus: http.StatusMovedPermanently}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, Target{URL: testURL, RedirectStatus: http.StatusMovedPermanently, Cacheable: true}, got)
+	})
+
+	t.Run("dynamic url is not cacheable", func(t *testing.T) {
+		ttl := int64(time.Hour.Seconds())
+		activeFrom := time.Now().Add(-time.Hour)
+		reqs := map[string]ShortenRequest{
+			"max clicks":   {URL: testURL, MaxClicks: 10},
+			"ttl":          {URL: testURL, TTL: ttl},
+			"active from":  {URL: testURL, ActiveFrom: &activeFrom},
+			"password":     {URL: testURL, Password: "secret"},
+			"destinations": {Destinations: []domain.Destination{{URL: testURL, Weight: 1}}},
+			"rules":        {URL: testURL, Rules: []domain.RoutingRule{{OS: "ios", URL: testURL}}},
+		}
+		for name, req := range reqs {
+			t.Run(name, func(t *testing.T) {
+				sut := New(inmemory.New())
+				ctx := context.Background()
+				req.RedirectStatus = http.StatusMovedPermanently
+				shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+				require.NoError(t, err)
+
+				got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{Password: req.Password})
+
+				require.NoError(t, err)
+				assert.False(t, got.Cacheable)
+			})
+		}
+	})
+
+	t.Run("redirect status is invalid", func(t *testing.T) {
+		for _, status := range []int{http.StatusOK, http.StatusSeeOther, http.StatusNotModified, -1} {
+			sut := New(inmemory.New())
+			req := ShortenRequest{URL: testURL, RedirectStatus: status}
+
+			_, err := sut.Shorten(context.Background(), req, domain.NewUserID())
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.ErrorIs(t, err, ErrRedirectStatusIsInvalid)
+		}
+	})
+
+	t.Run("permanent redirect", func(t *testing.T) {
+		assert.True(t, IsPermanentRedirect(http.StatusMovedPermanently))
+		assert.True(t, IsPermanentRedirect(http.StatusPermanentRedirect))
+		assert.False(t, IsPermanentRedirect(http.StatusFound))
+		assert.False(t, IsPermanentRedirect(http.StatusTemporaryRedirect))
+	})
+}
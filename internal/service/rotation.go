@@ -0,0 +1,164 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+)
+
+// Ошибки проверки вариантов исходного URL.
+var (
+	ErrDestinationsAreAmbiguous = errors.New("both url and destinations are set") // заданы и URL, и варианты
+	ErrTooManyDestinations      = errors.New("too many destinations")             // вариантов больше допустимого
+	ErrVariantIsTooLong         = errors.New("destination name is too long")      // имя варианта длиннее допустимого
+	ErrVariantIsDuplicated      = errors.New("destination name is duplicated")    // имена вариантов повторяются
+	ErrWeightIsNegative         = errors.New("destination weight is negative")    // вес варианта отрицательный
+	ErrWeightsAreZero           = errors.New("destination weights are all zero")  // все варианты отключены
+	ErrRotationIsInvalid        = errors.New("rotation must be random or sticky") // неизвестный режим выбора варианта
+)
+
+// Ограничения вариантов исходного URL.
+const (
+	MaxDestinationsCount = 10 // максимальное количество вариантов
+	MaxVariantLength     = 64 // максимальная длина имени варианта в символах
+)
+
+// Target содержит адрес перехода по сокращенному URL.
+type Target struct {
+	URL            string // исходный URL, на который выполняется переход
+	Variant        string // имя выбранного варианта; пустое значение - варианты не заданы
+	Sticky         bool   // признак варианта, который закрепляется за клиентом
+	RedirectStatus int    // код ответа перенаправления, заданный для сокращенного URL; 0 - код сервера
+	Cacheable      bool   // признак перехода, который не зависит от времени, счетчика переходов, пароля и клиента
+}
+
+// newDestinations проверяет варианты исходного URL и возвращает их с проверенными URL. Пустые имена
+// заменяются порядковыми номерами вариантов, начиная с 1. Для пустого набора возвращается nil.
+func (s *URLService) newDestinations(reqs []domain.Destination) ([]domain.Destination, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	if len(reqs) > MaxDestinationsCount {
+		return nil, newValidationError(ErrTooManyDestinations)
+	}
+
+	destinations := make([]domain.Destination, len(reqs))
+	names := make(map[string]struct{}, len(reqs))
+	totalWeight := 0
+
+	for i, req := range reqs {
+		name := strings.TrimSpace(req.Name)
+
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+
+		if utf8.RuneCountInString(name) > MaxVariantLength {
+			return nil, newValidationError(ErrVariantIsTooLong)
+		}
+
+		if _, ok := names[name]; ok {
+			return nil, newValidationError(ErrVariantIsDuplicated)
+		}
+
+		if req.Weight < 0 {
+			return nil, newValidationError(ErrWeightIsNegative)
+		}
+
+		originalURL, _, err := s.checkURL(req.URL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		names[name] = struct{}{}
+		totalWeight += req.Weight
+		destinations[i] = domain.Destination{Name: name, URL: originalURL, Weight: req.Weight}
+	}
+
+	if totalWeight == 0 {
+		return nil, newValidationError(ErrWeightsAreZero)
+	}
+
+	return destinations, nil
+}
+
+// normalizeRotation проверяет режим выбора варианта. Если режим не задан, для URL с вариантами
+// выбирается domain.RotationRandom.
+func normalizeRotation(rotation string, destinations []domain.Destination) (string, error) {
+	if rotation != "" && rotation != domain.RotationRandom && rotation != domain.RotationSticky {
+		return "", newValidationError(ErrRotationIsInvalid)
+	}
+
+	if len(destinations) == 0 {
+		return "", nil
+	}
+
+	if rotation == "" {
+		return domain.RotationRandom, nil
+	}
+
+	return rotation, nil
+}
+
+// newTarget выбирает адрес перехода по сокращенному URL. Если варианты не заданы, переход выполняется
+// на исходный URL. В режиме domain.RotationSticky сохраняется вариант variant, закрепленный за клиентом,
+// если он существует и не отключен; иначе вариант выбирается случайно с учетом весов.
+func newTarget(pair domain.URLPair, variant string) Target {
+	if !pair.HasDestinations() {
+		return Target{URL: pair.OriginalURL}
+	}
+
+	sticky := pair.Rotation == domain.RotationSticky
+
+	if sticky {
+		for _, d := range pair.Destinations {
+			if d.Name == variant && d.Weight > 0 {
+				return Target{URL: d.URL, Variant: d.Name, Sticky: true}
+			}
+		}
+	}
+
+	d := pickDestination(pair.Destinations)
+	return Target{URL: d.URL, Variant: d.Name, Sticky: sticky}
+}
+
+// pickDestination выбирает вариант случайно; вероятность выбора пропорциональна весу варианта.
+func pickDestination(destinations []domain.Destination) domain.Destination {
+	totalWeight := 0
+	for _, d := range destinations {
+		totalWeight += d.Weight
+	}
+
+	if totalWeight <= 0 {
+		return destinations[0]
+	}
+
+	n := randomInt(totalWeight)
+	for _, d := range destinations {
+		if n < d.Weight {
+			return d
+		}
+
+		n -= d.Weight
+	}
+
+	return destinations[len(destinations)-1]
+}
+
+// randomInt возвращает случайное число в диапазоне [0, n).
+func randomInt(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+
+	if err != nil {
+		return 0
+	}
+
+	return int(v.Int64())
+}
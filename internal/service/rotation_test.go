@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+)
+
+func TestDestinations(t *testing.T) {
+	const (
+		urlA = "https://example.com/a"
+		urlB = "https://example.com/b"
+	)
+
+	t.Run("shorten url with destinations", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		req := ShortenRequest{Destinations: []domain.Destination{
+			{URL: " " + urlA + " ", Weight: 70},
+			{Name: " control ", URL: urlB, Weight: 30},
+		}}
+
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+
+		require.NoError(t, err)
+		got, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, urlA, got.OriginalURL)
+		assert.Equal(t, domain.RotationRandom, got.Rotation)
+		assert.Equal(t, []domain.Destination{
+			{Name: "1", URL: urlA, Weight: 70},
+			{Name: "control", URL: urlB, Weight: 30},
+		}, got.Destinations)
+	})
+
+	t.Run("invalid destinations", func(t *testing.T) {
+		tooMany := make([]domain.Destination, MaxDestinationsCount+1)
+		for i := range tooMany {
+			tooMany[i] = domain.Destination{URL: urlA, Weight: 1}
+		}
+
+		tests := []struct {
+			want error
+			name string
+			req  ShortenRequest
+		}{
+			{
+				name: "url and destinations are set",
+				req:  ShortenRequest{URL: urlA, Destinations: []domain.Destination{{URL: urlB, Weight: 1}}},
+				want: ErrDestinationsAreAmbiguous,
+			},
+			{
+				name: "too many destinations",
+				req:  ShortenRequest{Destinations: tooMany},
+				want: ErrTooManyDestinations,
+			},
+			{
+				name: "name is duplicated",
+				req: ShortenRequest{Destinations: []domain.Destination{
+					{Name: "a", URL: urlA, Weight: 1},
+					{Name: "a", URL: urlB, Weight: 1},
+				}},
+				want: ErrVariantIsDuplicated,
+			},
+			{
+				name: "weight is negative",
+				req:  ShortenRequest{Destinations: []domain.Destination{{URL: urlA, Weight: -1}}},
+				want: ErrWeightIsNegative,
+			},
+			{
+				name: "weights are zero",
+				req:  ShortenRequest{Destinations: []domain.Destination{{URL: urlA}, {URL: urlB}}},
+				want: ErrWeightsAreZero,
+			},
+			{
+				name: "destination url is empty",
+				req:  ShortenRequest{Destinations: []domain.Destination{{URL: urlA, Weight: 1}, {Weight: 1}}},
+				want: ErrURLIsEmpty,
+			},
+			{
+				name: "rotation is invalid",
+				req:  ShortenRequest{URL: urlA, Rotation: "round-robin"},
+				want: ErrRotationIsInvalid,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sut := New(inmemory.New())
+
+				_, err := sut.Shorten(context.Background(), tt.req, domain.NewUserID())
+
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, err, tt.want)
+			})
+		}
+	})
+
+	t.Run("expand url with destinations", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{Destinations: []domain.Destination{
+			{Name: "a", URL: urlA},
+			{Name: "b", URL: urlB, Weight: 1},
+		}}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, Target{URL: urlB, Variant: "b"}, got)
+	})
+
+	t.Run("expand url with sticky variant", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{
+			Destinations: []domain.Destination{
+				{Name: "a", URL: urlA, Weight: 1},
+				{Name: "b", URL: urlB, Weight: 1},
+			},
+			Rotation: domain.RotationSticky,
+		}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{Variant: "b"})
+
+			require.NoError(t, err)
+			assert.Equal(t, Target{URL: urlB, Variant: "b", Sticky: true}, got)
+		}
+	})
+
+	t.Run("expand url without destinations", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{Variant: "b"})
+
+		require.NoError(t, err)
+		assert.Equal(t, Target{URL: testURL, Cacheable: true}, got)
+	})
+}
+
+func TestNewTarget(t *testing.T) {
+	destinations := []domain.Destination{
+		{Name: "a", URL: "https://example.com/a", Weight: 1},
+		{Name: "b", URL: "https://example.com/b"},
+	}
+
+	t.Run("disabled sticky variant is not kept", func(t *testing.T) {
+		pair := domain.URLPair{Destinations: destinations, Rotation: domain.RotationSticky}
+
+		got := newTarget(pair, "b")
+
+		assert.Equal(t, Target{URL: "https://example.com/a", Variant: "a", Sticky: true}, got)
+	})
+
+	t.Run("unknown sticky variant is replaced", func(t *testing.T) {
+		pair := domain.URLPair{Destinations: destinations, Rotation: domain.RotationSticky}
+
+		got := newTarget(pair, "c")
+
+		assert.Equal(t, "a", got.Variant)
+	})
+
+	t.Run("variant is ignored in random rotation", func(t *testing.T) {
+		pair := domain.URLPair{Destinations: destinations, Rotation: domain.RotationRandom}
+
+		got := newTarget(pair, "b")
+
+		assert.Equal(t, Target{URL: "https://example.com/a", Variant: "a"}, got)
+	})
+}
+
+func TestPickDestination(t *testing.T) {
+	destinations := []domain.Destination{
+		{Name: "a", Weight: 3},
+		{Name: "b", Weight: 1},
+	}
+	counts := make(map[string]int)
+
+	for i := 0; i < 4000; i++ {
+		counts[pickDestination(destinations).Name]++
+	}
+
+	assert.InDelta(t, 3000, counts["a"], 300)
+	assert.InDelta(t, 1000, counts["b"], 300)
+}
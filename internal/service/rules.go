@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/routing"
+)
+
+// Ошибки проверки правил выбора исходного URL.
+var (
+	ErrTooManyRules      = errors.New("too many routing rules")           // правил больше допустимого
+	ErrRuleIsEmpty       = errors.New("routing rule has no conditions")   // в правиле не задано ни одного условия
+	ErrOSIsInvalid       = errors.New("routing rule os is unknown")       // неизвестная операционная система
+	ErrDeviceIsInvalid   = errors.New("routing rule device is unknown")   // неизвестный класс устройства
+	ErrLanguageIsInvalid = errors.New("routing rule language is invalid") // язык не является тегом языка
+)
+
+// MaxRulesCount - максимальное количество правил выбора исходного URL.
+const MaxRulesCount = 20
+
+// ExpandRequest содержит параметры перехода по сокращенному URL.
+type ExpandRequest struct {
+	Client   routing.Client // клиент, выполняющий переход; учитывается правилами выбора исходного URL
+	Password string         // пароль для перехода по защищенному сокращенному URL
+	Variant  string         // имя варианта, закрепленного за клиентом при предыдущем переходе
+	Path     string         // суффикс пути после ключа в экранированном виде; учитывается для domain.URLPair.ForwardPath
+	Query    string         // строка запроса перехода; ее параметры добавляются к исходному URL
+}
+
+// newRoutingRules проверяет правила выбора исходного URL и возвращает их с проверенными URL и условиями
+// в нижнем регистре. Для пустого набора возвращается nil.
+func (s *URLService) newRoutingRules(reqs []domain.RoutingRule) ([]domain.RoutingRule, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	if len(reqs) > MaxRulesCount {
+		return nil, newValidationError(ErrTooManyRules)
+	}
+
+	rules := make([]domain.RoutingRule, len(reqs))
+
+	for i, req := range reqs {
+		rule := domain.RoutingRule{
+			OS:       strings.ToLower(strings.TrimSpace(req.OS)),
+			Device:   strings.ToLower(strings.TrimSpace(req.Device)),
+			Language: strings.ToLower(strings.TrimSpace(req.Language)),
+		}
+
+		if rule.OS == "" && rule.Device == "" && rule.Language == "" {
+			return nil, newValidationError(ErrRuleIsEmpty)
+		}
+
+		if rule.OS != "" && !slices.Contains(routing.OSes, rule.OS) {
+			return nil, newValidationError(ErrOSIsInvalid)
+		}
+
+		if rule.Device != "" && !slices.Contains(routing.Devices, rule.Device) {
+			return nil, newValidationError(ErrDeviceIsInvalid)
+		}
+
+		if rule.Language != "" && !isLanguageTag(rule.Language) {
+			return nil, newValidationError(ErrLanguageIsInvalid)
+		}
+
+		originalURL, _, err := s.checkURL(req.URL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rule.URL = originalURL
+		rules[i] = rule
+	}
+
+	return rules, nil
+}
+
+// isLanguageTag проверяет, что значение имеет вид тега языка: основной подтег из 2-8 латинских букв
+// и необязательные подтеги из 1-8 латинских букв и цифр, разделенные дефисом.
+func isLanguageTag(tag string) bool {
+	for i, subtag := range strings.Split(tag, "-") {
+		if len(subtag) == 0 || len(subtag) > 8 || (i == 0 && len(subtag) < 2) {
+			return false
+		}
+
+		for _, c := range subtag {
+			isLetter := c >= 'a' && c <= 'z'
+			isDigit := c >= '0' && c <= '9'
+
+			if !isLetter && (i == 0 || !isDigit) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// routeTarget выбирает исходный URL по первому правилу, условиям которого удовлетворяет клиент.
+// Если ни одно правило не подходит, возвращается false.
+func routeTarget(pair domain.URLPair, client routing.Client) (Target, bool) {
+	rule, ok := routing.Match(pair.Rules, client)
+
+	if !ok {
+		return Target{}, false
+	}
+
+	return Target{URL: rule.URL}, true
+}
+
+// UpdateRoutingRules заменяет правила выбора исходного URL сокращенного URL, добавленного пользователем.
+// Возвращает сохраненные правила; пустой набор правил удаляет все правила.
+func (s *URLService) UpdateRoutingRules(ctx context.Context, shortURL string, rules []domain.RoutingRule,
+	userID domain.UserID) ([]domain.RoutingRule, error) {
+	rules, err := s.newRoutingRules(rules)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.store.UpdateRoutingRules(ctx, shortURL, rules, userID); err != nil {
+		return nil, fmt.Errorf("update routing rules: %w", err)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+	"github.com/nestjam/yap-shortener/internal/routing"
+)
+
+func TestRoutingRules(t *testing.T) {
+	const (
+		appStoreURL = "https://apps.apple.com/app/id1"
+		playURL     = "https://play.google.com/store/apps/details?id=com.example"
+	)
+
+	t.Run("shorten url with routing rules", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		req := ShortenRequest{URL: testURL, Rules: []domain.RoutingRule{
+			{OS: " iOS ", URL: " " + appStoreURL + " "},
+			{OS: "android", Device: "Mobile", Language: "EN-us", URL: playURL},
+		}}
+
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+
+		require.NoError(t, err)
+		got, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, []domain.RoutingRule{
+			{OS: routing.OSiOS, URL: appStoreURL},
+			{OS: routing.OSAndroid, Device: routing.DeviceMobile, Language: "en-us", URL: playURL},
+		}, got.Rules)
+	})
+
+	t.Run("invalid routing rules", func(t *testing.T) {
+		tooMany := make([]domain.RoutingRule, MaxRulesCount+1)
+		for i := range tooMany {
+			tooMany[i] = domain.RoutingRule{OS: routing.OSiOS, URL: appStoreURL}
+		}
+
+		tests := []struct {
+			want  error
+			name  string
+			rules []domain.RoutingRule
+		}{
+			{
+				name:  "too many rules",
+				rules: tooMany,
+				want:  ErrTooManyRules,
+			},
+			{
+				name:  "rule has no conditions",
+				rules: []domain.RoutingRule{{URL: appStoreURL}},
+				want:  ErrRuleIsEmpty,
+			},
+			{
+				name:  "os is unknown",
+				rules: []domain.RoutingRule{{OS: "symbian", URL: appStoreURL}},
+				want:  ErrOSIsInvalid,
+			},
+			{
+				name:  "device is unknown",
+				rules: []domain.RoutingRule{{Device: "watch", URL: appStoreURL}},
+				want:  ErrDeviceIsInvalid,
+			},
+			{
+				name:  "language is invalid",
+				rules: []domain.RoutingRule{{Language: "en_US", URL: appStoreURL}},
+				want:  ErrLanguageIsInvalid,
+			},
+			{
+				name:  "rule url is empty",
+				rules: []domain.RoutingRule{{OS: routing.OSiOS}},
+				want:  ErrURLIsEmpty,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sut := New(inmemory.New())
+				req := ShortenRequest{URL: testURL, Rules: tt.rules}
+
+				_, err := sut.Shorten(context.Background(), req, domain.NewUserID())
+
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, err, tt.want)
+			})
+		}
+	})
+
+	t.Run("expand url by routing rules", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{URL: testURL, Rules: []domain.RoutingRule{
+			{OS: routing.OSiOS, URL: appStoreURL},
+			{OS: routing.OSAndroid, URL: playURL},
+		}}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		tests := []struct {
+			name   string
+			client routing.Client
+			want   string
+		}{
+			{
+				name:   "ios client",
+				client: routing.Client{OS: routing.OSiOS, Device: routing.DeviceMobile},
+				want:   appStoreURL,
+			},
+			{
+				name:   "android client",
+				client: routing.Client{OS: routing.OSAndroid, Device: routing.DeviceTablet},
+				want:   playURL,
+			},
+			{
+				name:   "other client falls back to original url",
+				client: routing.Client{OS: routing.OSWindows, Device: routing.DeviceDesktop},
+				want:   testURL,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{Client: tt.client})
+
+				require.NoError(t, err)
+				assert.Equal(t, Target{URL: tt.want}, got)
+			})
+		}
+	})
+
+	t.Run("routing rules take precedence over destinations", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{
+			Destinations: []domain.Destination{{Name: "a", URL: testURL, Weight: 1}},
+			Rotation:     domain.RotationSticky,
+			Rules:        []domain.RoutingRule{{OS: routing.OSiOS, URL: appStoreURL}},
+		}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.ExpandTarget(ctx, shortURL, ExpandRequest{Client: routing.Client{OS: routing.OSiOS}})
+
+		require.NoError(t, err)
+		assert.Equal(t, Target{URL: appStoreURL}, got)
+	})
+
+	t.Run("update routing rules", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+
+		got, err := sut.UpdateRoutingRules(ctx, shortURL, []domain.RoutingRule{{Language: "DE", URL: appStoreURL}}, userID)
+
+		require.NoError(t, err)
+		want := []domain.RoutingRule{{Language: "de", URL: appStoreURL}}
+		assert.Equal(t, want, got)
+		pair, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, want, pair.Rules)
+	})
+
+	t.Run("update routing rules of url added by other user", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, domain.NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.UpdateRoutingRules(ctx, shortURL, nil, domain.NewUserID())
+
+		assert.ErrorIs(t, err, domain.ErrOriginalURLNotOwned)
+	})
+}
@@ -0,0 +1,1071 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/nestjam/yap-shortener/internal/canonical"
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/policy"
+	"github.com/nestjam/yap-shortener/internal/shortener"
+)
+
+// Ошибки проверки запросов на сокращение URL.
+var (
+	ErrURLIsEmpty            = errors.New("url is empty")                    // исходный URL не задан
+	ErrBatchIsEmpty          = errors.New("batch is empty")                  // коллекция URL пуста
+	ErrTTLIsNegative         = errors.New("ttl is negative")                 // время жизни отрицательное
+	ErrTTLIsTooLong          = errors.New("ttl is too long")                 // время жизни больше допустимого
+	ErrExpirationIsAmbiguous = errors.New("both expires_at and ttl are set") // заданы и время окончания, и время жизни
+	ErrExpirationIsInPast    = errors.New("expiration time is in the past")  // время окончания действия уже прошло
+	ErrTitleIsTooLong        = errors.New("title is too long")               // заголовок длиннее допустимого
+	ErrTagIsTooLong          = errors.New("tag is too long")                 // метка длиннее допустимого
+	ErrTooManyTags           = errors.New("too many tags")                   // меток больше допустимого
+	ErrMaxClicksIsNegative   = errors.New("max_clicks is negative")          // ограничение переходов отрицательное
+	ErrActivationIsTooLate   = errors.New("active_from is after expires_at") // начало действия не раньше окончания
+)
+
+// Ограничения заголовка, меток и времени жизни сокращенного URL.
+const (
+	MaxTitleLength = 256                   // максимальная длина заголовка в символах
+	MaxTagLength   = 64                    // максимальная длина метки в символах
+	MaxTagsCount   = 20                    // максимальное количество меток
+	MaxTTL         = 100 * 365 * 24 * 3600 // максимальное время жизни в секундах (100 лет)
+)
+
+// Ошибки проверки запроса страницы URL пользователя.
+var (
+	ErrLimitIsInvalid = errors.New("limit is invalid") // размер страницы вне допустимого диапазона
+	ErrOrderIsInvalid = errors.New("order is invalid") // неизвестный порядок сортировки
+)
+
+// Ограничения размера страницы URL пользователя.
+const (
+	DefaultPageLimit = 100  // размер страницы, если он не задан в запросе
+	MaxPageLimit     = 1000 // максимальный размер страницы
+)
+
+// Порядок сортировки URL пользователя по времени создания.
+const (
+	OrderAsc  = "asc"  // сначала старые
+	OrderDesc = "desc" // сначала новые
+)
+
+// Ошибки перехода по сокращенному URL, защищенному паролем.
+var (
+	ErrPasswordRequired  = errors.New("password is required") // пароль не указан
+	ErrPasswordIsInvalid = errors.New("password is invalid")  // пароль не совпадает
+	ErrTooManyAttempts   = errors.New("too many attempts")    // исчерпан лимит неудачных попыток
+	ErrPasswordIsTooLong = errors.New("password is too long") // пароль длиннее допустимого
+)
+
+// Ограничение неудачных попыток ввода пароля по умолчанию.
+const (
+	defaultMaxPasswordAttempts   = 5
+	defaultPasswordAttemptWindow = 15 * time.Minute
+)
+
+// ImportChunkSize определяет количество URL, которые добавляются в хранилище за одно обращение при импорте.
+const ImportChunkSize = 1000
+
+// ErrTooManyURLs возвращается, если в запросе больше URL, чем разрешено.
+var ErrTooManyURLs = errors.New("to many urls")
+
+// ValidationError определяет ошибку проверки параметров запроса.
+type ValidationError struct {
+	err error
+}
+
+func newValidationError(err error) *ValidationError {
+	return &ValidationError{err: err}
+}
+
+// Error возвращает текст ошибки.
+func (e *ValidationError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap возвращает исходную ошибку.
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// DeleteCallback вызывается после выполнения отложенного удаления с результатами или ошибкой.
+type DeleteCallback func(results []domain.KeyResult, err error)
+
+// URLRemover определяет компонент, который выполняет отложенное удаление сокращенных URL.
+type URLRemover interface {
+	DeleteURLs(shortURLs []string, userID domain.UserID, done DeleteCallback) error
+}
+
+// URLService реализует логику сервиса сокращения ссылок, общую для HTTP и gRPC API.
+type URLService struct {
+	store               domain.URLStore
+	urlRemover          URLRemover
+	attemptLimiter      *AttemptLimiter
+	deleteJobs          *DeleteJobs
+	canonicalizer       *canonical.Canonicalizer
+	domainPolicy        *policy.DomainPolicy
+	quotaLocks          *userLocks
+	quotas              Quotas
+	shortenURLsMaxCount int
+}
+
+// ShortenRequest содержит параметры сокращения URL.
+type ShortenRequest struct {
+	ExpiresAt      *time.Time           // время окончания действия сокращенного URL
+	ActiveFrom     *time.Time           // время начала действия сокращенного URL; до него переход не выполняется
+	URL            string               // исходный URL
+	Alias          string               // ключ сокращенного URL, выбранный пользователем
+	TTL            int64                // время жизни сокращенного URL в секундах
+	Password       string               // пароль для перехода по сокращенному URL; пустое значение - без пароля
+	Title          string               // заголовок сокращенного URL, заданный владельцем
+	Tags           []string             // метки сокращенного URL, заданные владельцем
+	MaxClicks      int                  // максимальное количество переходов по сокращенному URL; 0 - без ограничения
+	Destinations   []domain.Destination // варианты исходного URL; задаются вместо URL, исходным считается первый
+	Rotation       string               // режим выбора варианта; пустое значение - domain.RotationRandom
+	Rules          []domain.RoutingRule // правила выбора исходного URL по клиенту; проверяются по порядку
+	ForwardPath    bool                 // признак добавления к исходному URL суффикса пути перехода
+	RedirectStatus int                  // код ответа перенаправления: 301, 302, 307 или 308; 0 - код сервера
+}
+
+// ImportResult содержит результат импорта одного сокращенного URL.
+type ImportResult struct {
+	Err      error            // ошибка проверки данных URL; задана для статуса domain.KeyInvalid
+	ShortURL string           // ключ сокращенного URL
+	Status   domain.KeyStatus // результат импорта
+}
+
+// UserURLsRequest содержит параметры запроса страницы URL, сокращенных пользователем.
+type UserURLsRequest struct {
+	Cursor string // курсор следующей страницы из предыдущего ответа; пустое значение - первая страница
+	Filter string // подстрока, которую должен содержать исходный URL
+	Tag    string // метка, которой должен быть отмечен URL; пустое значение - без отбора по метке
+	Order  string // порядок сортировки по времени создания: asc или desc (по умолчанию)
+	Limit  int    // размер страницы; 0 - размер по умолчанию
+}
+
+// UserURLsPage содержит страницу URL, сокращенных пользователем.
+type UserURLsPage struct {
+	NextCursor string           // курсор следующей страницы; пустое значение - страница последняя
+	URLs       []domain.URLPair // URL страницы
+}
+
+// Stats содержит сведения о количестве сокращенных URL и пользователей сервиса.
+type Stats struct {
+	URLs  int // количество сокращенных URL
+	Users int // количество пользователей
+}
+
+// Option определяет опцию настройки сервиса.
+type Option func(*URLService)
+
+// New создает сервис сокращения ссылок на основе хранилища и набора опций.
+func New(store domain.URLStore, options ...Option) *URLService {
+	s := &URLService{
+		store:          store,
+		attemptLimiter: NewAttemptLimiter(defaultMaxPasswordAttempts, defaultPasswordAttemptWindow),
+		deleteJobs:     NewDeleteJobs(defaultJobRetention),
+		canonicalizer:  canonical.New(),
+		quotaLocks:     newUserLocks(),
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithShortenURLsMaxCount определяет максимальное количество URL в запросе на сокращение коллекции URL.
+func WithShortenURLsMaxCount(count int) Option {
+	return func(s *URLService) {
+		s.shortenURLsMaxCount = count
+	}
+}
+
+// WithAttemptLimiter задает ограничитель неудачных попыток ввода пароля.
+func WithAttemptLimiter(limiter *AttemptLimiter) Option {
+	return func(s *URLService) {
+		s.attemptLimiter = limiter
+	}
+}
+
+// WithURLRemover задает компонент, который выполняет удаление сохраненных URL.
+func WithURLRemover(remover URLRemover) Option {
+	return func(s *URLService) {
+		s.urlRemover = remover
+	}
+}
+
+// WithCanonicalizer задает компонент проверки исходных URL и приведения их к каноническому виду.
+func WithCanonicalizer(canonicalizer *canonical.Canonicalizer) Option {
+	return func(s *URLService) {
+		s.canonicalizer = canonicalizer
+	}
+}
+
+// WithDomainPolicy задает политику доменов исходных URL. Без политики разрешены любые домены.
+func WithDomainPolicy(domainPolicy *policy.DomainPolicy) Option {
+	return func(s *URLService) {
+		s.domainPolicy = domainPolicy
+	}
+}
+
+// WithQuotas задает квоты пользователей на количество сокращенных URL. Без квот количество не ограничено.
+func WithQuotas(quotas Quotas) Option {
+	return func(s *URLService) {
+		s.quotas = quotas
+	}
+}
+
+// Shorten сохраняет исходный URL и возвращает ключ сокращенного URL. Если ключ не задан пользователем,
+// он генерируется. Если URL с тем же каноническим видом уже был сокращен, возвращается ранее созданный ключ
+// и ошибка *domain.OriginalURLExistsError.
+func (s *URLService) Shorten(ctx context.Context, req ShortenRequest, userID domain.UserID) (string, error) {
+	pair, err := s.newURLPair(req, time.Now())
+
+	if err != nil {
+		return "", err
+	}
+
+	if pair.ShortURL == "" {
+		pair.ShortURL = newShortURL()
+	} else if err = shortener.Validate(pair.ShortURL); err != nil {
+		return "", newValidationError(err)
+	}
+
+	release, err := s.reserveQuota(ctx, userID, 1)
+
+	if err != nil {
+		return "", fmt.Errorf("shorten: %w", err)
+	}
+
+	defer release()
+
+	err = s.store.AddURL(ctx, pair, userID)
+
+	var originalURLAlreadyExists *domain.OriginalURLExistsError
+	if errors.As(err, &originalURLAlreadyExists) {
+		return originalURLAlreadyExists.GetShortURL(), err
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("shorten: %w", err)
+	}
+
+	return pair.ShortURL, nil
+}
+
+// ShortenBatch сохраняет коллекцию исходных URL и возвращает сгенерированные ключи сокращенных URL
+// в порядке следования запросов. Для исходных URL, которые уже были сокращены, возвращаются ранее
+// созданные ключи.
+func (s *URLService) ShortenBatch(ctx context.Context, reqs []ShortenRequest, userID domain.UserID) ([]string, error) {
+	if len(reqs) == 0 {
+		return nil, newValidationError(ErrBatchIsEmpty)
+	}
+
+	if s.shortenURLsMaxCount > 0 && len(reqs) > s.shortenURLsMaxCount {
+		return nil, ErrTooManyURLs
+	}
+
+	now := time.Now()
+	pairs := make([]domain.URLPair, len(reqs))
+	for i := 0; i < len(reqs); i++ {
+		pair, err := s.newURLPair(reqs[i], now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pair.ShortURL = newShortURL()
+		pairs[i] = pair
+	}
+
+	release, err := s.reserveQuota(ctx, userID, len(pairs))
+
+	if err != nil {
+		return nil, fmt.Errorf("shorten batch: %w", err)
+	}
+
+	defer release()
+
+	err = s.store.AddURLs(ctx, pairs, userID)
+
+	var originalURLAlreadyExists *domain.OriginalURLExistsError
+	if errors.As(err, &originalURLAlreadyExists) {
+		// Коллекция не сохранилась из-за исходных URL, которые уже сокращены или повторяются в коллекции.
+		// Такие URL выявляются при добавлении по одному.
+		return s.addBatchURLs(ctx, pairs, userID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("shorten batch: %w", err)
+	}
+
+	shortURLs := make([]string, len(pairs))
+	for i := 0; i < len(pairs); i++ {
+		shortURLs[i] = pairs[i].ShortURL
+	}
+
+	return shortURLs, nil
+}
+
+// addBatchURLs добавляет пары коллекции по одному и возвращает их ключи. Для исходного URL, который уже
+// сокращен, возвращается ранее созданный ключ.
+func (s *URLService) addBatchURLs(ctx context.Context, pairs []domain.URLPair, userID domain.UserID) ([]string,
+	error) {
+	shortURLs := make([]string, len(pairs))
+
+	for i, pair := range pairs {
+		err := s.store.AddURL(ctx, pair, userID)
+
+		var originalURLAlreadyExists *domain.OriginalURLExistsError
+		if errors.As(err, &originalURLAlreadyExists) {
+			shortURLs[i] = originalURLAlreadyExists.GetShortURL()
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("shorten batch: %w", err)
+		}
+
+		shortURLs[i] = pair.ShortURL
+	}
+
+	return shortURLs, nil
+}
+
+// ImportURLs добавляет сокращенные URL с заданными ключами (ShortenRequest.Alias) и возвращает результат
+// импорта каждого URL в порядке следования запросов. URL с некорректными данными, занятым ключом или уже
+// сокращенным исходным URL пропускаются, остальные добавляются порциями по ImportChunkSize. Квота пользователя
+// проверяется для каждой порции по количеству добавляемых URL. При ошибке возвращаются результаты порций,
+// сохраненных до нее, например, если очередная порция не умещается в квоту (QuotaExceededError).
+func (s *URLService) ImportURLs(ctx context.Context, reqs []ShortenRequest,
+	userID domain.UserID) ([]ImportResult, error) {
+	results := make([]ImportResult, len(reqs))
+	now := time.Now()
+
+	for start := 0; start < len(reqs); start += ImportChunkSize {
+		end := min(start+ImportChunkSize, len(reqs))
+
+		if err := s.importChunk(ctx, reqs[start:end], results[start:end], userID, now); err != nil {
+			return results[:start], fmt.Errorf("import urls: %w", err)
+		}
+	}
+
+	return results, nil
+}
+
+// importChunk импортирует порцию URL и записывает результат импорта каждого URL в results.
+func (s *URLService) importChunk(ctx context.Context, reqs []ShortenRequest, results []ImportResult,
+	userID domain.UserID, now time.Time) error {
+	pairs := make([]domain.URLPair, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+	keys := make(map[string]struct{}, len(reqs))
+	originals := make(map[string]struct{}, len(reqs))
+
+	for i, req := range reqs {
+		results[i].ShortURL = req.Alias
+		pair, err := s.newImportedURLPair(req, now)
+
+		if err != nil {
+			results[i].Status = domain.KeyInvalid
+			results[i].Err = err
+			continue
+		}
+
+		if _, ok := keys[pair.ShortURL]; ok {
+			results[i].Status = domain.KeyTaken
+			continue
+		}
+
+		if _, ok := originals[pair.Canonical()]; ok {
+			results[i].Status = domain.KeyURLExists
+			continue
+		}
+
+		keys[pair.ShortURL] = struct{}{}
+		originals[pair.Canonical()] = struct{}{}
+		pairs = append(pairs, pair)
+		indexes = append(indexes, i)
+	}
+
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	conflicts, err := s.store.FindConflicts(ctx, pairs)
+
+	if err != nil {
+		return fmt.Errorf("import chunk: %w", err)
+	}
+
+	var added []domain.URLPair
+	var addedIndexes []int
+
+	for j, pair := range pairs {
+		if status, ok := conflicts[pair.ShortURL]; ok {
+			results[indexes[j]].Status = status
+			continue
+		}
+
+		added = append(added, pair)
+		addedIndexes = append(addedIndexes, indexes[j])
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	release, err := s.reserveQuota(ctx, userID, len(added))
+
+	if err != nil {
+		return fmt.Errorf("import chunk: %w", err)
+	}
+
+	defer release()
+
+	if err = s.store.AddURLs(ctx, added, userID); err == nil {
+		for _, i := range addedIndexes {
+			results[i].Status = domain.KeyImported
+		}
+
+		return nil
+	}
+
+	// Порция могла не сохраниться из-за URL, добавленных после поиска конфликтов.
+	// Такие URL выявляются при добавлении по одному.
+	for j, pair := range added {
+		status, err := s.addImportedURL(ctx, pair, userID)
+
+		if err != nil {
+			return fmt.Errorf("import chunk: %w", err)
+		}
+
+		results[addedIndexes[j]].Status = status
+	}
+
+	return nil
+}
+
+// addImportedURL добавляет импортируемый URL и возвращает результат импорта.
+func (s *URLService) addImportedURL(ctx context.Context, pair domain.URLPair,
+	userID domain.UserID) (domain.KeyStatus, error) {
+	err := s.store.AddURL(ctx, pair, userID)
+
+	var shortURLAlreadyExists *domain.ShortURLExistsError
+	if errors.As(err, &shortURLAlreadyExists) {
+		return domain.KeyTaken, nil
+	}
+
+	var originalURLAlreadyExists *domain.OriginalURLExistsError
+	if errors.As(err, &originalURLAlreadyExists) {
+		return domain.KeyURLExists, nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("add imported url: %w", err)
+	}
+
+	return domain.KeyImported, nil
+}
+
+// GetURL возвращает сохраненный сокращенный URL по ключу без проверки пароля.
+func (s *URLService) GetURL(ctx context.Context, shortURL string) (domain.URLPair, error) {
+	pair, err := s.store.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return domain.URLPair{}, fmt.Errorf("get url: %w", err)
+	}
+
+	return pair, nil
+}
+
+// PreviewURL возвращает пару исходного и сокращенного URL для страницы предварительного просмотра.
+// Если домен исходного URL запрещен политикой после сокращения, возвращается ошибка *policy.ViolationError,
+// как и при переходе по сокращенному URL.
+func (s *URLService) PreviewURL(ctx context.Context, shortURL string) (domain.URLPair, error) {
+	pair, err := s.store.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return domain.URLPair{}, fmt.Errorf("preview url: %w", err)
+	}
+
+	if err = s.checkDomain(pair.Canonical()); err != nil {
+		return domain.URLPair{}, fmt.Errorf("preview url: %w", err)
+	}
+
+	return pair, nil
+}
+
+// Expand возвращает исходный URL по ключу сокращенного URL. Если сокращенный URL защищен паролем,
+// пароль проверяется; количество неудачных попыток для одного ключа ограничено. Если домен исходного URL
+// запрещен политикой после сокращения, возвращается ошибка *policy.ViolationError. Для URL с ограниченным
+// количеством переходов списывается переход; если переходы исчерпаны, возвращается ошибка
+// domain.ErrOriginalURLIsExhausted. Если заданы варианты исходного URL, вариант выбирается случайно
+// с учетом весов. Правила выбора исходного URL по клиенту не применяются.
+func (s *URLService) Expand(ctx context.Context, shortURL, password string) (string, error) {
+	target, err := s.ExpandTarget(ctx, shortURL, ExpandRequest{Password: password})
+
+	if err != nil {
+		return "", err
+	}
+
+	return target.URL, nil
+}
+
+// ExpandTarget выполняет те же проверки, что и Expand, и возвращает адрес перехода вместе с выбранным
+// вариантом исходного URL. Если клиент удовлетворяет условиям правила выбора исходного URL, переход
+// выполняется на URL первого такого правила; иначе - на исходный URL или выбранный вариант. Имя варианта,
+// закрепленного за клиентом, учитывается в режиме domain.RotationSticky. К адресу перехода добавляются
+// параметры строки запроса перехода и, если это разрешено для сокращенного URL, суффикс пути; для
+// сокращенного URL без передачи пути переход с суффиксом возвращает ошибку domain.ErrOriginalURLNotFound.
+func (s *URLService) ExpandTarget(ctx context.Context, shortURL string, req ExpandRequest) (Target, error) {
+	pair, err := s.store.GetURL(ctx, shortURL)
+
+	if err != nil {
+		return Target{}, fmt.Errorf("expand: %w", err)
+	}
+
+	if pair.IsScheduled(time.Now()) {
+		return Target{}, fmt.Errorf("expand: %w", domain.ErrOriginalURLIsNotActive)
+	}
+
+	target, ok := routeTarget(pair, req.Client)
+	if !ok {
+		target = newTarget(pair, req.Variant)
+	}
+
+	checkedURL := pair.Canonical()
+	if target.URL != pair.OriginalURL {
+		checkedURL = target.URL
+	}
+
+	if err = s.checkDomain(checkedURL); err != nil {
+		return Target{}, fmt.Errorf("expand: %w", err)
+	}
+
+	if req.Path != "" && !pair.ForwardPath {
+		return Target{}, fmt.Errorf("expand: %w", domain.ErrOriginalURLNotFound)
+	}
+
+	if target.URL, err = forwardURL(target.URL, req.Path, req.Query); err != nil {
+		return Target{}, fmt.Errorf("expand: %w", err)
+	}
+
+	target.RedirectStatus = pair.RedirectStatus
+	target.Cacheable = isCacheable(pair)
+
+	if pair.IsProtected() {
+		if err = s.checkPassword(pair, req.Password); err != nil {
+			return Target{}, err
+		}
+	}
+
+	if pair.MaxClicks > 0 {
+		if err = s.store.ConsumeClick(ctx, shortURL); err != nil {
+			return Target{}, fmt.Errorf("expand: %w", err)
+		}
+	}
+
+	return target, nil
+}
+
+// checkPassword проверяет пароль сокращенного URL с учетом ограничения неудачных попыток. Попытка
+// резервируется до проверки пароля и снимается только при успешной проверке.
+func (s *URLService) checkPassword(pair domain.URLPair, password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	if !s.attemptLimiter.Reserve(pair.ShortURL, time.Now()) {
+		return ErrTooManyAttempts
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(pair.PasswordHash), []byte(password))
+
+	if err != nil {
+		return ErrPasswordIsInvalid
+	}
+
+	s.attemptLimiter.Reset(pair.ShortURL)
+	return nil
+}
+
+// GetDeletedUserURLs возвращает удаленные URL, сокращенные пользователем.
+func (s *URLService) GetDeletedUserURLs(ctx context.Context, userID domain.UserID) ([]domain.URLPair, error) {
+	pairs, err := s.store.GetDeletedUserURLs(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("get deleted user urls: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// ExportUserURLs передает функции обхода все URL, сокращенные пользователем, включая удаленные,
+// в порядке создания.
+func (s *URLService) ExportUserURLs(ctx context.Context, userID domain.UserID, visit domain.URLVisitor) error {
+	if err := s.store.WalkUserURLs(ctx, userID, visit); err != nil {
+		return fmt.Errorf("export user urls: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserURLsPage возвращает страницу URL, сокращенных пользователем.
+func (s *URLService) GetUserURLsPage(ctx context.Context, req UserURLsRequest,
+	userID domain.UserID) (UserURLsPage, error) {
+	query, err := newUserURLsQuery(req)
+
+	if err != nil {
+		return UserURLsPage{}, err
+	}
+
+	page, err := s.store.GetUserURLsPage(ctx, userID, query)
+
+	if err != nil {
+		return UserURLsPage{}, fmt.Errorf("get user urls page: %w", err)
+	}
+
+	resp := UserURLsPage{URLs: page.URLs}
+
+	if page.Next != nil {
+		resp.NextCursor = page.Next.String()
+	}
+
+	return resp, nil
+}
+
+func newUserURLsQuery(req UserURLsRequest) (domain.UserURLsQuery, error) {
+	query := domain.UserURLsQuery{
+		Filter: req.Filter,
+		Tag:    strings.TrimSpace(req.Tag),
+		Limit:  req.Limit,
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultPageLimit
+	}
+
+	if query.Limit < 0 || query.Limit > MaxPageLimit {
+		return domain.UserURLsQuery{}, newValidationError(ErrLimitIsInvalid)
+	}
+
+	switch req.Order {
+	case "", OrderDesc:
+		query.Order = domain.SortDesc
+	case OrderAsc:
+		query.Order = domain.SortAsc
+	default:
+		return domain.UserURLsQuery{}, newValidationError(ErrOrderIsInvalid)
+	}
+
+	if req.Cursor != "" {
+		cursor, err := domain.ParseURLCursor(req.Cursor)
+
+		if err != nil {
+			return domain.UserURLsQuery{}, newValidationError(err)
+		}
+
+		query.After = &cursor
+	}
+
+	return query, nil
+}
+
+// UpdateOriginalURL заменяет исходный URL сокращенного URL, добавленного пользователем.
+// Если URL с тем же каноническим видом уже был сокращен, возвращается ошибка *domain.OriginalURLExistsError.
+func (s *URLService) UpdateOriginalURL(ctx context.Context, shortURL, originalURL string,
+	userID domain.UserID) error {
+	originalURL, canonicalURL, err := s.checkURL(originalURL)
+
+	if err != nil {
+		return err
+	}
+
+	if err = s.store.UpdateOriginalURL(ctx, shortURL, originalURL, canonicalURL, userID); err != nil {
+		return fmt.Errorf("update original url: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTags заменяет метки сокращенного URL, добавленного пользователем. Возвращает сохраненные метки:
+// без повторов и пустых значений, в порядке возрастания.
+func (s *URLService) UpdateTags(ctx context.Context, shortURL string, tags []string,
+	userID domain.UserID) ([]string, error) {
+	tags, err := normalizeTags(tags)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.store.UpdateTags(ctx, shortURL, tags, userID); err != nil {
+		return nil, fmt.Errorf("update tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// DeleteUserURLs удаляет сокращенные пользователем URL и возвращает идентификатор задачи удаления.
+// Если задан компонент отложенного удаления, удаление выполняется асинхронно, а ход его выполнения
+// можно узнать с помощью GetDeleteJob.
+func (s *URLService) DeleteUserURLs(ctx context.Context, shortURLs []string, userID domain.UserID) (string, error) {
+	job := s.deleteJobs.Start(userID, time.Now())
+	done := func(results []domain.KeyResult, err error) {
+		s.deleteJobs.Finish(job.ID, results, err, time.Now())
+	}
+
+	if s.urlRemover == nil {
+		results, err := s.store.DeleteUserURLs(ctx, shortURLs, userID)
+		done(results, err)
+
+		if err != nil {
+			return "", fmt.Errorf("delete user urls: %w", err)
+		}
+
+		return job.ID, nil
+	}
+
+	if err := s.urlRemover.DeleteURLs(shortURLs, userID, done); err != nil {
+		done(nil, err)
+		return "", fmt.Errorf("delete user urls: %w", err)
+	}
+
+	return job.ID, nil
+}
+
+// RestoreUserURLs восстанавливает удаленные пользователем URL и возвращает результат восстановления
+// каждого URL.
+func (s *URLService) RestoreUserURLs(ctx context.Context, shortURLs []string,
+	userID domain.UserID) ([]domain.KeyResult, error) {
+	results, err := s.store.RestoreUserURLs(ctx, shortURLs, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("restore user urls: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetDeleteJob возвращает задачу удаления, созданную пользователем.
+func (s *URLService) GetDeleteJob(id string, userID domain.UserID) (DeleteJob, error) {
+	job, err := s.deleteJobs.Get(id, userID, time.Now())
+
+	if err != nil {
+		return DeleteJob{}, fmt.Errorf("get delete job: %w", err)
+	}
+
+	return job, nil
+}
+
+// GetClickStats возвращает статистику переходов по сокращенному URL, добавленному пользователем.
+func (s *URLService) GetClickStats(ctx context.Context, shortURL string, userID domain.UserID) (domain.ClickStats, error) {
+	stats, err := s.store.GetClickStats(ctx, shortURL, userID)
+
+	if err != nil {
+		return domain.ClickStats{}, fmt.Errorf("get click stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetStats возвращает количество сокращенных URL и пользователей сервиса.
+func (s *URLService) GetStats(ctx context.Context) (Stats, error) {
+	urls, err := s.store.GetURLsCount(ctx)
+
+	if err != nil {
+		return Stats{}, fmt.Errorf("get stats: %w", err)
+	}
+
+	users, err := s.store.GetUsersCount(ctx)
+
+	if err != nil {
+		return Stats{}, fmt.Errorf("get stats: %w", err)
+	}
+
+	return Stats{URLs: urls, Users: users}, nil
+}
+
+// GetQuota возвращает квоту пользователя на количество сокращенных URL и ее использование.
+func (s *URLService) GetQuota(ctx context.Context, userID domain.UserID) (Quota, error) {
+	used, err := s.store.GetUserURLsCount(ctx, userID)
+
+	if err != nil {
+		return Quota{}, fmt.Errorf("get quota: %w", err)
+	}
+
+	return Quota{Limit: s.quotas.Limit(userID), Used: used}, nil
+}
+
+// reserveQuota проверяет, что добавление count URL не превысит квоту пользователя, и возвращает функцию,
+// которую нужно вызвать после добавления URL. До ее вызова другие запросы пользователя с ограниченной квотой
+// ожидают, поэтому одновременные запросы в пределах экземпляра сервиса не превышают квоту. Если квота
+// будет превышена, возвращается ошибка *QuotaExceededError.
+func (s *URLService) reserveQuota(ctx context.Context, userID domain.UserID, count int) (func(), error) {
+	limit := s.quotas.Limit(userID)
+
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	release := s.quotaLocks.lock(userID)
+	used, err := s.store.GetUserURLsCount(ctx, userID)
+
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("check quota: %w", err)
+	}
+
+	if used+count > limit {
+		release()
+		return nil, &QuotaExceededError{quota: Quota{Limit: limit, Used: used}}
+	}
+
+	return release, nil
+}
+
+// IsAvailable позволяет проверить доступность сервиса.
+func (s *URLService) IsAvailable(ctx context.Context) bool {
+	return s.store.IsAvailable(ctx)
+}
+
+func (s *URLService) newURLPair(req ShortenRequest, now time.Time) (domain.URLPair, error) {
+	destinations, err := s.newDestinations(req.Destinations)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	rawURL := req.URL
+	if len(destinations) > 0 {
+		if strings.TrimSpace(req.URL) != "" {
+			return domain.URLPair{}, newValidationError(ErrDestinationsAreAmbiguous)
+		}
+
+		rawURL = destinations[0].URL
+	}
+
+	originalURL, canonicalURL, err := s.checkURL(rawURL)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	rotation, err := normalizeRotation(req.Rotation, destinations)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	expiresAt, err := getExpiresAt(req.ExpiresAt, req.TTL, now)
+
+	if err != nil {
+		return domain.URLPair{}, newValidationError(err)
+	}
+
+	activeFrom := getActiveFrom(req.ActiveFrom)
+
+	if !activeFrom.IsZero() && !expiresAt.IsZero() && !activeFrom.Before(expiresAt) {
+		return domain.URLPair{}, newValidationError(ErrActivationIsTooLate)
+	}
+
+	if utf8.RuneCountInString(req.Title) > MaxTitleLength {
+		return domain.URLPair{}, newValidationError(ErrTitleIsTooLong)
+	}
+
+	if req.MaxClicks < 0 {
+		return domain.URLPair{}, newValidationError(ErrMaxClicksIsNegative)
+	}
+
+	tags, err := normalizeTags(req.Tags)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	rules, err := s.newRoutingRules(req.Rules)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	redirectStatus, err := getRedirectStatus(req.RedirectStatus)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+
+	if err != nil {
+		return domain.URLPair{}, err
+	}
+
+	pair := domain.URLPair{
+		ShortURL:       req.Alias,
+		OriginalURL:    originalURL,
+		CanonicalURL:   canonicalURL,
+		PasswordHash:   passwordHash,
+		Title:          req.Title,
+		Tags:           tags,
+		CreatedAt:      now,
+		ActiveFrom:     activeFrom,
+		ExpiresAt:      expiresAt,
+		MaxClicks:      req.MaxClicks,
+		ClicksLeft:     req.MaxClicks,
+		Destinations:   destinations,
+		Rotation:       rotation,
+		Rules:          rules,
+		ForwardPath:    req.ForwardPath,
+		RedirectStatus: redirectStatus,
+	}
+	return pair, nil
+}
+
+// newImportedURLPair проверяет данные импортируемого URL. В отличие от сокращения, ключ обязателен.
+func (s *URLService) newImportedURLPair(req ShortenRequest, now time.Time) (domain.URLPair, error) {
+	if err := shortener.Validate(req.Alias); err != nil {
+		return domain.URLPair{}, newValidationError(err)
+	}
+
+	return s.newURLPair(req, now)
+}
+
+// checkURL проверяет исходный URL и его домен и возвращает URL без пробелов по краям вместе с каноническим
+// видом. Если домен запрещен политикой, возвращается ошибка *policy.ViolationError.
+func (s *URLService) checkURL(rawURL string) (string, string, error) {
+	originalURL := strings.TrimSpace(rawURL)
+
+	if originalURL == "" {
+		return "", "", newValidationError(ErrURLIsEmpty)
+	}
+
+	canonicalURL, err := s.canonicalizer.Canonicalize(originalURL)
+
+	if err != nil {
+		return "", "", newValidationError(err)
+	}
+
+	if err = s.checkDomain(canonicalURL); err != nil {
+		return "", "", err
+	}
+
+	return originalURL, canonicalURL, nil
+}
+
+// checkDomain проверяет домен исходного URL по политике доменов, если она задана. Ошибка *policy.ViolationError
+// возвращается без обертки: ее текст содержит причину отказа для клиента.
+func (s *URLService) checkDomain(rawURL string) error {
+	if s.domainPolicy == nil {
+		return nil
+	}
+
+	return s.domainPolicy.CheckURL(rawURL)
+}
+
+// normalizeTags удаляет из меток пробелы по краям, пустые значения и повторы и сортирует их.
+// Для пустого набора возвращается nil.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]struct{}, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, newValidationError(ErrTagIsTooLong)
+		}
+
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxTagsCount {
+		return nil, newValidationError(ErrTooManyTags)
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// hashPassword возвращает хеш пароля. Для пустого пароля возвращается пустой хеш.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", newValidationError(ErrPasswordIsTooLong)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func newShortURL() string {
+	return shortener.Shorten(uuid.New().ID())
+}
+
+// getActiveFrom возвращает время начала действия сокращенного URL. Нулевое значение означает, что
+// сокращенный URL действует сразу.
+func getActiveFrom(activeFrom *time.Time) time.Time {
+	if activeFrom == nil {
+		return time.Time{}
+	}
+
+	return *activeFrom
+}
+
+// getExpiresAt возвращает время окончания действия сокращенного URL, заданное явно или через время жизни.
+// Нулевое значение означает, что срок действия не ограничен.
+func getExpiresAt(expiresAt *time.Time, ttl int64, now time.Time) (time.Time, error) {
+	if ttl < 0 {
+		return time.Time{}, ErrTTLIsNegative
+	}
+
+	if ttl > MaxTTL {
+		return time.Time{}, ErrTTLIsTooLong
+	}
+
+	if expiresAt != nil && ttl > 0 {
+		return time.Time{}, ErrExpirationIsAmbiguous
+	}
+
+	if ttl > 0 {
+		return now.Add(time.Duration(ttl) * time.Second), nil
+	}
+
+	if expiresAt == nil {
+		return time.Time{}, nil
+	}
+
+	if !now.Before(*expiresAt) {
+		return time.Time{}, ErrExpirationIsInPast
+	}
+
+	return *expiresAt, nil
+}
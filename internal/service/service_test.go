@@ -0,0 +1,758 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nestjam/yap-shortener/internal/canonical"
+	"github.com/nestjam/yap-shortener/internal/domain"
+	"github.com/nestjam/yap-shortener/internal/persistance/inmemory"
+	"github.com/nestjam/yap-shortener/internal/policy"
+	"github.com/nestjam/yap-shortener/internal/shortener"
+)
+
+const testURL = "https://practicum.yandex.ru/"
+
+func TestShorten(t *testing.T) {
+	t.Run("shorten url", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, domain.NewUserID())
+
+		require.NoError(t, err)
+		got, err := store.GetOriginalURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, testURL, got)
+	})
+
+	t.Run("shorten same url twice", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		want, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+
+		got, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+
+		var originalURLAlreadyExists *domain.OriginalURLExistsError
+		assert.ErrorAs(t, err, &originalURLAlreadyExists)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("shorten url with same canonical url", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		want, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+
+		got, err := sut.Shorten(ctx, ShortenRequest{URL: " HTTPS://Practicum.Yandex.RU:443/ "}, userID)
+
+		var originalURLAlreadyExists *domain.OriginalURLExistsError
+		assert.ErrorAs(t, err, &originalURLAlreadyExists)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("store original and canonical url", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store, WithCanonicalizer(canonical.New(canonical.WithSortedQuery(true))))
+		ctx := context.Background()
+
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: " http://Пример.рф:80/?b=1&a=2 "}, domain.NewUserID())
+
+		require.NoError(t, err)
+		got, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, "http://Пример.рф:80/?b=1&a=2", got.OriginalURL)
+		assert.Equal(t, "http://xn--e1afmkfd.xn--p1ai/?a=2&b=1", got.CanonicalURL)
+	})
+
+	t.Run("shorten url with scheme allowed by options", func(t *testing.T) {
+		sut := New(inmemory.New(), WithCanonicalizer(canonical.New(canonical.WithSchemes("ftp"))))
+
+		_, err := sut.Shorten(context.Background(), ShortenRequest{URL: "ftp://example.com/file"},
+			domain.NewUserID())
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("domain is blocked", func(t *testing.T) {
+		domainPolicy, err := policy.New([]string{"*.yandex.ru"}, nil)
+		require.NoError(t, err)
+		sut := New(inmemory.New(), WithDomainPolicy(domainPolicy))
+
+		_, err = sut.Shorten(context.Background(), ShortenRequest{URL: testURL}, domain.NewUserID())
+
+		var violationErr *policy.ViolationError
+		require.ErrorAs(t, err, &violationErr)
+		assert.ErrorIs(t, err, policy.ErrDomainIsBlocked)
+	})
+
+	t.Run("shorten url with tags", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		req := ShortenRequest{URL: testURL, Tags: []string{" team ", "promo", "", "team"}}
+
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+
+		require.NoError(t, err)
+		got, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"promo", "team"}, got.Tags)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		tests := []struct {
+			want error
+			name string
+			req  ShortenRequest
+		}{
+			{
+				name: "url is empty",
+				req:  ShortenRequest{},
+				want: ErrURLIsEmpty,
+			},
+			{
+				name: "url is blank",
+				req:  ShortenRequest{URL: "  "},
+				want: ErrURLIsEmpty,
+			},
+			{
+				name: "max clicks is negative",
+				req:  ShortenRequest{URL: testURL, MaxClicks: -1},
+				want: ErrMaxClicksIsNegative,
+			},
+			{
+				name: "activation is after expiration",
+				req:  ShortenRequest{URL: testURL, ActiveFrom: &future, TTL: 60},
+				want: ErrActivationIsTooLate,
+			},
+			{
+				name: "url is invalid",
+				req:  ShortenRequest{URL: "not a url"},
+				want: canonical.ErrURLIsInvalid,
+			},
+			{
+				name: "url scheme is not allowed",
+				req:  ShortenRequest{URL: "ftp://example.com/file"},
+				want: canonical.ErrSchemeIsNotAllowed,
+			},
+			{
+				name: "url host is empty",
+				req:  ShortenRequest{URL: "https:///path"},
+				want: canonical.ErrHostIsEmpty,
+			},
+			{
+				name: "ttl is negative",
+				req:  ShortenRequest{URL: testURL, TTL: -1},
+				want: ErrTTLIsNegative,
+			},
+			{
+				name: "ttl is too long",
+				req:  ShortenRequest{URL: testURL, TTL: math.MaxInt64},
+				want: ErrTTLIsTooLong,
+			},
+			{
+				name: "expiration is ambiguous",
+				req:  ShortenRequest{URL: testURL, TTL: 1, ExpiresAt: &past},
+				want: ErrExpirationIsAmbiguous,
+			},
+			{
+				name: "expiration is in the past",
+				req:  ShortenRequest{URL: testURL, ExpiresAt: &past},
+				want: ErrExpirationIsInPast,
+			},
+			{
+				name: "password is too long",
+				req:  ShortenRequest{URL: testURL, Password: strings.Repeat("a", 73)},
+				want: ErrPasswordIsTooLong,
+			},
+			{
+				name: "title is too long",
+				req:  ShortenRequest{URL: testURL, Title: strings.Repeat("a", MaxTitleLength+1)},
+				want: ErrTitleIsTooLong,
+			},
+			{
+				name: "tag is too long",
+				req:  ShortenRequest{URL: testURL, Tags: []string{strings.Repeat("a", MaxTagLength+1)}},
+				want: ErrTagIsTooLong,
+			},
+			{
+				name: "too many tags",
+				req:  ShortenRequest{URL: testURL, Tags: newTags(MaxTagsCount + 1)},
+				want: ErrTooManyTags,
+			},
+			{
+				name: "alias is reserved",
+				req:  ShortenRequest{URL: testURL, Alias: "api"},
+				want: shortener.ErrKeyIsReserved,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sut := New(inmemory.New())
+
+				_, err := sut.Shorten(context.Background(), tt.req, domain.NewUserID())
+
+				var validationErr *ValidationError
+				assert.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, err, tt.want)
+			})
+		}
+	})
+}
+
+func TestImportURLs(t *testing.T) {
+	t.Run("import urls with keys", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		err := store.AddURL(ctx, domain.URLPair{ShortURL: "taken", OriginalURL: "http://example.io"}, userID)
+		require.NoError(t, err)
+		reqs := []ShortenRequest{
+			{Alias: "abc", URL: "http://example.com", Tags: []string{"promo"}},
+			{Alias: "taken", URL: "http://example.org"},
+			{Alias: "def", URL: "http://example.io"},
+			{Alias: "abc", URL: "http://example.net"},
+			{Alias: "ghi", URL: "http://example.com"},
+			{Alias: "", URL: "http://example.ru"},
+			{Alias: "jkm", URL: ""},
+		}
+
+		got, err := sut.ImportURLs(ctx, reqs, userID)
+
+		require.NoError(t, err)
+		statuses := make([]domain.KeyStatus, len(got))
+		for i, result := range got {
+			statuses[i] = result.Status
+		}
+		want := []domain.KeyStatus{
+			domain.KeyImported,
+			domain.KeyTaken,
+			domain.KeyURLExists,
+			domain.KeyTaken,
+			domain.KeyURLExists,
+			domain.KeyInvalid,
+			domain.KeyInvalid,
+		}
+		assert.Equal(t, want, statuses)
+		assert.ErrorIs(t, got[5].Err, shortener.ErrKeyIsEmpty)
+		assert.ErrorIs(t, got[6].Err, ErrURLIsEmpty)
+		pair, err := store.GetURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com", pair.OriginalURL)
+		assert.Equal(t, []string{"promo"}, pair.Tags)
+	})
+
+	t.Run("chunk conflicts with urls added concurrently", func(t *testing.T) {
+		store := inmemory.New()
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		err := store.AddURL(ctx, domain.URLPair{ShortURL: "abc", OriginalURL: "http://example.io"}, userID)
+		require.NoError(t, err)
+		delegate := domain.NewURLStoreDelegate(store)
+		delegate.FindConflictsFunc = func(ctx context.Context, pairs []domain.URLPair) (map[string]domain.KeyStatus,
+			error) {
+			return map[string]domain.KeyStatus{}, nil
+		}
+		sut := New(delegate)
+		reqs := []ShortenRequest{
+			{Alias: "abc", URL: "http://example.com"},
+			{Alias: "def", URL: "http://example.org"},
+		}
+
+		got, err := sut.ImportURLs(ctx, reqs, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, []ImportResult{
+			{ShortURL: "abc", Status: domain.KeyTaken},
+			{ShortURL: "def", Status: domain.KeyImported},
+		}, got)
+	})
+
+	t.Run("failed to find conflicts", func(t *testing.T) {
+		delegate := domain.NewURLStoreDelegate(inmemory.New())
+		delegate.FindConflictsFunc = func(ctx context.Context, pairs []domain.URLPair) (map[string]domain.KeyStatus,
+			error) {
+			return nil, errors.New("failed to find conflicts")
+		}
+		sut := New(delegate)
+		reqs := []ShortenRequest{{Alias: "abc", URL: "http://example.com"}}
+
+		_, err := sut.ImportURLs(context.Background(), reqs, domain.NewUserID())
+
+		assert.Error(t, err)
+	})
+}
+
+func TestUpdateTags(t *testing.T) {
+	t.Run("update tags", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL, Tags: []string{"promo"}}, userID)
+		require.NoError(t, err)
+
+		got, err := sut.UpdateTags(ctx, shortURL, []string{"team", "spring", "team"}, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"spring", "team"}, got)
+		pair, err := store.GetURL(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, got, pair.Tags)
+	})
+
+	t.Run("url is added by other user", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, domain.NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.UpdateTags(ctx, shortURL, []string{"promo"}, domain.NewUserID())
+
+		assert.ErrorIs(t, err, domain.ErrOriginalURLNotOwned)
+	})
+
+	t.Run("too many tags", func(t *testing.T) {
+		sut := New(inmemory.New())
+
+		_, err := sut.UpdateTags(context.Background(), "abc", newTags(MaxTagsCount+1), domain.NewUserID())
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.ErrorIs(t, err, ErrTooManyTags)
+	})
+}
+
+func newTags(count int) []string {
+	tags := make([]string, count)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag%d", i)
+	}
+	return tags
+}
+
+func TestExpand(t *testing.T) {
+	const password = "secret"
+	shortenProtected := func(t *testing.T, sut *URLService) string {
+		t.Helper()
+		shortURL, err := sut.Shorten(context.Background(), ShortenRequest{URL: testURL, Password: password},
+			domain.NewUserID())
+		require.NoError(t, err)
+		return shortURL
+	}
+
+	t.Run("expand url", func(t *testing.T) {
+		sut := New(inmemory.New())
+		shortURL, err := sut.Shorten(context.Background(), ShortenRequest{URL: testURL}, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.Expand(context.Background(), shortURL, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, testURL, got)
+	})
+
+	t.Run("expand one-time url", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL, MaxClicks: 1}, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.Expand(ctx, shortURL, "")
+		require.NoError(t, err)
+		assert.Equal(t, testURL, got)
+
+		_, err = sut.Expand(ctx, shortURL, "")
+		assert.ErrorIs(t, err, domain.ErrOriginalURLIsExhausted)
+	})
+
+	t.Run("expand scheduled url", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		activeFrom := time.Now().Add(time.Hour)
+		req := ShortenRequest{URL: testURL, ActiveFrom: &activeFrom, MaxClicks: 1}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.Expand(ctx, shortURL, "")
+
+		assert.ErrorIs(t, err, domain.ErrOriginalURLIsNotActive)
+	})
+
+	t.Run("expand url after activation", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		activeFrom := time.Now().Add(-time.Minute)
+		req := ShortenRequest{URL: testURL, ActiveFrom: &activeFrom}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		got, err := sut.Expand(ctx, shortURL, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, testURL, got)
+	})
+
+	t.Run("invalid password does not consume click", func(t *testing.T) {
+		sut := New(inmemory.New())
+		ctx := context.Background()
+		req := ShortenRequest{URL: testURL, Password: password, MaxClicks: 1}
+		shortURL, err := sut.Shorten(ctx, req, domain.NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.Expand(ctx, shortURL, "wrong")
+		require.ErrorIs(t, err, ErrPasswordIsInvalid)
+
+		got, err := sut.Expand(ctx, shortURL, password)
+		require.NoError(t, err)
+		assert.Equal(t, testURL, got)
+	})
+
+	t.Run("expand protected url", func(t *testing.T) {
+		sut := New(inmemory.New())
+		shortURL := shortenProtected(t, sut)
+
+		got, err := sut.Expand(context.Background(), shortURL, password)
+
+		require.NoError(t, err)
+		assert.Equal(t, testURL, got)
+	})
+
+	t.Run("domain is blocked after shortening", func(t *testing.T) {
+		store := inmemory.New()
+		shortURL, err := New(store).Shorten(context.Background(), ShortenRequest{URL: testURL}, domain.NewUserID())
+		require.NoError(t, err)
+		domainPolicy, err := policy.New([]string{"practicum.yandex.ru"}, nil)
+		require.NoError(t, err)
+		sut := New(store, WithDomainPolicy(domainPolicy))
+
+		_, err = sut.Expand(context.Background(), shortURL, "")
+
+		assert.ErrorIs(t, err, policy.ErrDomainIsBlocked)
+	})
+
+	t.Run("password is required", func(t *testing.T) {
+		sut := New(inmemory.New())
+		shortURL := shortenProtected(t, sut)
+
+		_, err := sut.Expand(context.Background(), shortURL, "")
+
+		assert.ErrorIs(t, err, ErrPasswordRequired)
+	})
+
+	t.Run("password is invalid", func(t *testing.T) {
+		sut := New(inmemory.New())
+		shortURL := shortenProtected(t, sut)
+
+		_, err := sut.Expand(context.Background(), shortURL, "wrong")
+
+		assert.ErrorIs(t, err, ErrPasswordIsInvalid)
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		sut := New(inmemory.New(), WithAttemptLimiter(NewAttemptLimiter(1, time.Hour)))
+		shortURL := shortenProtected(t, sut)
+		_, err := sut.Expand(context.Background(), shortURL, "wrong")
+		require.ErrorIs(t, err, ErrPasswordIsInvalid)
+
+		_, err = sut.Expand(context.Background(), shortURL, password)
+
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+	})
+}
+
+func TestShortenBatch(t *testing.T) {
+	t.Run("shorten batch", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		reqs := []ShortenRequest{{URL: testURL}, {URL: "https://ya.ru/"}}
+
+		shortURLs, err := sut.ShortenBatch(ctx, reqs, domain.NewUserID())
+
+		require.NoError(t, err)
+		require.Len(t, shortURLs, len(reqs))
+		for i, shortURL := range shortURLs {
+			got, err := store.GetOriginalURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, reqs[i].URL, got)
+		}
+	})
+
+	t.Run("shorten already shortened urls", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+		reqs := []ShortenRequest{{URL: "https://ya.ru/"}, {URL: testURL}, {URL: "https://ya.ru/"}}
+
+		got, err := sut.ShortenBatch(ctx, reqs, userID)
+
+		require.NoError(t, err)
+		require.Len(t, got, len(reqs))
+		assert.Equal(t, shortURL, got[1])
+		assert.Equal(t, got[0], got[2])
+		original, err := store.GetOriginalURL(ctx, got[0])
+		require.NoError(t, err)
+		assert.Equal(t, "https://ya.ru/", original)
+	})
+
+	t.Run("too many urls", func(t *testing.T) {
+		sut := New(inmemory.New(), WithShortenURLsMaxCount(1))
+		reqs := []ShortenRequest{{URL: testURL}, {URL: "https://ya.ru/"}}
+
+		_, err := sut.ShortenBatch(context.Background(), reqs, domain.NewUserID())
+
+		assert.ErrorIs(t, err, ErrTooManyURLs)
+	})
+}
+
+func TestDeleteUserURLs(t *testing.T) {
+	t.Run("delete with remover", func(t *testing.T) {
+		remover := &urlRemoverSpy{}
+		sut := New(inmemory.New(), WithURLRemover(remover))
+		shortURLs := []string{"EwHXdJfB"}
+		userID := domain.NewUserID()
+
+		jobID, err := sut.DeleteUserURLs(context.Background(), shortURLs, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, shortURLs, remover.shortURLs)
+		job, err := sut.GetDeleteJob(jobID, userID)
+		require.NoError(t, err)
+		assert.Equal(t, JobPending, job.Status)
+
+		results := []domain.KeyResult{{ShortURL: "EwHXdJfB", Status: domain.KeyNotFound}}
+		remover.done(results, nil)
+
+		job, err = sut.GetDeleteJob(jobID, userID)
+		require.NoError(t, err)
+		assert.Equal(t, JobDone, job.Status)
+		assert.Equal(t, results, job.Results)
+	})
+
+	t.Run("delete without remover", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store)
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+
+		jobID, err := sut.DeleteUserURLs(ctx, []string{shortURL}, userID)
+
+		require.NoError(t, err)
+		job, err := sut.GetDeleteJob(jobID, userID)
+		require.NoError(t, err)
+		assert.Equal(t, JobDone, job.Status)
+		assert.Equal(t, []domain.KeyResult{{ShortURL: shortURL, Status: domain.KeyDeleted}}, job.Results)
+	})
+
+	t.Run("remover failed", func(t *testing.T) {
+		remover := &urlRemoverSpy{err: errors.New("failed")}
+		sut := New(inmemory.New(), WithURLRemover(remover))
+
+		_, err := sut.DeleteUserURLs(context.Background(), []string{"EwHXdJfB"}, domain.NewUserID())
+
+		assert.Error(t, err)
+	})
+
+	t.Run("job of other user", func(t *testing.T) {
+		sut := New(inmemory.New())
+		jobID, err := sut.DeleteUserURLs(context.Background(), []string{"EwHXdJfB"}, domain.NewUserID())
+		require.NoError(t, err)
+
+		_, err = sut.GetDeleteJob(jobID, domain.NewUserID())
+
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+}
+
+func TestQuota(t *testing.T) {
+	t.Run("shorten url within quota", func(t *testing.T) {
+		userID := domain.NewUserID()
+		sut := New(inmemory.New(), WithQuotas(Quotas{Default: 1}))
+		ctx := context.Background()
+
+		_, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+
+		quota, err := sut.GetQuota(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, Quota{Limit: 1, Used: 1}, quota)
+	})
+
+	t.Run("quota is exceeded", func(t *testing.T) {
+		userID := domain.NewUserID()
+		sut := New(inmemory.New(), WithQuotas(Quotas{Default: 1}))
+		ctx := context.Background()
+		_, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+
+		_, err = sut.Shorten(ctx, ShortenRequest{URL: "https://ya.ru/"}, userID)
+
+		var quotaExceeded *QuotaExceededError
+		require.ErrorAs(t, err, &quotaExceeded)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.Equal(t, Quota{Limit: 1, Used: 1}, quotaExceeded.Quota())
+		assert.Equal(t, "url quota exceeded: 1 of 1 urls used", quotaExceeded.Error())
+	})
+
+	t.Run("batch exceeds quota", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store, WithQuotas(Quotas{Default: 1}))
+		userID := domain.NewUserID()
+		reqs := []ShortenRequest{{URL: testURL}, {URL: "https://ya.ru/"}}
+
+		_, err := sut.ShortenBatch(context.Background(), reqs, userID)
+
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		count, err := store.GetUserURLsCount(context.Background(), userID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("import exceeds quota", func(t *testing.T) {
+		sut := New(inmemory.New(), WithQuotas(Quotas{Default: 1}))
+		reqs := []ShortenRequest{{URL: testURL, Alias: "abc"}, {URL: "https://ya.ru/", Alias: "def"}}
+
+		_, err := sut.ImportURLs(context.Background(), reqs, domain.NewUserID())
+
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+	})
+
+	t.Run("skipped import rows are not counted", func(t *testing.T) {
+		store := inmemory.New()
+		sut := New(store, WithQuotas(Quotas{Default: 2}))
+		ctx := context.Background()
+		userID := domain.NewUserID()
+		err := store.AddURL(ctx, domain.URLPair{ShortURL: "abc", OriginalURL: "http://example.io"}, userID)
+		require.NoError(t, err)
+		reqs := []ShortenRequest{
+			{Alias: "abc", URL: "http://example.com"},
+			{Alias: "def", URL: "http://example.io"},
+			{Alias: "", URL: "http://example.ru"},
+			{Alias: "ghi", URL: "http://example.org"},
+		}
+
+		got, err := sut.ImportURLs(ctx, reqs, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.KeyImported, got[3].Status)
+	})
+
+	t.Run("deleted urls are not counted", func(t *testing.T) {
+		userID := domain.NewUserID()
+		sut := New(inmemory.New(), WithQuotas(Quotas{Default: 1}))
+		ctx := context.Background()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL}, userID)
+		require.NoError(t, err)
+		_, err = sut.DeleteUserURLs(ctx, []string{shortURL}, userID)
+		require.NoError(t, err)
+
+		_, err = sut.Shorten(ctx, ShortenRequest{URL: "https://ya.ru/"}, userID)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("exhausted urls are not counted", func(t *testing.T) {
+		userID := domain.NewUserID()
+		sut := New(inmemory.New(), WithQuotas(Quotas{Default: 1}))
+		ctx := context.Background()
+		shortURL, err := sut.Shorten(ctx, ShortenRequest{URL: testURL, MaxClicks: 1}, userID)
+		require.NoError(t, err)
+		_, err = sut.ExpandTarget(ctx, shortURL, ExpandRequest{})
+		require.NoError(t, err)
+
+		_, err = sut.Shorten(ctx, ShortenRequest{URL: "https://ya.ru/"}, userID)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrent requests do not exceed quota", func(t *testing.T) {
+		const (
+			limit    = 5
+			requests = 20
+		)
+		userID := domain.NewUserID()
+		store := inmemory.New()
+		sut := New(store, WithQuotas(Quotas{Default: limit}))
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _ = sut.Shorten(ctx, ShortenRequest{URL: fmt.Sprintf("https://example.com/%d", i)}, userID)
+			}(i)
+		}
+		wg.Wait()
+
+		count, err := store.GetUserURLsCount(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, limit, count)
+	})
+
+	t.Run("user quota overrides default quota", func(t *testing.T) {
+		userID := domain.NewUserID()
+		sut := New(inmemory.New(), WithQuotas(Quotas{
+			Default: 1,
+			Users:   map[domain.UserID]int{userID: 0},
+		}))
+		reqs := []ShortenRequest{{URL: testURL}, {URL: "https://ya.ru/"}}
+
+		_, err := sut.ShortenBatch(context.Background(), reqs, userID)
+		require.NoError(t, err)
+
+		quota, err := sut.GetQuota(context.Background(), userID)
+		require.NoError(t, err)
+		assert.True(t, quota.IsUnlimited())
+		assert.Equal(t, 2, quota.Used)
+	})
+
+	t.Run("failed to count user urls", func(t *testing.T) {
+		store := domain.NewURLStoreDelegate(inmemory.New())
+		store.GetUserURLsCountFunc = func(ctx context.Context, userID domain.UserID) (int, error) {
+			return 0, errors.New("failed to count")
+		}
+		sut := New(store, WithQuotas(Quotas{Default: 1}))
+
+		_, err := sut.Shorten(context.Background(), ShortenRequest{URL: testURL}, domain.NewUserID())
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrQuotaExceeded)
+	})
+}
+
+type urlRemoverSpy struct {
+	err       error
+	done      DeleteCallback
+	shortURLs []string
+}
+
+func (s *urlRemoverSpy) DeleteURLs(shortURLs []string, _ domain.UserID, done DeleteCallback) error {
+	s.shortURLs = shortURLs
+	s.done = done
+	return s.err
+}
@@ -1,9 +1,28 @@
 package shortener
 
+import (
+	"errors"
+	"strings"
+)
+
 const alphabet = "ynAJfoSgdXHB5VasEMtcbPCr1uNZ4LG723ehWkvwYR6KpxjTm8iQUFqz9D"
 
+// MaxKeyLength определяет максимальную длину ключа сокращенного URL, заданного пользователем.
+const MaxKeyLength = 32
+
 var alphabetLen = uint32(len(alphabet))
 
+// Зарезервированные ключи, совпадающие с путями API сервиса.
+var reservedKeys = []string{"api", "ping"}
+
+// Ошибки проверки ключа сокращенного URL.
+var (
+	ErrKeyIsEmpty    = errors.New("key is empty")                    // ключ не задан
+	ErrKeyIsTooLong  = errors.New("key is too long")                 // ключ превышает максимальную длину
+	ErrKeyIsInvalid  = errors.New("key contains invalid characters") // ключ содержит символы не из алфавита
+	ErrKeyIsReserved = errors.New("key is reserved")                 // ключ зарезервирован
+)
+
 // Shorten конвертирует число в строку на основании алфавита из 58 символов.
 func Shorten(id uint32) string {
 	letters := []byte{}
@@ -19,3 +38,29 @@ func Shorten(id uint32) string {
 
 	return string(letters)
 }
+
+// Validate проверяет, что ключ может быть использован в качестве сокращенного URL:
+// ключ состоит только из символов алфавита, не превышает максимальную длину и не зарезервирован.
+func Validate(key string) error {
+	if key == "" {
+		return ErrKeyIsEmpty
+	}
+
+	if len(key) > MaxKeyLength {
+		return ErrKeyIsTooLong
+	}
+
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(alphabet, key[i]) < 0 {
+			return ErrKeyIsInvalid
+		}
+	}
+
+	for _, reserved := range reservedKeys {
+		if strings.EqualFold(key, reserved) {
+			return ErrKeyIsReserved
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package shortener
 
 import (
 	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -61,3 +62,47 @@ func TestShorten(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		want error
+		name string
+		key  string
+	}{
+		{
+			name: "valid key",
+			key:  "Promo25",
+		},
+		{
+			name: "empty key",
+			key:  "",
+			want: ErrKeyIsEmpty,
+		},
+		{
+			name: "key is too long",
+			key:  strings.Repeat("a", MaxKeyLength+1),
+			want: ErrKeyIsTooLong,
+		},
+		{
+			name: "key contains character out of alphabet",
+			key:  "sale-0",
+			want: ErrKeyIsInvalid,
+		},
+		{
+			name: "key is reserved",
+			key:  "api",
+			want: ErrKeyIsReserved,
+		},
+		{
+			name: "key is reserved in other case",
+			key:  "Ping",
+			want: ErrKeyIsReserved,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Validate(tt.key)
+			assert.ErrorIs(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN expires_at TIMESTAMPTZ;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE click(id BIGSERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT ''
+);
+CREATE INDEX click_short_url_idx ON click (short_url, clicked_at);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS url_user_id_created_at_idx;
+ALTER TABLE url
+DROP COLUMN created_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE url
+ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX url_user_id_created_at_idx ON url (user_id, created_at, short_url);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+DROP COLUMN title;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN title TEXT NOT NULL DEFAULT '';
\ No newline at end of file
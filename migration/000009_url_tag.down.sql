@@ -0,0 +1 @@
+DROP TABLE IF EXISTS url_tag;
\ No newline at end of file
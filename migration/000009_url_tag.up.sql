@@ -0,0 +1,5 @@
+CREATE TABLE url_tag(short_url VARCHAR(255) NOT NULL REFERENCES url (short_url) ON DELETE CASCADE,
+    tag VARCHAR(64) NOT NULL,
+    PRIMARY KEY (short_url, tag)
+);
+CREATE INDEX url_tag_tag_idx ON url_tag (tag);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE url
+ADD CONSTRAINT url_original_url_key UNIQUE (original_url);
+ALTER TABLE url
+DROP COLUMN canonical_url;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE url
+ADD COLUMN canonical_url TEXT;
+CREATE UNIQUE INDEX url_canonical_url_key ON url ((COALESCE(canonical_url, original_url)));
+ALTER TABLE url
+DROP CONSTRAINT url_original_url_key;
\ No newline at end of file
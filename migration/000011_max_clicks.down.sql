@@ -0,0 +1,3 @@
+ALTER TABLE url
+DROP COLUMN max_clicks,
+DROP COLUMN clicks_left;
\ No newline at end of file
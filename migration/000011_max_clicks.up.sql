@@ -0,0 +1,3 @@
+ALTER TABLE url
+ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN clicks_left INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
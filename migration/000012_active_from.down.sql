@@ -0,0 +1,2 @@
+ALTER TABLE url
+DROP COLUMN active_from;
\ No newline at end of file
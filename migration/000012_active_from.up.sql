@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN active_from TIMESTAMPTZ;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE click
+DROP COLUMN variant;
+ALTER TABLE url
+DROP COLUMN destinations,
+DROP COLUMN rotation;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE url
+ADD COLUMN destinations JSONB,
+ADD COLUMN rotation VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE click
+ADD COLUMN variant VARCHAR(255) NOT NULL DEFAULT '';
\ No newline at end of file
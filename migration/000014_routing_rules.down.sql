@@ -0,0 +1,2 @@
+ALTER TABLE url
+DROP COLUMN routing_rules;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN routing_rules JSONB;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+DROP COLUMN forward_path;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN forward_path BOOLEAN NOT NULL DEFAULT false;
\ No newline at end of file
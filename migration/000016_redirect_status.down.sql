@@ -0,0 +1,2 @@
+ALTER TABLE url
+DROP COLUMN redirect_status;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE url
+ADD COLUMN redirect_status SMALLINT NOT NULL DEFAULT 0;
\ No newline at end of file